@@ -0,0 +1,236 @@
+// Package cbortest provides CBOR conformance test vectors: the examples of RFC 8949, Appendix A, and an
+// extended set covering encodings that the RFC doesn't. Implementations of encoders, decoders, and
+// Marshaler/Unmarshaler types can check themselves against the same vectors used to test package cbor.
+package cbortest
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// A Vector is an encoded CBOR data item along with its meaning.
+type Vector struct {
+	// Diagnostic is the item in diagnostic notation (RFC 8949, section 8), with the encoding indicators of
+	// RFC 8610, appendix G, for items that aren't in preferred serialization.
+	Diagnostic string
+	CBOR       []byte
+
+	// Value is the item as a Go value:
+	//
+	//	unsigned integers          uint64
+	//	negative integers          int64
+	//	larger integers, bignums   *big.Int
+	//	floats                     float64
+	//	byte strings               []byte
+	//	text strings               string
+	//	arrays                     []interface{}
+	//	maps                       map[interface{}]interface{}
+	//	false, true                bool
+	//	null                       nil
+	//	undefined                  Undefined
+	//	other simple values        Simple
+	//	other tags                 Tag
+	Value interface{}
+
+	// Roundtrip reports whether CBOR is the preferred serialization of Value, so that an encoder should
+	// produce it exactly.
+	Roundtrip bool
+}
+
+// A Tag is a tagged data item, other than a bignum.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// Simple is a simple value other than false, true, null, and undefined.
+type Simple uint8
+
+type undefined struct{}
+
+// Undefined is the value of the undefined simple value.
+var Undefined undefined
+
+func (undefined) String() string { return "undefined" }
+
+// AppendixA returns the examples of RFC 8949, Appendix A.
+func AppendixA() []Vector {
+	return parseVectors(appendixA)
+}
+
+// Extended returns vectors beyond those of Appendix A: encodings that aren't preferred serialization, more
+// boundary values, and tags used by package cbor.
+func Extended() []Vector {
+	return parseVectors(extended)
+}
+
+type vector struct {
+	diag      string
+	hex       string
+	value     interface{}
+	roundtrip bool
+}
+
+// parseVectors builds new Vectors from vs (so the caller may modify them).
+func parseVectors(vs []vector) []Vector {
+	result := make([]Vector, len(vs))
+	for i, v := range vs {
+		b, err := hex.DecodeString(v.hex)
+		if err != nil {
+			panic(err)
+		}
+		result[i] = Vector{Diagnostic: v.diag, CBOR: b, Value: copyValue(v.value), Roundtrip: v.roundtrip}
+	}
+	return result
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return append([]byte{}, v...)
+	case *big.Int:
+		return new(big.Int).Set(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			result[i] = copyValue(elem)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for k, elem := range v {
+			result[k] = copyValue(elem)
+		}
+		return result
+	case Tag:
+		return Tag{v.Number, copyValue(v.Content)}
+	}
+	return v
+}
+
+// Equal reports whether a and b represent the same CBOR value. Integers are compared by value regardless of
+// their Go type, as are float32 and float64 values. A NaN is equal to any other NaN, but positive and
+// negative zero are different.
+func Equal(a, b interface{}) bool {
+	if x, ok := toBig(a); ok {
+		y, ok := toBig(b)
+		return ok && x.Cmp(y) == 0
+	}
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return false
+		}
+		if math.IsNaN(x) || math.IsNaN(y) {
+			return math.IsNaN(x) && math.IsNaN(y)
+		}
+		return math.Float64bits(x) == math.Float64bits(y)
+	}
+	switch a := a.(type) {
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[interface{}]interface{}:
+		b, ok := b.(map[interface{}]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		// Keys of different integer types may be equal, so search rather than indexing b.
+	outer:
+		for ka, va := range a {
+			for kb, vb := range b {
+				if Equal(ka, kb) {
+					if !Equal(va, vb) {
+						return false
+					}
+					continue outer
+				}
+			}
+			return false
+		}
+		return true
+	case Tag:
+		b, ok := b.(Tag)
+		return ok && a.Number == b.Number && Equal(a.Content, b.Content)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toBig(v interface{}) (*big.Int, bool) {
+	if n, ok := v.(*big.Int); ok {
+		return n, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// CheckMarshal calls marshal with the Value of each vector that round trips and reports an error through t
+// if the result isn't the vector's CBOR. Vectors for which marshal returns an error wrapping
+// errors.ErrUnsupported are skipped.
+func CheckMarshal(t testing.TB, vectors []Vector, marshal func(interface{}) ([]byte, error)) {
+	t.Helper()
+	for _, v := range vectors {
+		if !v.Roundtrip {
+			continue
+		}
+		b, err := marshal(v.Value)
+		if errors.Is(err, errors.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			t.Errorf("marshaling %s: %s", v.Diagnostic, err)
+			continue
+		}
+		if string(b) != string(v.CBOR) {
+			t.Errorf("marshaling %s: got %x; want %x", v.Diagnostic, b, v.CBOR)
+		}
+	}
+}
+
+// CheckUnmarshal calls unmarshal with the CBOR of each vector and reports an error through t if the result
+// isn't Equal to the vector's Value. Vectors for which unmarshal returns an error wrapping
+// errors.ErrUnsupported are skipped.
+func CheckUnmarshal(t testing.TB, vectors []Vector, unmarshal func([]byte) (interface{}, error)) {
+	t.Helper()
+	for _, v := range vectors {
+		value, err := unmarshal(v.CBOR)
+		if errors.Is(err, errors.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			t.Errorf("unmarshaling %x (%s): %s", v.CBOR, v.Diagnostic, err)
+			continue
+		}
+		if !Equal(value, v.Value) {
+			t.Errorf("unmarshaling %x (%s): got %#v; want %#v", v.CBOR, v.Diagnostic, value, v.Value)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cbortest
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, v := range append(AppendixA(), Extended()...) {
+		if seen[string(v.CBOR)] {
+			t.Errorf("duplicate vector %x (%s)", v.CBOR, v.Diagnostic)
+		}
+		seen[string(v.CBOR)] = true
+		if !Equal(v.Value, v.Value) {
+			t.Errorf("%s: value isn't equal to itself", v.Diagnostic)
+		}
+	}
+}
+
+func TestVectorsCopied(t *testing.T) {
+	v := AppendixA()
+	v[0].CBOR[0] = 0xFF
+	v[len(v)-1].Value.(map[interface{}]interface{})["Fun"] = false
+	if w := AppendixA(); w[0].CBOR[0] != 0 || w[len(w)-1].Value.(map[interface{}]interface{})["Fun"] != true {
+		t.Fatal("modifying the returned vectors changed later results")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, test := range []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{uint64(1), 1, true},
+		{int64(-1), big.NewInt(-1), true},
+		{uint8(255), int16(255), true},
+		{1, 2, false},
+		{1, 1.0, false},
+		{float32(1.5), 1.5, true},
+		{math.NaN(), math.NaN(), true},
+		{0.0, math.Copysign(0, -1), false},
+		{"a", []byte("a"), false},
+		{[]byte{}, []byte{}, true},
+		{nil, nil, true},
+		{nil, Undefined, false},
+		{Simple(16), Simple(16), true},
+		{[]interface{}{1, "a"}, []interface{}{uint64(1), "a"}, true},
+		{[]interface{}{1}, []interface{}{1, 2}, false},
+		{map[interface{}]interface{}{1: "a"}, map[interface{}]interface{}{int64(1): "a"}, true},
+		{map[interface{}]interface{}{1: "a"}, map[interface{}]interface{}{2: "a"}, false},
+		{map[interface{}]interface{}{1: "a"}, map[interface{}]interface{}{1: "b"}, false},
+		{Tag{1, 2}, Tag{1, uint64(2)}, true},
+		{Tag{1, 2}, Tag{2, 2}, false},
+	} {
+		if got := Equal(test.a, test.b); got != test.equal {
+			t.Errorf("Equal(%#v, %#v): got %t", test.a, test.b, got)
+		}
+	}
+}
+
+// recorder is a testing.TB that records errors.
+type recorder struct {
+	testing.TB
+	errors int
+}
+
+func (r *recorder) Helper()                                   {}
+func (r *recorder) Errorf(format string, args ...interface{}) { r.errors++ }
+
+func TestCheck(t *testing.T) {
+	vectors := AppendixA()
+	r := &recorder{TB: t}
+	CheckUnmarshal(r, vectors, func(b []byte) (interface{}, error) {
+		for _, v := range vectors {
+			if bytes.Equal(v.CBOR, b) {
+				return v.Value, nil
+			}
+		}
+		return nil, errors.New("unknown")
+	})
+	if r.errors != 0 {
+		t.Errorf("CheckUnmarshal reported %d errors for a correct decoder", r.errors)
+	}
+	CheckMarshal(r, vectors, func(interface{}) ([]byte, error) { return nil, errors.ErrUnsupported })
+	if r.errors != 0 {
+		t.Errorf("CheckMarshal reported %d errors for unsupported values", r.errors)
+	}
+	CheckMarshal(r, vectors, func(interface{}) ([]byte, error) { return []byte{0}, nil })
+	if r.errors == 0 {
+		t.Error("CheckMarshal reported no errors for a broken encoder")
+	}
+}
@@ -0,0 +1,192 @@
+package cbortest
+
+import (
+	"math"
+	"math/big"
+)
+
+type (
+	array = []interface{}
+	dict  = map[interface{}]interface{}
+)
+
+func bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("bad integer " + s)
+	}
+	return n
+}
+
+func uints(lo, hi uint64) array {
+	var a array
+	for n := lo; n <= hi; n++ {
+		a = append(a, n)
+	}
+	return a
+}
+
+// https://www.rfc-editor.org/rfc/rfc8949.html#appendix-A
+var appendixA = []vector{
+	// Integers
+	{"0", "00", uint64(0), true},
+	{"1", "01", uint64(1), true},
+	{"10", "0a", uint64(10), true},
+	{"23", "17", uint64(23), true},
+	{"24", "1818", uint64(24), true},
+	{"25", "1819", uint64(25), true},
+	{"100", "1864", uint64(100), true},
+	{"1000", "1903e8", uint64(1000), true},
+	{"1000000", "1a000f4240", uint64(1000000), true},
+	{"1000000000000", "1b000000e8d4a51000", uint64(1000000000000), true},
+	{"18446744073709551615", "1bffffffffffffffff", uint64(math.MaxUint64), true},
+	{"18446744073709551616", "c249010000000000000000", bigInt("18446744073709551616"), true},
+	{"-18446744073709551616", "3bffffffffffffffff", bigInt("-18446744073709551616"), true},
+	{"-18446744073709551617", "c349010000000000000000", bigInt("-18446744073709551617"), true},
+	{"-1", "20", int64(-1), true},
+	{"-10", "29", int64(-10), true},
+	{"-100", "3863", int64(-100), true},
+	{"-1000", "3903e7", int64(-1000), true},
+
+	// Floats
+	{"0.0", "f90000", 0.0, true},
+	{"-0.0", "f98000", math.Copysign(0, -1), true},
+	{"1.0", "f93c00", 1.0, true},
+	{"1.1", "fb3ff199999999999a", 1.1, true},
+	{"1.5", "f93e00", 1.5, true},
+	{"65504.0", "f97bff", 65504.0, true},
+	{"100000.0", "fa47c35000", 100000.0, true},
+	{"3.4028234663852886e+38", "fa7f7fffff", 3.4028234663852886e+38, true},
+	{"1.0e+300", "fb7e37e43c8800759c", 1.0e+300, true},
+	{"5.960464477539063e-8", "f90001", 5.960464477539063e-8, true},
+	{"0.00006103515625", "f90400", 0.00006103515625, true},
+	{"-4.0", "f9c400", -4.0, true},
+	{"-4.1", "fbc010666666666666", -4.1, true},
+	{"Infinity", "f97c00", math.Inf(1), true},
+	{"NaN", "f97e00", math.NaN(), true},
+	{"-Infinity", "f9fc00", math.Inf(-1), true},
+	{"Infinity_2", "fa7f800000", math.Inf(1), false},
+	{"NaN_2", "fa7fc00000", math.NaN(), false},
+	{"-Infinity_2", "faff800000", math.Inf(-1), false},
+	{"Infinity_3", "fb7ff0000000000000", math.Inf(1), false},
+	{"NaN_3", "fb7ff8000000000000", math.NaN(), false},
+	{"-Infinity_3", "fbfff0000000000000", math.Inf(-1), false},
+
+	// Simple values
+	{"false", "f4", false, true},
+	{"true", "f5", true, true},
+	{"null", "f6", nil, true},
+	{"undefined", "f7", Undefined, true},
+	{"simple(16)", "f0", Simple(16), true},
+	{"simple(255)", "f8ff", Simple(255), true},
+
+	// Tags
+	{`0("2013-03-21T20:04:00Z")`, "c074323031332d30332d32315432303a30343a30305a", Tag{0, "2013-03-21T20:04:00Z"}, true},
+	{"1(1363896240)", "c11a514b67b0", Tag{1, uint64(1363896240)}, true},
+	{"1(1363896240.5)", "c1fb41d452d9ec200000", Tag{1, 1363896240.5}, true},
+	{"23(h'01020304')", "d74401020304", Tag{23, []byte{1, 2, 3, 4}}, true},
+	{"24(h'6449455446')", "d818456449455446", Tag{24, []byte("dIETF")}, true},
+	{`32("http://www.example.com")`, "d82076687474703a2f2f7777772e6578616d706c652e636f6d", Tag{32, "http://www.example.com"}, true},
+
+	// Strings
+	{"h''", "40", []byte{}, true},
+	{"h'01020304'", "4401020304", []byte{1, 2, 3, 4}, true},
+	{`""`, "60", "", true},
+	{`"a"`, "6161", "a", true},
+	{`"IETF"`, "6449455446", "IETF", true},
+	{`"\"\\"`, "62225c", "\"\\", true},
+	{`"ü"`, "62c3bc", "ü", true},
+	{`"水"`, "63e6b0b4", "水", true},
+	{`"𐅑"`, "64f0908591", "\U00010151", true},
+
+	// Arrays and maps
+	{"[]", "80", array{}, true},
+	{"[1, 2, 3]", "83010203", array{uint64(1), uint64(2), uint64(3)}, true},
+	{"[1, [2, 3], [4, 5]]", "8301820203820405", array{uint64(1), array{uint64(2), uint64(3)}, array{uint64(4), uint64(5)}}, true},
+	{"[1, 2, 3, ..., 25]", "98190102030405060708090a0b0c0d0e0f101112131415161718181819", uints(1, 25), true},
+	{"{}", "a0", dict{}, true},
+	{"{1: 2, 3: 4}", "a201020304", dict{uint64(1): uint64(2), uint64(3): uint64(4)}, true},
+	{`{"a": 1, "b": [2, 3]}`, "a26161016162820203", dict{"a": uint64(1), "b": array{uint64(2), uint64(3)}}, true},
+	{`["a", {"b": "c"}]`, "826161a161626163", array{"a", dict{"b": "c"}}, true},
+	{`{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}`, "a56161614161626142616361436164614461656145",
+		dict{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, true},
+
+	// Indefinite lengths
+	{"(_ h'0102', h'030405')", "5f42010243030405ff", []byte{1, 2, 3, 4, 5}, false},
+	{`(_ "strea", "ming")`, "7f657374726561646d696e67ff", "streaming", false},
+	{"[_ ]", "9fff", array{}, false},
+	{"[_ 1, [2, 3], [_ 4, 5]]", "9f018202039f0405ffff", array{uint64(1), array{uint64(2), uint64(3)}, array{uint64(4), uint64(5)}}, false},
+	{"[_ 1, [2, 3], [4, 5]]", "9f01820203820405ff", array{uint64(1), array{uint64(2), uint64(3)}, array{uint64(4), uint64(5)}}, false},
+	{"[1, [2, 3], [_ 4, 5]]", "83018202039f0405ff", array{uint64(1), array{uint64(2), uint64(3)}, array{uint64(4), uint64(5)}}, false},
+	{"[1, [_ 2, 3], [4, 5]]", "83019f0203ff820405", array{uint64(1), array{uint64(2), uint64(3)}, array{uint64(4), uint64(5)}}, false},
+	{"[_ 1, 2, 3, ..., 25]", "9f0102030405060708090a0b0c0d0e0f101112131415161718181819ff", uints(1, 25), false},
+	{`{_ "a": 1, "b": [_ 2, 3]}`, "bf61610161629f0203ffff", dict{"a": uint64(1), "b": array{uint64(2), uint64(3)}}, false},
+	{`["a", {_ "b": "c"}]`, "826161bf61626163ff", array{"a", dict{"b": "c"}}, false},
+	{`{_ "Fun": true, "Amt": -2}`, "bf6346756ef563416d7421ff", dict{"Fun": true, "Amt": int64(-2)}, false},
+}
+
+var mixedKeys = dict{uint64(10): uint64(1), uint64(100): uint64(2), int64(-1): uint64(3), "z": uint64(4), "aa": uint64(5), false: uint64(6)}
+
+var extended = []vector{
+	// Integer boundaries
+	{"255", "18ff", uint64(255), true},
+	{"256", "190100", uint64(256), true},
+	{"65535", "19ffff", uint64(65535), true},
+	{"65536", "1a00010000", uint64(65536), true},
+	{"4294967295", "1affffffff", uint64(math.MaxUint32), true},
+	{"4294967296", "1b0000000100000000", uint64(math.MaxUint32 + 1), true},
+	{"9223372036854775807", "1b7fffffffffffffff", uint64(math.MaxInt64), true},
+	{"-24", "37", int64(-24), true},
+	{"-25", "3818", int64(-25), true},
+	{"-9223372036854775808", "3b7fffffffffffffff", int64(math.MinInt64), true},
+	{"-9223372036854775809", "3b8000000000000000", bigInt("-9223372036854775809"), true},
+
+	// Integers wider than necessary
+	{"0_0", "1800", uint64(0), false},
+	{"0_1", "190000", uint64(0), false},
+	{"0_2", "1a00000000", uint64(0), false},
+	{"0_3", "1b0000000000000000", uint64(0), false},
+	{"-1_0", "3800", int64(-1), false},
+	{"23_3", "1b0000000000000017", uint64(23), false},
+
+	// Floats
+	{"6.097555160522461e-5", "f903ff", 6.097555160522461e-5, true},
+	{"-65504.0", "f9fbff", -65504.0, true},
+	{"65536.0", "fa47800000", 65536.0, true},
+	{"1.401298464324817e-45", "fa00000001", 1.401298464324817e-45, true},
+	{"5e-324", "fb0000000000000001", 5e-324, true},
+	{"0.0_2", "fa00000000", 0.0, false},
+	{"1.0_2", "fa3f800000", 1.0, false},
+	{"1.0_3", "fb3ff0000000000000", 1.0, false},
+	{"100000.0_3", "fb40f86a0000000000", 100000.0, false},
+
+	// Simple values
+	{"simple(0)", "e0", Simple(0), true},
+	{"simple(19)", "f3", Simple(19), true},
+	{"simple(32)", "f820", Simple(32), true},
+
+	// Strings
+	{"h''_1", "590000", []byte{}, false},
+	{"''_", "5fff", []byte{}, false},
+	{`""_`, "7fff", "", false},
+	{"(_ h'')", "5f40ff", []byte{}, false},
+	{`(_ "")`, "7f60ff", "", false},
+	{`(_ "a", "", "b")`, "7f6161606162ff", "ab", false},
+	{`"aaaaaaaaaaaaaaaaaaaaaaaa"`, "7818616161616161616161616161616161616161616161616161", "aaaaaaaaaaaaaaaaaaaaaaaa", true},
+
+	// Tags
+	{"2(h'')", "c240", bigInt("0"), false},
+	{"3(h'00')", "c34100", bigInt("-1"), false},
+	{"55799([1])", "d9d9f78101", Tag{55799, array{uint64(1)}}, true},
+	{"24(24(h'f6'))", "d818d81841f6", Tag{24, Tag{24, []byte{0xf6}}}, true},
+	{`262(h'7b2261223a317d')`, "d90106477b2261223a317d", Tag{262, []byte(`{"a":1}`)}, true},
+	{"1_3(0)", "db000000000000000100", Tag{1, uint64(0)}, false},
+
+	// Maps with keys of several types, sorted as in RFC 8949, section 4.2.1 (bytewise lexicographic) and
+	// RFC 7049, section 3.9 (shortest first). Key order isn't part of preferred serialization.
+	{`{10: 1, 100: 2, -1: 3, "z": 4, "aa": 5, false: 6}`, "a60a011864022003617a0462616105f406", mixedKeys, false},
+	{`{10: 1, -1: 3, false: 6, 100: 2, "z": 4, "aa": 5}`, "a60a012003f406186402617a0462616105", mixedKeys, false},
+	{"{_ }", "bfff", dict{}, false},
+	{"[[[[]]]]", "81818180", array{array{array{array{}}}}, true},
+	{"{1: {2: {}}}", "a101a102a0", dict{uint64(1): dict{uint64(2): dict{}}}, true},
+}
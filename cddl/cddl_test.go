@@ -0,0 +1,321 @@
+package cddl
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cespare/cbor"
+)
+
+const personSchema = `
+person = {
+	name: tstr,
+	age: uint .le 150,
+	? email: tstr .regexp "[^@]+@[^@]+",
+	? tags: [* tstr],
+	? location: [lat: float, lon: float],
+	* int => any,
+}
+`
+
+func mustParse(t *testing.T, src string) *Schema {
+	s, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestValidate(t *testing.T) {
+	s := mustParse(t, personSchema)
+	for _, v := range []interface{}{
+		map[string]interface{}{"name": "Alice", "age": 30},
+		map[string]interface{}{"name": "Bob", "age": 150, "email": "bob@example.com", "tags": []string{"a", "b"}},
+		map[string]interface{}{"name": "Carol", "age": 1, "location": []float64{1.5, -2.25}},
+		cbor.OrderedMap{{Key: "name", Value: "Dan"}, {Key: "age", Value: 2}, {Key: 1, Value: []int{1}}, {Key: -2}},
+		map[string]interface{}{"name": "Eve", "age": 3, "tags": []string{}},
+	} {
+		if err := s.Validate(mustMarshal(t, v)); err != nil {
+			t.Errorf("%v: unexpected error: %s", v, err)
+		}
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+	s := mustParse(t, personSchema)
+	for _, test := range []struct {
+		input    interface{}
+		expected []Violation
+	}{
+		{
+			map[string]interface{}{"name": "Alice"},
+			[]Violation{{"$", "missing key \"age\""}},
+		},
+		{
+			"Alice",
+			[]Violation{{"$", `expected person, found "Alice"`}},
+		},
+		{
+			map[string]interface{}{"name": 1, "age": 151},
+			[]Violation{{"$.name", "expected tstr, found 1"}, {"$.age", "151 is not .le 150"}},
+		},
+		{
+			map[string]interface{}{"name": "Alice", "age": 30, "email": "alice"},
+			[]Violation{{"$.email", `"alice" does not match "[^@]+@[^@]+"`}},
+		},
+		{
+			map[string]interface{}{"name": "Alice", "age": 30, "tags": []interface{}{"a", 1}},
+			[]Violation{{"$.tags[1]", "expected tstr, found 1"}},
+		},
+		{
+			map[string]interface{}{"name": "Alice", "age": 30, "location": []float64{1.5}},
+			[]Violation{{"$.location", "missing array element: expected float"}},
+		},
+		{
+			map[string]interface{}{"name": "Alice", "age": 30, "location": []float64{1.5, 2, 3}},
+			[]Violation{{"$.location[2]", "unexpected array element"}},
+		},
+		{
+			cbor.OrderedMap{{Key: "name", Value: "Alice"}, {Key: "age", Value: 30}, {Key: "nick", Value: "Al"}, {Key: []byte{1}, Value: 2}},
+			[]Violation{{"$.nick", "unexpected key"}, {"$[h'01']", "unexpected key"}},
+		},
+	} {
+		err := s.Validate(mustMarshal(t, test.input))
+		verr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%v: expected a *ValidationError; got %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(verr.Violations, test.expected) {
+			t.Errorf("%v: expected violations\n%v\ngot\n%v", test.input, test.expected, verr.Violations)
+		}
+	}
+}
+
+func TestValidateLongArray(t *testing.T) {
+	// Each way of splitting the array between the entries used to be tried separately, taking time cubic
+	// in its length.
+	s := mustParse(t, `a = [* int, * int, * int]`)
+	elems := make([]interface{}, 1000)
+	for i := range elems {
+		elems[i] = i
+	}
+	if err := s.Validate(mustMarshal(t, elems)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	err := s.Validate(mustMarshal(t, append(elems, "x")))
+	expected := []Violation{{"$[1000]", `expected int, found "x"`}}
+	if verr, ok := err.(*ValidationError); !ok || !reflect.DeepEqual(verr.Violations, expected) {
+		t.Errorf("expected violations %v; got %v", expected, err)
+	}
+}
+
+func TestValidateSelfReference(t *testing.T) {
+	// Each of these used to take time exponential in the depth of the recursion.
+	s := mustParse(t, `A = A .size 0 / A`)
+	if err := s.Validate(mustMarshal(t, 1)); err == nil {
+		t.Error("expected an error for a rule that only refers to itself")
+	}
+
+	s = mustParse(t, `A = [A] / [A] / int`)
+	var deep interface{} = 1
+	for i := 0; i < 500; i++ {
+		deep = []interface{}{deep}
+	}
+	if err := s.Validate(mustMarshal(t, deep)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := s.Validate(mustMarshal(t, []interface{}{deep, "x"})); err == nil {
+		t.Error("expected an error for an array of the wrong length")
+	}
+	deep = "x"
+	for i := 0; i < 500; i++ {
+		deep = []interface{}{deep}
+	}
+	if err := s.Validate(mustMarshal(t, deep)); err == nil {
+		t.Error("expected an error for a string at the bottom")
+	}
+}
+
+func TestValidateTypes(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		good   []string // hex
+		bad    []string
+	}{
+		{`a = uint`, []string{"00", "1bffffffffffffffff"}, []string{"20", "f6", "60"}},
+		{`a = int`, []string{"00", "3bffffffffffffffff"}, []string{"f93c00"}},
+		{`a = float`, []string{"f93c00", "fa3f800000", "fb3ff199999999999a"}, []string{"01"}},
+		{`a = float16`, []string{"f93c00"}, []string{"fa3f800000"}},
+		{`a = bool / null`, []string{"f4", "f5", "f6"}, []string{"f7", "00"}},
+		{`a = 1..10`, []string{"01", "0a"}, []string{"00", "0b", "fa3f800000"}},
+		{`a = 1...10`, []string{"01", "09"}, []string{"0a"}},
+		{`a = -1.5..1.5`, []string{"f93c00", "fbbff8000000000000"}, []string{"00", "fa40000000"}},
+		{`a = "x" / 'y' / h'00ff' / 3`, []string{"6178", "4179", "4200ff", "03"}, []string{"4178", "6179", "04"}},
+		{`a = tdate`, []string{"c074323031332d30332d32315432303a30343a30305a"}, []string{"c101", "74323031332d30332d32315432303a30343a30305a"}},
+		{`a = #6.32(tstr) / #6.100(uint)`, []string{"d8206161", "d86401"}, []string{"d8200a", "d8656161"}},
+		{`a = bstr .size 2`, []string{"420102"}, []string{"4101"}},
+		{`a = tstr .size (1..3)`, []string{"6161", "63616161"}, []string{"60", "6461616161"}},
+		{`a = uint .size 1`, []string{"18ff"}, []string{"190100"}},
+		{`a = bstr .cbor [uint]`, []string{"428101"}, []string{"4101", "4181"}},
+		{`a = uint .ne 0`, []string{"01"}, []string{"00"}},
+		{`a = #7.255`, []string{"f8ff"}, []string{"f7"}},
+		{`a = #2.3`, []string{"43010203", "5f4101420203ff"}, []string{"4101"}},
+		{`a = [* uint]`, []string{"80", "83010203", "9f01ff"}, []string{"8120", "a0"}},
+		{`a = [+ uint, ? tstr]`, []string{"8101", "82016161", "8301026161"}, []string{"80", "816161"}},
+		{`a = [2*3 uint]`, []string{"820102", "83010203"}, []string{"8101", "8401020304"}},
+		{`a = [*2 uint]`, []string{"80", "820102"}, []string{"83010203"}},
+		{`a = [* (uint, tstr)]`, []string{"80", "82016161", "84016161026162"}, []string{"8101", "8301616102"}},
+		{`a = [uint // tstr, tstr]`, []string{"8101", "8261616162"}, []string{"82616101"}},
+		{"a = {pair}\npair = (x: uint, y: uint)", []string{"a2617801617902"}, []string{"a1617801"}},
+		{"a = {? pair}\npair = (x: uint, y: uint)", []string{"a0", "a2617801617902"}, []string{"a1617801"}},
+		{"a = {x: uint // y: tstr}", []string{"a1617801", "a161796161"}, []string{"a161786161", "a0"}},
+		{"a = {1: uint, \"x\" => tstr, 2*3 tstr => uint}", []string{"a4010161786178616101616202"}, []string{"a2010161786178"}},
+		{"a = b / c\nb = uint\nc = tstr\nc /= bstr", []string{"01", "6161", "4161"}, []string{"f6"}},
+		{"a = {b}\nb = (x: uint)\nb //= (y: uint)", []string{"a1617801", "a1617901"}, []string{"a0"}},
+		{"a = {x: a / null}", []string{"a16178f6", "a16178a16178f6"}, []string{"a16178a0"}},
+	} {
+		s, err := Parse(test.schema)
+		if err != nil {
+			t.Errorf("%q: %s", test.schema, err)
+			continue
+		}
+		for _, h := range test.good {
+			data, err := hex.DecodeString(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Validate(data); err != nil {
+				t.Errorf("%q, %s: unexpected error: %s", test.schema, h, err)
+			}
+		}
+		for _, h := range test.bad {
+			data, err := hex.DecodeString(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := s.Validate(data).(*ValidationError); !ok {
+				t.Errorf("%q, %s: expected a *ValidationError", test.schema, h)
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		err    string
+	}{
+		{``, "no rules defined"},
+		{`a = b`, "undefined rules: b"},
+		{`a = [uint`, `expected "]"`},
+		{`a<T> = T`, "generic rules are not supported"},
+		{`a = uint .bits b`, "unsupported control operator .bits"},
+		{`a = "unterminated`, "unterminated text string"},
+		{`a /= uint`, "/= used on undefined rule a"},
+		{`a = {x: ~b}`, "the ~ operator is not supported"},
+	} {
+		_, err := Parse(test.schema)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error containing %q; got %v", test.schema, test.err, err)
+		}
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	s := mustParse(t, `a = any`)
+	for _, h := range []string{"", "1c", "8201", "0101", "ff"} {
+		data, _ := hex.DecodeString(h)
+		err := s.Validate(data)
+		if err == nil {
+			t.Errorf("%q: expected an error", h)
+			continue
+		}
+		if _, ok := err.(*ValidationError); ok {
+			t.Errorf("%q: expected a malformed-input error; got %s", h, err)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	s := mustParse(t, personSchema+`
+point = [x: int, y: int]
+`)
+	data := mustMarshal(t, cbor.OrderedMap{
+		{Key: "name", Value: "Alice"},
+		{Key: "age", Value: 30},
+		{Key: "location", Value: []float64{1.5, -2.5}},
+		{Key: 7, Value: []interface{}{-1, []byte{1}}},
+	})
+	n, err := s.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Type != "person" || len(n.Members) != 4 {
+		t.Fatalf("expected a person with 4 members; got %+v", n)
+	}
+	for i, expected := range []struct {
+		keyType string
+		key     interface{}
+		name    string
+		typ     string
+		value   interface{}
+	}{
+		{"", "name", "name", "tstr", "Alice"},
+		{"", "age", "age", "uint", uint64(30)},
+		{"", "location", "location", "", nil},
+		{"int", uint64(7), "", "any", nil},
+	} {
+		m := n.Members[i]
+		if m.Key.Type != expected.keyType || !reflect.DeepEqual(m.Key.Value, expected.key) ||
+			m.Value.Name != expected.name || m.Value.Type != expected.typ || !reflect.DeepEqual(m.Value.Value, expected.value) {
+			t.Errorf("member %d: expected %+v; got key %+v, value %+v", i, expected, m.Key, m.Value)
+		}
+	}
+	loc := n.Members[2].Value.Elements
+	if len(loc) != 2 || loc[0].Name != "lat" || loc[0].Type != "float" || loc[0].Value != 1.5 ||
+		loc[1].Name != "lon" || loc[1].Value != -2.5 {
+		t.Errorf("unexpected location elements: %+v, %+v", loc[0], loc[1])
+	}
+	other := n.Members[3].Value.Elements
+	if len(other) != 2 || other[0].Value != int64(-1) || !reflect.DeepEqual(other[1].Value, []byte{1}) {
+		t.Errorf("unexpected elements of an any: %+v", other)
+	}
+
+	p, err := s.DecodeRule("point", mustMarshal(t, []int{1, -2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "point" || p.Elements[0].Name != "x" || p.Elements[1].Name != "y" || p.Elements[1].Type != "int" {
+		t.Errorf("unexpected point: %+v", p)
+	}
+
+	if _, err := s.Decode(mustMarshal(t, "Alice")); err == nil {
+		t.Error("expected an error decoding data that doesn't match")
+	}
+}
+
+func TestDecodeRepeatedGroups(t *testing.T) {
+	s := mustParse(t, `pairs = [* (k: tstr, v: uint), ? trailer: bool]`)
+	n, err := s.Decode(mustMarshal(t, []interface{}{"a", 1, "b", 2, true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, elem := range n.Elements {
+		names = append(names, elem.Name)
+	}
+	if expected := []string{"k", "v", "k", "v", "trailer"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected element names %q; got %q", expected, names)
+	}
+}
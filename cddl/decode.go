@@ -0,0 +1,207 @@
+package cddl
+
+import (
+	"math"
+	"math/big"
+)
+
+// A Node is a CBOR data item decoded under the guidance of a schema, annotated with the names the schema
+// gives it.
+type Node struct {
+	// Type is the name of the outermost rule (or prelude type) that the item matched, such as "person" or
+	// "uint". It is empty for items matched by an anonymous type, such as the elements of an array of any.
+	Type string
+	// Name is the member key name of the group entry that matched the item, for array elements and map
+	// values; for example, "lat" for the first element of [lat: float, lon: float].
+	Name string
+
+	// Value holds the item itself for everything except arrays, maps, and tags: a uint64 (for unsigned
+	// integers), int64 or *big.Int (for negative integers, depending on their size), float64, string,
+	// []byte, bool, nil (for null and undefined), or Simple.
+	Value interface{}
+	// Elements holds the elements of an array.
+	Elements []*Node
+	// Members holds the entries of a map, in the order they appear.
+	Members []Member
+	// Tag and Content hold a tag number and the item it tags. Content is nil for untagged items.
+	Tag     uint64
+	Content *Node
+}
+
+// A Member is one key/value pair of a map.
+type Member struct {
+	Key   *Node
+	Value *Node
+}
+
+// Simple is a simple value other than false, true, null, and undefined.
+type Simple uint8
+
+// Decode validates data against the schema's root rule, as with Validate, and decodes it into a tree of
+// Nodes labeled with the schema's rule and member names.
+func (s *Schema) Decode(data []byte) (*Node, error) {
+	return s.DecodeRule(s.root, data)
+}
+
+// DecodeRule is like Decode but uses the named rule.
+func (s *Schema) DecodeRule(rule string, data []byte) (*Node, error) {
+	it, v, err := s.validate(rule, data)
+	if err != nil {
+		return nil, err
+	}
+	return v.annotate(&typ{kind: kindRef, name: rule}, it), nil
+}
+
+func (v *validator) matches(t *typ, it *item) bool {
+	viols, _ := v.match(t, it, "")
+	return len(viols) == 0
+}
+
+// annotate builds the Node for it, which is known to match t.
+func (v *validator) annotate(t *typ, it *item) *Node {
+	switch t.kind {
+	case kindRef:
+		n := v.annotate(v.s.rules[t.name], it)
+		n.Type = t.name
+		return n
+	case kindChoice:
+		for _, c := range t.choices {
+			if v.matches(c, it) {
+				return v.annotate(c, it)
+			}
+		}
+	case kindTag:
+		return &Node{Tag: it.arg, Content: v.annotate(t.hi, it.items[0])}
+	case kindControl:
+		return v.annotate(t.lo, it)
+	case kindArray:
+		if entries := v.assignArray(t.group, it.items); entries != nil {
+			n := &Node{Elements: make([]*Node, len(it.items))}
+			for i, e := range entries {
+				n.Elements[i] = v.annotate(e.typ, it.items[i])
+				n.Elements[i].Name = e.name
+			}
+			return n
+		}
+	case kindMap:
+		for _, entries := range v.mapAlternatives(t.group.choices) {
+			assigned := make([]*entry, len(it.items)/2)
+			if len(v.matchMapEntries(entries, it.items, "", assigned)) > 0 {
+				continue
+			}
+			n := &Node{Members: make([]Member, len(assigned))}
+			for i, e := range assigned {
+				n.Members[i] = Member{v.annotate(e.key, it.items[2*i]), v.annotate(e.typ, it.items[2*i+1])}
+				n.Members[i].Value.Name = e.name
+			}
+			return n
+		}
+	}
+	return plainNode(it)
+}
+
+// plainNode builds a Node for it without any schema information.
+func plainNode(it *item) *Node {
+	n := &Node{}
+	switch it.major {
+	case majorUint:
+		n.Value = it.arg
+	case majorNint:
+		if it.arg <= math.MaxInt64 {
+			n.Value = -1 - int64(it.arg)
+		} else {
+			b := new(big.Int).SetUint64(it.arg)
+			n.Value = b.Neg(b.Add(b, big.NewInt(1)))
+		}
+	case majorBytes:
+		n.Value = it.data
+	case majorText:
+		n.Value = string(it.data)
+	case majorArray:
+		n.Elements = make([]*Node, len(it.items))
+		for i, elem := range it.items {
+			n.Elements[i] = plainNode(elem)
+		}
+	case majorMap:
+		n.Members = make([]Member, len(it.items)/2)
+		for i := range n.Members {
+			n.Members[i] = Member{plainNode(it.items[2*i]), plainNode(it.items[2*i+1])}
+		}
+	case majorTag:
+		n.Tag = it.arg
+		n.Content = plainNode(it.items[0])
+	case majorSimple:
+		switch {
+		case it.isFloat():
+			n.Value = it.float()
+		case it.info == 20 || it.info == 21:
+			n.Value = it.info == 21
+		case it.info == 22 || it.info == 23:
+		default:
+			n.Value = Simple(majorArg(it))
+		}
+	}
+	return n
+}
+
+// assignArray finds the group entry that matches each element of items, returning nil if there's no way
+// for items to match g.
+func (v *validator) assignArray(g *group, items []*item) []*entry {
+	var result []*entry
+	for _, entries := range g.choices {
+		if v.assignEntries(entries, items, 0, nil, func(pos int, acc []*entry) bool {
+			if pos != len(items) {
+				return false
+			}
+			result = acc
+			return true
+		}) {
+			return result
+		}
+	}
+	return nil
+}
+
+// assignEntries matches entries against items starting at pos, appending the entry matched by each item to
+// acc, and calls done with the resulting position and assignments. It backtracks through the possible
+// matches until done returns true.
+func (v *validator) assignEntries(entries []*entry, items []*item, pos int, acc []*entry, done func(int, []*entry) bool) bool {
+	if len(entries) == 0 {
+		return done(pos, acc)
+	}
+	return v.assignRepeated(entries[0], 0, items, pos, acc, func(pos int, acc []*entry) bool {
+		return v.assignEntries(entries[1:], items, pos, acc, done)
+	})
+}
+
+// assignRepeated matches further repetitions of e (which has matched n times so far), preferring more.
+func (v *validator) assignRepeated(e *entry, n int, items []*item, pos int, acc []*entry, done func(int, []*entry) bool) bool {
+	if e.max < 0 || n < e.max {
+		more := v.assignOnce(e, items, pos, acc, func(next int, acc []*entry) bool {
+			// Repetitions that consume nothing can only help to reach the minimum count.
+			if next == pos && n >= e.min {
+				return false
+			}
+			return v.assignRepeated(e, n+1, items, next, acc, done)
+		})
+		if more {
+			return true
+		}
+	}
+	return n >= e.min && done(pos, acc)
+}
+
+func (v *validator) assignOnce(e *entry, items []*item, pos int, acc []*entry, done func(int, []*entry) bool) bool {
+	if g := v.entryGroup(e); g != nil {
+		for _, entries := range g.choices {
+			if v.assignEntries(entries, items, pos, acc, done) {
+				return true
+			}
+		}
+		return false
+	}
+	if pos < len(items) && v.matches(e.typ, items[pos]) {
+		return done(pos+1, append(acc[:len(acc):len(acc)], e))
+	}
+	return false
+}
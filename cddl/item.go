@@ -0,0 +1,276 @@
+package cddl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Major types
+const (
+	majorUint = iota
+	majorNint
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// maxDepth bounds the nesting of the CBOR data (and of rule references during validation).
+const maxDepth = 1000
+
+// An item is a decoded CBOR data item. Schema literals are represented as items as well.
+type item struct {
+	major byte
+	info  byte    // the additional information; for major type 7 this tells floats apart from simple values
+	arg   uint64  // the argument for major types 0, 1, 6, and 7 (the bits of a float)
+	data  []byte  // the contents of a byte or text string, with any chunks joined
+	items []*item // array elements; map keys and values, alternating; or a tag's content
+}
+
+func (it *item) isFloat() bool {
+	return it.major == majorSimple && it.info >= 25 && it.info <= 27
+}
+
+func (it *item) isInt() bool {
+	return it.major == majorUint || it.major == majorNint
+}
+
+// float returns the value of a float item.
+func (it *item) float() float64 {
+	switch it.info {
+	case 25:
+		return float16ToFloat64(uint16(it.arg))
+	case 26:
+		return float64(math.Float32frombits(uint32(it.arg)))
+	}
+	return math.Float64frombits(it.arg)
+}
+
+// number returns the numeric value of an integer or float item, and whether it is numeric.
+func (it *item) number() (*big.Float, bool) {
+	switch {
+	case it.major == majorUint:
+		return new(big.Float).SetUint64(it.arg), true
+	case it.major == majorNint:
+		f := new(big.Float).SetUint64(it.arg)
+		return f.Neg(f.Add(f, big.NewFloat(1))), true
+	case it.isFloat():
+		f := it.float()
+		if math.IsNaN(f) {
+			return nil, false
+		}
+		return big.NewFloat(f), true
+	}
+	return nil, false
+}
+
+// equal reports whether a and b are the same value. Integers and floats are never equal to each other, but
+// floats of different widths are compared by value.
+func equal(a, b *item) bool {
+	if a.isFloat() && b.isFloat() {
+		return a.float() == b.float()
+	}
+	if a.major != b.major || len(a.items) != len(b.items) {
+		return false
+	}
+	switch a.major {
+	case majorBytes, majorText:
+		return bytes.Equal(a.data, b.data)
+	case majorSimple:
+		return a.info == b.info && a.arg == b.arg
+	}
+	if a.arg != b.arg {
+		return false
+	}
+	for i := range a.items {
+		if !equal(a.items[i], b.items[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1F
+	mant := float64(h & 0x3FF)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1F:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
+
+// String returns the item in (a compact form of) CBOR diagnostic notation, for error messages.
+func (it *item) String() string {
+	switch it.major {
+	case majorUint:
+		return fmt.Sprint(it.arg)
+	case majorNint:
+		n := new(big.Int).SetUint64(it.arg)
+		return n.Neg(n.Add(n, big.NewInt(1))).String()
+	case majorBytes:
+		return fmt.Sprintf("h'%x'", it.data)
+	case majorText:
+		return fmt.Sprintf("%q", it.data)
+	case majorArray:
+		return "[...]"
+	case majorMap:
+		return "{...}"
+	case majorTag:
+		return fmt.Sprintf("%d(%s)", it.arg, it.items[0])
+	}
+	if it.isFloat() {
+		return fmt.Sprint(it.float())
+	}
+	switch it.info {
+	case 20:
+		return "false"
+	case 21:
+		return "true"
+	case 22:
+		return "null"
+	case 23:
+		return "undefined"
+	}
+	return fmt.Sprintf("simple(%d)", it.arg)
+}
+
+var errTruncated = errors.New("unexpected end of input")
+
+// itemParser decodes CBOR into items.
+type itemParser struct {
+	data  []byte
+	off   int
+	depth int
+}
+
+// parseItem decodes the single CBOR data item in data.
+func parseItem(data []byte) (*item, error) {
+	p := &itemParser{data: data}
+	it, err := p.parse()
+	if err == nil && p.off != len(data) {
+		err = errors.New("trailing data after top-level item")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cddl: malformed CBOR at offset %d: %s", p.off, err)
+	}
+	return it, nil
+}
+
+func (p *itemParser) header() (major, info byte, arg uint64, err error) {
+	if p.off >= len(p.data) {
+		return 0, 0, 0, errTruncated
+	}
+	b := p.data[p.off]
+	major, info = b>>5, b&0x1F
+	switch {
+	case info < 24:
+		p.off++
+		return major, info, uint64(info), nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(p.data)-p.off-1 < n {
+			return 0, 0, 0, errTruncated
+		}
+		for _, c := range p.data[p.off+1 : p.off+1+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		p.off += 1 + n
+		return major, info, arg, nil
+	case info == 31 && major >= majorBytes && major <= majorMap, info == 31 && major == majorSimple:
+		p.off++
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("invalid additional information %d for major type %d", info, major)
+}
+
+func (p *itemParser) atBreak() bool {
+	return p.off < len(p.data) && p.data[p.off] == 0xFF
+}
+
+func (p *itemParser) parse() (*item, error) {
+	major, info, arg, err := p.header()
+	if err != nil {
+		return nil, err
+	}
+	it := &item{major: major, info: info, arg: arg}
+	indefinite := info == 31
+	switch major {
+	case majorBytes, majorText:
+		if !indefinite {
+			if uint64(len(p.data)-p.off) < arg {
+				return nil, errTruncated
+			}
+			it.data = p.data[p.off : p.off+int(arg)]
+			p.off += int(arg)
+			return it, nil
+		}
+		it.data = []byte{}
+		for !p.atBreak() {
+			chunk, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			if chunk.major != major || chunk.info == 31 {
+				return nil, errors.New("invalid chunk in indefinite-length string")
+			}
+			it.data = append(it.data, chunk.data...)
+		}
+		p.off++
+		return it, nil
+	case majorArray, majorMap, majorTag:
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, errors.New("nesting too deep")
+		}
+	}
+	switch major {
+	case majorArray, majorMap:
+		n := arg
+		if major == majorMap {
+			n *= 2
+		}
+		if !indefinite && arg > uint64(len(p.data)) {
+			return nil, errTruncated
+		}
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite && p.atBreak() && (major == majorArray || i%2 == 0) {
+				p.off++
+				break
+			}
+			elem, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			it.items = append(it.items, elem)
+		}
+		return it, nil
+	case majorTag:
+		content, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		it.items = []*item{content}
+		return it, nil
+	case majorSimple:
+		if indefinite {
+			return nil, errors.New("unexpected break")
+		}
+	}
+	return it, nil
+}
@@ -0,0 +1,179 @@
+package cddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota
+	tokIdent             // rule and member names
+	tokNumber            // integer and float literals
+	tokText              // "text"
+	tokBytes             // h'0102', '...', b64'...'
+	tokHash              // #, #6, #6.32 (the digits are in s)
+	tokControl           // .size, .regexp, ... (the name, without the dot, is in s)
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	s    string
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokText:
+		return fmt.Sprintf("text %s", t.s)
+	case tokHash:
+		return "#" + t.s
+	case tokControl:
+		return "." + t.s
+	}
+	return fmt.Sprintf("%q", t.s)
+}
+
+// punctuation lists the multi-character punctuation tokens before their prefixes so that lexing is greedy.
+var punctuation = []string{
+	"//=", "/=", "//", "=>", "...", "..",
+	"=", "/", "(", ")", "[", "]", "{", "}", "<", ">", ",", ":", "?", "*", "+", "~", "&", "^",
+}
+
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '@' || c == '_' || c == '$'
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// lex splits src into tokens, dropping whitespace and comments.
+func lex(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == ';':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case isAlpha(c):
+			i++
+			for i < len(src) && (isAlpha(src[i]) || isDigit(src[i]) || src[i] == '-' || src[i] == '.') {
+				i++
+			}
+			// Names can't end in a dash or dot.
+			for src[i-1] == '-' || src[i-1] == '.' {
+				i--
+			}
+			// Quoted bytes with an encoding prefix.
+			if i < len(src) && src[i] == '\'' && (src[start:i] == "h" || src[start:i] == "b64") {
+				end := strings.IndexByte(src[i+1:], '\'')
+				if end < 0 {
+					return nil, fmt.Errorf("cddl: line %d: unterminated byte string", line)
+				}
+				i += end + 2
+				toks = append(toks, token{tokBytes, src[start:i], line})
+				continue
+			}
+			toks = append(toks, token{tokIdent, src[start:i], line})
+			continue
+		case isDigit(c) || c == '-' && i+1 < len(src) && isDigit(src[i+1]):
+			i++
+			if c == '0' && i < len(src) && (src[i] == 'x' || src[i] == 'b') {
+				i++
+				for i < len(src) && isHexDigit(src[i]) {
+					i++
+				}
+			} else {
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+				// A dot starts a fraction only if a digit follows; otherwise it's a range operator.
+				if i+1 < len(src) && src[i] == '.' && isDigit(src[i+1]) {
+					i++
+					for i < len(src) && isDigit(src[i]) {
+						i++
+					}
+				}
+				if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+					i++
+					if i < len(src) && (src[i] == '+' || src[i] == '-') {
+						i++
+					}
+					for i < len(src) && isDigit(src[i]) {
+						i++
+					}
+				}
+			}
+			toks = append(toks, token{tokNumber, src[start:i], line})
+			continue
+		case c == '"':
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("cddl: line %d: unterminated text string", line)
+			}
+			i++
+			toks = append(toks, token{tokText, src[start:i], line})
+			continue
+		case c == '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("cddl: line %d: unterminated byte string", line)
+			}
+			i += end + 2
+			toks = append(toks, token{tokBytes, src[start:i], line})
+			continue
+		case c == '#':
+			i++
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.' && i+1 < len(src) && isDigit(src[i+1])) {
+				i++
+			}
+			toks = append(toks, token{tokHash, src[start+1 : i], line})
+			continue
+		case c == '.' && i+1 < len(src) && isAlpha(src[i+1]):
+			i++
+			for i < len(src) && (isAlpha(src[i]) || isDigit(src[i]) || src[i] == '-') {
+				i++
+			}
+			toks = append(toks, token{tokControl, src[start+1 : i], line})
+			continue
+		}
+		found := false
+		for _, p := range punctuation {
+			if strings.HasPrefix(src[i:], p) {
+				toks = append(toks, token{tokPunct, p, line})
+				i += len(p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("cddl: line %d: unexpected character %q", line, c)
+		}
+	}
+	return append(toks, token{tokEOF, "", line}), nil
+}
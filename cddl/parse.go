@@ -0,0 +1,455 @@
+package cddl
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindAny     kind = iota // #: any item
+	kindRef                 // a reference to a rule
+	kindLiteral             // a single value
+	kindMajor               // #n or #n.m: any item of a major type, optionally with a given argument
+	kindTag                 // #6.n(type)
+	kindChoice              // type / type
+	kindRange               // lo..hi or lo...hi
+	kindControl             // type .op type
+	kindArray               // [ group ]
+	kindMap                 // { group }
+	kindGroup               // ( group ), when used as a group rather than parenthesizing a type
+)
+
+// A typ is a parsed CDDL type (or group).
+type typ struct {
+	kind    kind
+	name    string  // kindRef: the rule name; kindControl: the operator
+	lit     *item   // kindLiteral
+	major   byte    // kindMajor
+	arg     *uint64 // kindMajor (if the argument is constrained), kindTag
+	choices []*typ  // kindChoice
+	lo, hi  *typ    // kindRange; kindControl (target and controller); kindTag (hi is the content)
+	incl    bool    // kindRange: whether hi is included (..) or not (...)
+	group   *group  // kindArray, kindMap, kindGroup
+}
+
+// A group is a list of group choices, each a sequence of entries.
+type group struct {
+	choices [][]*entry
+}
+
+// An entry is one entry of a group, with its occurrence indicator.
+type entry struct {
+	min, max int  // max < 0 means unbounded
+	key      *typ // nil if the entry has no member key
+	name     string
+	typ      *typ
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+n]
+}
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.s == s
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("cddl: line %d: %s", p.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected %q but found %s", s, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+// parseRules parses a list of rules, calling add for each.
+func (p *parser) parseRules(add func(name, op string, t *typ) error) error {
+	for p.peek().kind != tokEOF {
+		name := p.next()
+		if name.kind != tokIdent {
+			return fmt.Errorf("cddl: line %d: expected a rule name but found %s", name.line, name)
+		}
+		if p.isPunct("<") {
+			return p.errorf("generic rules are not supported")
+		}
+		op := p.next()
+		if op.kind != tokPunct || op.s != "=" && op.s != "/=" && op.s != "//=" {
+			return fmt.Errorf("cddl: line %d: expected an assignment after %s but found %s", op.line, name.s, op)
+		}
+		var t *typ
+		var err error
+		if op.s == "//=" {
+			var g *group
+			g, err = p.parseGroup()
+			t = &typ{kind: kindGroup, group: g}
+		} else {
+			t, err = p.parseRuleBody()
+		}
+		if err != nil {
+			return err
+		}
+		if err := add(name.s, op.s, t); err != nil {
+			return fmt.Errorf("cddl: line %d: %s", name.line, err)
+		}
+	}
+	return nil
+}
+
+// parseRuleBody parses the right-hand side of a rule, which is either a type or a group entry (such as
+// "a = (b: int, c: tstr)" or "a = b: int").
+func (p *parser) parseRuleBody() (*typ, error) {
+	start := p.pos
+	t, err := p.parseType()
+	if err == nil && !p.startsEntry() {
+		return t, nil
+	}
+	p.pos = start
+	e, err := p.parseEntry()
+	if err != nil {
+		return nil, err
+	}
+	if e.key == nil && e.min == 1 && e.max == 1 {
+		return e.typ, nil
+	}
+	return &typ{kind: kindGroup, group: &group{choices: [][]*entry{{e}}}}, nil
+}
+
+// startsEntry reports whether the next token continues a group entry rather than starting a new rule.
+func (p *parser) startsEntry() bool {
+	return p.isPunct(":") || p.isPunct("=>") || p.isPunct("^")
+}
+
+// parseType parses type1 *("/" type1).
+func (p *parser) parseType() (*typ, error) {
+	t, err := p.parseType1()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct("/") {
+		return t, nil
+	}
+	choice := &typ{kind: kindChoice, choices: []*typ{t}}
+	for p.isPunct("/") {
+		p.next()
+		t, err := p.parseType1()
+		if err != nil {
+			return nil, err
+		}
+		choice.choices = append(choice.choices, t)
+	}
+	return choice, nil
+}
+
+// parseType1 parses a type2 optionally followed by a range or control operator.
+func (p *parser) parseType1() (*typ, error) {
+	t, err := p.parseType2()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isPunct("..") || p.isPunct("..."):
+		incl := p.next().s == ".."
+		hi, err := p.parseType2()
+		if err != nil {
+			return nil, err
+		}
+		return &typ{kind: kindRange, lo: t, hi: hi, incl: incl}, nil
+	case p.peek().kind == tokControl:
+		op := p.next().s
+		if _, ok := controls[op]; !ok {
+			return nil, p.errorf("unsupported control operator .%s", op)
+		}
+		arg, err := p.parseType2()
+		if err != nil {
+			return nil, err
+		}
+		return &typ{kind: kindControl, name: op, lo: t, hi: arg}, nil
+	}
+	return t, nil
+}
+
+func (p *parser) parseType2() (*typ, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber, tokText, tokBytes:
+		p.next()
+		lit, err := parseLiteral(t)
+		if err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		return &typ{kind: kindLiteral, lit: lit}, nil
+	case tokIdent:
+		p.next()
+		if p.isPunct("<") {
+			return nil, p.errorf("generic arguments are not supported")
+		}
+		return &typ{kind: kindRef, name: t.s}, nil
+	case tokHash:
+		p.next()
+		return p.parseHash(t.s)
+	case tokPunct:
+		switch t.s {
+		case "(":
+			p.next()
+			g, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			// A parenthesized single type is just that type.
+			if len(g.choices) == 1 && len(g.choices[0]) == 1 {
+				if e := g.choices[0][0]; e.key == nil && e.min == 1 && e.max == 1 {
+					return e.typ, nil
+				}
+			}
+			return &typ{kind: kindGroup, group: g}, nil
+		case "[", "{":
+			p.next()
+			g, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			if t.s == "[" {
+				return &typ{kind: kindArray, group: g}, p.expect("]")
+			}
+			return &typ{kind: kindMap, group: g}, p.expect("}")
+		case "~", "&":
+			return nil, p.errorf("the %s operator is not supported", t.s)
+		}
+	}
+	return nil, p.errorf("expected a type but found %s", t)
+}
+
+// parseHash parses the rest of #, #n, #n.m, or #6.n(type).
+func (p *parser) parseHash(s string) (*typ, error) {
+	if s == "" {
+		return &typ{kind: kindAny}, nil
+	}
+	majorStr, argStr, hasArg := strings.Cut(s, ".")
+	major, err := strconv.ParseUint(majorStr, 10, 8)
+	if err != nil || major > 7 {
+		return nil, p.errorf("invalid major type in #%s", s)
+	}
+	t := &typ{kind: kindMajor, major: byte(major)}
+	if hasArg {
+		arg, err := strconv.ParseUint(argStr, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid argument in #%s", s)
+		}
+		t.arg = &arg
+	}
+	if major != majorTag || !p.isPunct("(") {
+		return t, nil
+	}
+	if !hasArg {
+		return nil, p.errorf("a tag type needs a tag number")
+	}
+	p.next()
+	content, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &typ{kind: kindTag, arg: t.arg, hi: content}, nil
+}
+
+// parseGroup parses group choices separated by "//" up to (not including) a closing bracket or the end of a
+// rule.
+func (p *parser) parseGroup() (*group, error) {
+	g := &group{}
+	var entries []*entry
+	for {
+		switch {
+		case p.isPunct(")") || p.isPunct("]") || p.isPunct("}") || p.peek().kind == tokEOF || p.atRuleStart():
+			g.choices = append(g.choices, entries)
+			return g, nil
+		case p.isPunct("//"):
+			p.next()
+			g.choices = append(g.choices, entries)
+			entries = nil
+			continue
+		case p.isPunct(","):
+			p.next()
+			continue
+		}
+		e, err := p.parseEntry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// atRuleStart reports whether the upcoming tokens begin a new rule ("name =").
+func (p *parser) atRuleStart() bool {
+	next := p.peekAt(1)
+	return p.peek().kind == tokIdent && next.kind == tokPunct &&
+		(next.s == "=" || next.s == "/=" || next.s == "//=" || next.s == "<")
+}
+
+// parseEntry parses [occurrence] [memberkey] type.
+func (p *parser) parseEntry() (*entry, error) {
+	e := &entry{min: 1, max: 1}
+	switch {
+	case p.isPunct("?"):
+		p.next()
+		e.min, e.max = 0, 1
+	case p.isPunct("+"):
+		p.next()
+		e.min, e.max = 1, -1
+	case p.isPunct("*"):
+		p.next()
+		e.min, e.max = 0, -1
+		if p.peek().kind == tokNumber {
+			n, err := strconv.Atoi(p.next().s)
+			if err != nil {
+				return nil, p.errorf("invalid occurrence")
+			}
+			e.max = n
+		}
+	case p.peek().kind == tokNumber && p.peekAt(1).kind == tokPunct && p.peekAt(1).s == "*":
+		n, err := strconv.Atoi(p.next().s)
+		if err != nil {
+			return nil, p.errorf("invalid occurrence")
+		}
+		p.next()
+		e.min, e.max = n, -1
+		if p.peek().kind == tokNumber {
+			m, err := strconv.Atoi(p.next().s)
+			if err != nil {
+				return nil, p.errorf("invalid occurrence")
+			}
+			e.max = m
+		}
+	}
+
+	// A bare name or a literal followed by a colon is a member key.
+	if t := p.peek(); (t.kind == tokIdent || t.kind == tokNumber || t.kind == tokText || t.kind == tokBytes) &&
+		p.peekAt(1).kind == tokPunct && p.peekAt(1).s == ":" {
+		p.next()
+		p.next()
+		if t.kind == tokIdent {
+			e.key = &typ{kind: kindLiteral, lit: &item{major: majorText, data: []byte(t.s)}}
+			e.name = t.s
+		} else {
+			lit, err := parseLiteral(t)
+			if err != nil {
+				return nil, p.errorf("%s", err)
+			}
+			e.key = &typ{kind: kindLiteral, lit: lit}
+			e.name = lit.String()
+		}
+		var err error
+		e.typ, err = p.parseType()
+		return e, err
+	}
+
+	t, err := p.parseType1()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("^") {
+		p.next()
+	}
+	if p.isPunct("=>") {
+		p.next()
+		e.key = t
+		if t.kind == kindLiteral {
+			e.name = t.lit.String()
+		}
+		e.typ, err = p.parseType()
+		return e, err
+	}
+	e.typ = t
+	if p.isPunct("/") {
+		e.typ = &typ{kind: kindChoice, choices: []*typ{t}}
+		for p.isPunct("/") {
+			p.next()
+			t, err := p.parseType1()
+			if err != nil {
+				return nil, err
+			}
+			e.typ.choices = append(e.typ.choices, t)
+		}
+	}
+	return e, nil
+}
+
+// parseLiteral converts a number, text, or bytes token into an item.
+func parseLiteral(t token) (*item, error) {
+	switch t.kind {
+	case tokText:
+		s, err := strconv.Unquote(t.s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid text literal %s", t.s)
+		}
+		return &item{major: majorText, data: []byte(s)}, nil
+	case tokBytes:
+		prefix, quoted, _ := strings.Cut(t.s, "'")
+		quoted = strings.TrimSuffix(quoted, "'")
+		var b []byte
+		var err error
+		switch prefix {
+		case "":
+			b = []byte(quoted)
+		case "h":
+			b, err = hex.DecodeString(strings.Join(strings.Fields(quoted), ""))
+		case "b64":
+			b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(quoted, "="))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte string literal %s", t.s)
+		}
+		return &item{major: majorBytes, data: b}, nil
+	}
+	s := t.s
+	if !strings.ContainsAny(s, ".eE") || strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "-0x") {
+		neg := strings.HasPrefix(s, "-")
+		n, err := strconv.ParseUint(strings.TrimPrefix(s, "-"), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer literal %s", s)
+		}
+		if neg {
+			if n == 0 {
+				return &item{major: majorUint}, nil
+			}
+			return &item{major: majorNint, arg: n - 1}, nil
+		}
+		return &item{major: majorUint, arg: n}, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid float literal %s", s)
+	}
+	return &item{major: majorSimple, info: 27, arg: math.Float64bits(f)}, nil
+}
@@ -0,0 +1,148 @@
+// Package cddl parses schemas written in the Concise Data Definition Language (CDDL, RFC 8610) and validates
+// CBOR data against them. Data can also be decoded into a generic tree labeled with the schema's names, for
+// tools that work with schemas only known at runtime.
+//
+// A useful subset of CDDL is supported: the standard prelude, type and group choices, ranges, literals,
+// arrays and maps with occurrence indicators and member keys, group rules and inline groups, tags (#6.n),
+// major types (#n, #n.m), and the control operators .size, .regexp, .lt, .le, .gt, .ge, .eq, .ne, .default,
+// .and, .within, and .cbor. Generic rules and the ~ and & operators are not supported.
+package cddl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Schema is a parsed set of CDDL rules.
+type Schema struct {
+	rules map[string]*typ
+	root  string // the first rule defined
+}
+
+// prelude is the standard CDDL prelude (RFC 8610, Appendix D).
+const prelude = `
+any = #
+uint = #0
+nint = #1
+int = uint / nint
+bstr = #2
+bytes = bstr
+tstr = #3
+text = tstr
+tdate = #6.0(tstr)
+time = #6.1(number)
+number = int / float
+biguint = #6.2(bstr)
+bignint = #6.3(bstr)
+bigint = biguint / bignint
+integer = int / bigint
+unsigned = uint / biguint
+decfrac = #6.4([e10: int, m: integer])
+bigfloat = #6.5([e2: int, m: integer])
+eb64url = #6.21(any)
+eb64legacy = #6.22(any)
+eb16 = #6.23(any)
+encoded-cbor = #6.24(bstr)
+uri = #6.32(tstr)
+b64url = #6.33(tstr)
+b64legacy = #6.34(tstr)
+regexp = #6.35(tstr)
+mime-message = #6.36(tstr)
+cbor-any = #6.55799(any)
+float16 = #7.25
+float32 = #7.26
+float64 = #7.27
+float16-32 = float16 / float32
+float32-64 = float32 / float64
+float = float16-32 / float64
+false = #7.20
+true = #7.21
+bool = false / true
+nil = #7.22
+null = nil
+undefined = #7.23
+`
+
+// Parse parses the CDDL rules in src. The first rule defined is the root rule, used by Validate.
+func Parse(src string) (*Schema, error) {
+	s := &Schema{rules: make(map[string]*typ)}
+	if err := s.parse(prelude, false); err != nil {
+		panic(err) // The prelude is fixed, so this can't happen.
+	}
+	if err := s.parse(src, true); err != nil {
+		return nil, err
+	}
+	if s.root == "" {
+		return nil, fmt.Errorf("cddl: no rules defined")
+	}
+	var undefined []string
+	seen := make(map[string]bool)
+	for _, t := range s.rules {
+		walk(t, func(t *typ) {
+			if t.kind == kindRef && s.rules[t.name] == nil && !seen[t.name] {
+				seen[t.name] = true
+				undefined = append(undefined, t.name)
+			}
+		})
+	}
+	if len(undefined) > 0 {
+		sort.Strings(undefined)
+		return nil, fmt.Errorf("cddl: undefined rules: %s", strings.Join(undefined, ", "))
+	}
+	return s, nil
+}
+
+func (s *Schema) parse(src string, setRoot bool) error {
+	toks, err := lex(src)
+	if err != nil {
+		return err
+	}
+	p := &parser{toks: toks}
+	return p.parseRules(func(name, op string, t *typ) error {
+		prev := s.rules[name]
+		switch op {
+		case "=":
+			if setRoot && s.root == "" {
+				s.root = name
+			}
+			s.rules[name] = t
+		case "/=":
+			if prev == nil {
+				return fmt.Errorf("/= used on undefined rule %s", name)
+			}
+			if prev.kind == kindChoice {
+				prev.choices = append(prev.choices, t)
+			} else {
+				s.rules[name] = &typ{kind: kindChoice, choices: []*typ{prev, t}}
+			}
+		case "//=":
+			if prev == nil || prev.kind != kindGroup {
+				return fmt.Errorf("//= used on %s, which is not a group rule", name)
+			}
+			prev.group.choices = append(prev.group.choices, t.group.choices...)
+		}
+		return nil
+	})
+}
+
+// walk calls fn for t and each type nested within it.
+func walk(t *typ, fn func(*typ)) {
+	if t == nil {
+		return
+	}
+	fn(t)
+	for _, c := range t.choices {
+		walk(c, fn)
+	}
+	walk(t.lo, fn)
+	walk(t.hi, fn)
+	if t.group != nil {
+		for _, entries := range t.group.choices {
+			for _, e := range entries {
+				walk(e.key, fn)
+				walk(e.typ, fn)
+			}
+		}
+	}
+}
@@ -0,0 +1,651 @@
+package cddl
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// A Violation describes one way in which data fails to match a schema.
+type Violation struct {
+	Path    string // The location of the offending value, such as $.items[2].name
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// A ValidationError is returned when data is well-formed CBOR but doesn't match a schema.
+type ValidationError struct {
+	Rule       string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return fmt.Sprintf("cddl: data does not match %s: %s", e.Rule, strings.Join(parts, "; "))
+}
+
+// Validate checks that data holds a single CBOR item that matches the schema's root rule. If data is not
+// well-formed CBOR, an error describing that is returned; otherwise, if it doesn't match, the error is a
+// *ValidationError.
+func (s *Schema) Validate(data []byte) error {
+	return s.ValidateRule(s.root, data)
+}
+
+// ValidateRule is like Validate but checks data against the named rule.
+func (s *Schema) ValidateRule(rule string, data []byte) error {
+	_, _, err := s.validate(rule, data)
+	return err
+}
+
+// validate parses data and checks it against the named rule, returning the parsed item and the validator
+// for further use.
+func (s *Schema) validate(rule string, data []byte) (*item, *validator, error) {
+	if s.rules[rule] == nil {
+		return nil, nil, fmt.Errorf("cddl: no rule named %s", rule)
+	}
+	it, err := parseItem(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := &validator{s: s}
+	if viols, _ := v.match(&typ{kind: kindRef, name: rule}, it, "$"); len(viols) > 0 {
+		return nil, nil, &ValidationError{rule, viols}
+	}
+	return it, v, nil
+}
+
+type validator struct {
+	s       *Schema
+	depth   int
+	regexps map[string]*regexp.Regexp
+	// The results of matching items against rules, so that a rule reached along several paths (such as
+	// through the alternatives of a choice) is only matched against each item once.
+	refs map[refKey]*refResult
+}
+
+type refKey struct {
+	rule string
+	it   *item
+}
+
+// A refResult is the result of matching an item against a rule, or nil viols and done unset while the
+// match is in progress.
+type refResult struct {
+	done    bool
+	viols   []Violation
+	shallow bool
+}
+
+// mismatch returns a violation for an item that isn't of the expected type.
+func mismatch(path string, expected string, it *item) []Violation {
+	found := it.String()
+	if len(found) > 40 {
+		found = found[:37] + "..."
+	}
+	return []Violation{{path, fmt.Sprintf("expected %s, found %s", expected, found)}}
+}
+
+// match checks it against t. It returns the violations found (none if it matches) and whether the failure
+// is shallow: that is, it is a plain type mismatch of it itself, which the caller may describe better.
+func (v *validator) match(t *typ, it *item, path string) (viols []Violation, shallow bool) {
+	switch t.kind {
+	case kindAny:
+		return nil, false
+	case kindRef:
+		v.depth++
+		defer func() { v.depth-- }()
+		if v.depth > maxDepth {
+			return []Violation{{path, "rule references nested too deeply"}}, false
+		}
+		rule := v.s.rules[t.name]
+		if rule.kind == kindGroup {
+			return []Violation{{path, fmt.Sprintf("group %s used as a type", t.name)}}, false
+		}
+		key := refKey{t.name, it}
+		if r, ok := v.refs[key]; ok {
+			if !r.done {
+				// Going around again would never get anywhere (and could take exponential time).
+				return []Violation{{path, fmt.Sprintf("rule %s refers to itself without consuming any data", t.name)}}, false
+			}
+			return r.viols, r.shallow
+		}
+		if v.refs == nil {
+			v.refs = make(map[refKey]*refResult)
+		}
+		r := &refResult{}
+		v.refs[key] = r
+		viols, shallow := v.match(rule, it, path)
+		if shallow {
+			viols = mismatch(path, t.name, it)
+		}
+		*r = refResult{true, viols, shallow}
+		return viols, shallow
+	case kindLiteral:
+		if equal(t.lit, it) {
+			return nil, false
+		}
+	case kindMajor:
+		if it.major == t.major && (t.arg == nil || majorArg(it) == *t.arg) {
+			return nil, false
+		}
+	case kindTag:
+		if it.major == majorTag && it.arg == *t.arg {
+			viols, shallow := v.match(t.hi, it.items[0], path)
+			if !shallow {
+				return viols, false
+			}
+		}
+	case kindChoice:
+		var deep [][]Violation
+		for _, c := range t.choices {
+			viols, shallow := v.match(c, it, path)
+			if len(viols) == 0 {
+				return nil, false
+			}
+			if !shallow {
+				deep = append(deep, viols)
+			}
+		}
+		// If exactly one alternative got past the type of it, its problems are the interesting ones.
+		if len(deep) == 1 {
+			return deep[0], false
+		}
+	case kindRange:
+		if v.inRange(t, it) {
+			return nil, false
+		}
+	case kindControl:
+		if viols, shallow := v.match(t.lo, it, path); len(viols) > 0 {
+			return viols, shallow
+		}
+		return v.control(t, it, path), false
+	case kindArray:
+		if it.major == majorArray {
+			return v.matchArray(t.group, it.items, path), false
+		}
+	case kindMap:
+		if it.major == majorMap {
+			return v.matchMap(t.group, it.items, path), false
+		}
+	case kindGroup:
+		return []Violation{{path, "group used as a type"}}, false
+	}
+	return mismatch(path, describe(t), it), true
+}
+
+// majorArg returns the value that #n.m constrains for an item of major type n.
+func majorArg(it *item) uint64 {
+	switch it.major {
+	case majorBytes, majorText:
+		return uint64(len(it.data))
+	case majorArray:
+		return uint64(len(it.items))
+	case majorMap:
+		return uint64(len(it.items) / 2)
+	case majorSimple:
+		if it.info == 24 {
+			return it.arg
+		}
+		return uint64(it.info)
+	}
+	return it.arg
+}
+
+// literal returns the literal value of t, following rule references, or nil if t isn't a literal.
+func (v *validator) literal(t *typ) *item {
+	for i := 0; t.kind == kindRef && i < maxDepth; i++ {
+		t = v.s.rules[t.name]
+	}
+	if t.kind != kindLiteral {
+		return nil
+	}
+	return t.lit
+}
+
+func (v *validator) inRange(t *typ, it *item) bool {
+	lo, hi := v.literal(t.lo), v.literal(t.hi)
+	if lo == nil || hi == nil || lo.isFloat() != it.isFloat() || hi.isFloat() != it.isFloat() {
+		return false
+	}
+	n, ok := it.number()
+	if !ok {
+		return false
+	}
+	l, _ := lo.number()
+	h, _ := hi.number()
+	if n.Cmp(l) < 0 {
+		return false
+	}
+	c := n.Cmp(h)
+	return c < 0 || c == 0 && t.incl
+}
+
+// controls lists the supported control operators.
+var controls = map[string]struct{}{
+	"size": {}, "regexp": {}, "lt": {}, "le": {}, "gt": {}, "ge": {}, "eq": {}, "ne": {},
+	"default": {}, "and": {}, "within": {}, "cbor": {},
+}
+
+// control checks it, which already matches t.lo, against the control operator t.
+func (v *validator) control(t *typ, it *item, path string) []Violation {
+	switch t.name {
+	case "default":
+		return nil
+	case "and", "within":
+		viols, shallow := v.match(t.hi, it, path)
+		if shallow {
+			return mismatch(path, describe(t), it)
+		}
+		return viols
+	case "size":
+		switch it.major {
+		case majorBytes, majorText:
+			size := &item{major: majorUint, arg: uint64(len(it.data))}
+			if viols, _ := v.match(t.hi, size, path); len(viols) > 0 {
+				return []Violation{{path, fmt.Sprintf("size %d is not %s", len(it.data), describe(t.hi))}}
+			}
+			return nil
+		case majorUint:
+			size := v.literal(t.hi)
+			if size == nil || size.major != majorUint {
+				return []Violation{{path, "unsupported .size controller for an integer"}}
+			}
+			if size.arg < 8 && it.arg >= 1<<(8*size.arg) {
+				return []Violation{{path, fmt.Sprintf("%d does not fit in %d bytes", it.arg, size.arg)}}
+			}
+			return nil
+		}
+	case "regexp":
+		pattern := v.literal(t.hi)
+		if pattern == nil || pattern.major != majorText || it.major != majorText {
+			return []Violation{{path, ".regexp needs a text string and a text pattern"}}
+		}
+		re, err := v.regexp(string(pattern.data))
+		if err != nil {
+			return []Violation{{path, err.Error()}}
+		}
+		if !re.Match(it.data) {
+			return []Violation{{path, fmt.Sprintf("%s does not match %s", it, pattern)}}
+		}
+		return nil
+	case "eq", "ne":
+		lit := v.literal(t.hi)
+		if lit == nil {
+			return []Violation{{path, fmt.Sprintf(".%s needs a literal value", t.name)}}
+		}
+		if equal(lit, it) != (t.name == "eq") {
+			return []Violation{{path, fmt.Sprintf("%s is not .%s %s", it, t.name, lit)}}
+		}
+		return nil
+	case "lt", "le", "gt", "ge":
+		lit := v.literal(t.hi)
+		var l, n *big.Float
+		ok := lit != nil
+		if ok {
+			l, ok = lit.number()
+		}
+		if ok {
+			n, ok = it.number()
+		}
+		if !ok {
+			return []Violation{{path, fmt.Sprintf(".%s needs numbers", t.name)}}
+		}
+		c := n.Cmp(l)
+		if t.name == "lt" && c < 0 || t.name == "le" && c <= 0 || t.name == "gt" && c > 0 || t.name == "ge" && c >= 0 {
+			return nil
+		}
+		return []Violation{{path, fmt.Sprintf("%s is not .%s %s", it, t.name, lit)}}
+	case "cbor":
+		if it.major != majorBytes {
+			break
+		}
+		embedded, err := parseItem(it.data)
+		if err != nil {
+			return []Violation{{path, "byte string does not hold well-formed CBOR"}}
+		}
+		viols, shallow := v.match(t.hi, embedded, path)
+		if shallow {
+			return mismatch(path, describe(t), embedded)
+		}
+		return viols
+	}
+	return mismatch(path, describe(t), it)
+}
+
+func (v *validator) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid .regexp pattern: %s", err)
+	}
+	if v.regexps == nil {
+		v.regexps = make(map[string]*regexp.Regexp)
+	}
+	v.regexps[pattern] = re
+	return re, nil
+}
+
+// entryGroup returns the group that e refers to (inline or by name), or nil if it is an ordinary entry.
+func (v *validator) entryGroup(e *entry) *group {
+	if e.key != nil {
+		return nil
+	}
+	t := e.typ
+	for i := 0; t.kind == kindRef && i < maxDepth; i++ {
+		t = v.s.rules[t.name]
+	}
+	if t.kind == kindGroup {
+		return t.group
+	}
+	return nil
+}
+
+// arrayMatch tracks the most informative failure while matching array elements.
+type arrayMatch struct {
+	items   []*item
+	path    string
+	bestPos int // the furthest position at which an element failed to match, or -1
+	best    []Violation
+
+	// The results of entryOnceEnds, which would otherwise be recomputed for every way of reaching the same
+	// position.
+	onceEnds map[entryPos]map[int]bool
+}
+
+// An entryPos is an entry starting at a position in the array.
+type entryPos struct {
+	e   *entry
+	pos int
+}
+
+func (v *validator) matchArray(g *group, items []*item, path string) []Violation {
+	m := &arrayMatch{
+		items:    items,
+		path:     path,
+		bestPos:  -1,
+		onceEnds: make(map[entryPos]map[int]bool),
+	}
+	ends := v.groupEnds(m, g, 0)
+	maxEnd := -1
+	for end := range ends {
+		if end == len(items) {
+			return nil
+		}
+		maxEnd = max(maxEnd, end)
+	}
+	if m.bestPos >= 0 && m.bestPos >= maxEnd {
+		return m.best
+	}
+	return []Violation{{elemPath(path, maxEnd), "unexpected array element"}}
+}
+
+// groupEnds returns the set of positions in m.items at which a match of g starting at start could end.
+func (v *validator) groupEnds(m *arrayMatch, g *group, start int) map[int]bool {
+	ends := make(map[int]bool)
+	for _, entries := range g.choices {
+		for end := range v.entriesEnds(m, entries, start) {
+			ends[end] = true
+		}
+	}
+	return ends
+}
+
+func (v *validator) entriesEnds(m *arrayMatch, entries []*entry, start int) map[int]bool {
+	frontier := map[int]bool{start: true}
+	for _, e := range entries {
+		frontier = v.entryEnds(m, e, frontier)
+	}
+	return frontier
+}
+
+// entryEnds returns the positions at which e, repeated as allowed by its occurrence indicator, could end if
+// it starts at any of the positions in starts. All the starts are followed together, so that a position
+// reachable from several of them is only followed once.
+func (v *validator) entryEnds(m *arrayMatch, e *entry, starts map[int]bool) map[int]bool {
+	ends := make(map[int]bool)
+	if e.min == 0 {
+		for pos := range starts {
+			ends[pos] = true
+		}
+	}
+	// Once e has been repeated at least e.min times, and if it may be repeated without limit, where it can
+	// end next no longer depends on how many times it was repeated to get to a position.
+	followed := make(map[int]bool)
+	frontier := starts
+	for n := 1; len(frontier) > 0 && (e.max < 0 || n <= e.max); n++ {
+		next := make(map[int]bool)
+		for pos := range frontier {
+			if e.max < 0 && n-1 >= e.min {
+				if followed[pos] {
+					continue
+				}
+				followed[pos] = true
+			}
+			for end := range v.entryOnceEnds(m, e, pos) {
+				// Repetitions that consume nothing can't get anywhere new.
+				if end > pos || n == 1 {
+					next[end] = true
+				}
+			}
+		}
+		if n >= e.min {
+			for end := range next {
+				ends[end] = true
+			}
+		}
+		frontier = next
+		if n > len(m.items) {
+			break
+		}
+	}
+	return ends
+}
+
+// entryOnceEnds returns the positions at which a single occurrence of e could end if it starts at pos. The
+// result must not be modified.
+func (v *validator) entryOnceEnds(m *arrayMatch, e *entry, pos int) map[int]bool {
+	key := entryPos{e, pos}
+	if ends, ok := m.onceEnds[key]; ok {
+		return ends
+	}
+	ends := v.entryOnceEndsUncached(m, e, pos)
+	m.onceEnds[key] = ends
+	return ends
+}
+
+func (v *validator) entryOnceEndsUncached(m *arrayMatch, e *entry, pos int) map[int]bool {
+	if g := v.entryGroup(e); g != nil {
+		return v.groupEnds(m, g, pos)
+	}
+	if pos >= len(m.items) {
+		if pos > m.bestPos {
+			m.bestPos = pos
+			m.best = []Violation{{m.path, fmt.Sprintf("missing array element: expected %s", describe(e.typ))}}
+		}
+		return nil
+	}
+	viols, _ := v.match(e.typ, m.items[pos], elemPath(m.path, pos))
+	if len(viols) > 0 {
+		if pos > m.bestPos {
+			m.bestPos = pos
+			m.best = viols
+		}
+		return nil
+	}
+	return map[int]bool{pos + 1: true}
+}
+
+func elemPath(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// keyPath returns the path of the value with the given key in a map at path.
+func keyPath(path string, key *item) string {
+	if key.major == majorText && isName(string(key.data)) {
+		return path + "." + string(key.data)
+	}
+	return path + "[" + key.String() + "]"
+}
+
+func isName(s string) bool {
+	if s == "" || !isAlpha(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(s[i]) && !isDigit(s[i]) && s[i] != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *validator) matchMap(g *group, items []*item, path string) []Violation {
+	var best []Violation
+	for _, entries := range v.mapAlternatives(g.choices) {
+		viols := v.matchMapEntries(entries, items, path, nil)
+		if len(viols) == 0 {
+			return nil
+		}
+		if best == nil || len(viols) < len(best) {
+			best = viols
+		}
+	}
+	return best
+}
+
+// mapAlternatives flattens the nested groups within group choices into lists of keyed entries, one list per
+// combination of group choices. An optional nested group is either present as a whole or absent.
+func (v *validator) mapAlternatives(choices [][]*entry) [][]*entry {
+	var alts [][]*entry
+	for _, entries := range choices {
+		partial := [][]*entry{nil}
+		for _, e := range entries {
+			expansions := [][]*entry{{e}}
+			if g := v.entryGroup(e); g != nil {
+				expansions = v.mapAlternatives(g.choices)
+				if e.min == 0 {
+					expansions = append(expansions, nil)
+				}
+			}
+			var next [][]*entry
+			for _, p := range partial {
+				for _, exp := range expansions {
+					next = append(next, append(append([]*entry(nil), p...), exp...))
+				}
+			}
+			partial = next
+		}
+		alts = append(alts, partial...)
+	}
+	return alts
+}
+
+// matchMapEntries checks the key/value pairs in items against entries. If assigned is non-nil, the entry
+// matching each pair is recorded in it.
+func (v *validator) matchMapEntries(entries []*entry, items []*item, path string, assigned []*entry) []Violation {
+	var viols []Violation
+	used := make([]bool, len(items)/2)
+	// Match entries with literal keys first, so that they aren't claimed by catch-all entries.
+	for _, e := range entries {
+		if e.key == nil || e.key.kind != kindLiteral {
+			continue
+		}
+		found := false
+		for i := range used {
+			if !used[i] && equal(e.key.lit, items[2*i]) {
+				used[i] = true
+				if assigned != nil {
+					assigned[i] = e
+				}
+				found = true
+				vs, _ := v.match(e.typ, items[2*i+1], keyPath(path, items[2*i]))
+				viols = append(viols, vs...)
+				break
+			}
+		}
+		if !found && e.min > 0 {
+			viols = append(viols, Violation{path, fmt.Sprintf("missing key %s", e.key.lit)})
+		}
+	}
+	for _, e := range entries {
+		if e.key == nil {
+			viols = append(viols, Violation{path, fmt.Sprintf("map entry %s has no member key", describe(e.typ))})
+			continue
+		}
+		if e.key.kind == kindLiteral {
+			continue
+		}
+		n := 0
+		for i := range used {
+			if used[i] || e.max >= 0 && n >= e.max {
+				continue
+			}
+			if vs, _ := v.match(e.key, items[2*i], path); len(vs) > 0 {
+				continue
+			}
+			used[i] = true
+			if assigned != nil {
+				assigned[i] = e
+			}
+			n++
+			vs, _ := v.match(e.typ, items[2*i+1], keyPath(path, items[2*i]))
+			viols = append(viols, vs...)
+		}
+		if n < e.min {
+			viols = append(viols, Violation{path, fmt.Sprintf("expected at least %d keys matching %s", e.min, describe(e.key))})
+		}
+	}
+	for i := range used {
+		if !used[i] {
+			viols = append(viols, Violation{keyPath(path, items[2*i]), "unexpected key"})
+		}
+	}
+	return viols
+}
+
+// describe returns a short CDDL-like description of t for messages.
+func describe(t *typ) string {
+	switch t.kind {
+	case kindAny:
+		return "any"
+	case kindRef:
+		return t.name
+	case kindLiteral:
+		return t.lit.String()
+	case kindMajor:
+		if t.arg != nil {
+			return fmt.Sprintf("#%d.%d", t.major, *t.arg)
+		}
+		return fmt.Sprintf("#%d", t.major)
+	case kindTag:
+		return fmt.Sprintf("#6.%d(%s)", *t.arg, describe(t.hi))
+	case kindChoice:
+		parts := make([]string, len(t.choices))
+		for i, c := range t.choices {
+			parts[i] = describe(c)
+		}
+		return strings.Join(parts, " / ")
+	case kindRange:
+		op := "..."
+		if t.incl {
+			op = ".."
+		}
+		return describe(t.lo) + op + describe(t.hi)
+	case kindControl:
+		return fmt.Sprintf("%s .%s %s", describe(t.lo), t.name, describe(t.hi))
+	case kindArray:
+		return "array"
+	case kindMap:
+		return "map"
+	}
+	return "group"
+}
@@ -0,0 +1,88 @@
+package cbor
+
+import (
+	"errors"
+	"reflect"
+)
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	streamMarshalerType = reflect.TypeOf((*StreamMarshaler)(nil)).Elem()
+	mapIteratorType     = reflect.TypeOf((*MapIterator)(nil)).Elem()
+	arrayIteratorType   = reflect.TypeOf((*ArrayIterator)(nil)).Elem()
+)
+
+// CanMarshal reports whether values of type t can be encoded by Marshal. If not, it returns an
+// *UnsupportedTypeError for the first unsupported type found, with a Path (like ".Items[].Callback") saying
+// where it is within t. Services can call CanMarshal on their message types at startup rather than finding
+// out about unsupported types from failing Marshal calls.
+//
+// Values of interface types aren't known until they're encoded, so interface types are assumed to be
+// supported. A type is assumed to be a Marshaler (or StreamMarshaler, MapIterator, or ArrayIterator) if
+// either it or a pointer to it implements the interface, though Marshal only uses pointer methods on
+// addressable values.
+func CanMarshal(t reflect.Type) error {
+	if t == nil {
+		return nil // the nil interface encodes as null
+	}
+	return checkType(t, "", make(map[reflect.Type]bool))
+}
+
+// Preregister prepares to encode values of the same types as the given values (which may be nil pointers,
+// like (*Message)(nil)), building the cached information about each struct type they contain up front
+// rather than when they're first encoded. It returns the errors from calling CanMarshal on each type, joined
+// with errors.Join, so services can call it at startup to find unsupported types early.
+func Preregister(values ...interface{}) error {
+	var errs []error
+	for _, v := range values {
+		if err := CanMarshal(reflect.TypeOf(v)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkType implements CanMarshal. The types being checked (or already checked) are recorded in seen, so
+// recursive types terminate.
+func checkType(t reflect.Type, path string, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	pt := reflect.PointerTo(t)
+	for _, it := range []reflect.Type{marshalerType, streamMarshalerType, mapIteratorType, arrayIteratorType} {
+		if t.Implements(it) || pt.Implements(it) {
+			return nil
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	case reflect.Struct:
+		for _, f := range cachedFieldsForType(t) {
+			if err := checkType(f.typ, path+"."+f.name, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		return checkType(t.Elem(), path+"[]", seen)
+	case reflect.Map:
+		if err := checkType(t.Key(), path+"[key]", seen); err != nil {
+			return err
+		}
+		return checkType(t.Elem(), path+"[]", seen)
+	case reflect.Ptr:
+		return checkType(t.Elem(), path, seen)
+	case reflect.Func:
+		if isSeqType(t) {
+			return checkType(t.In(0).In(0), path+"[]", seen)
+		}
+	}
+	return &UnsupportedTypeError{Type: t, Path: path}
+}
@@ -0,0 +1,64 @@
+package cbor
+
+import "math/big"
+
+// Clone returns a deep copy of v, a tree of generic CBOR values: []interface{}, map[interface{}]interface{},
+// map[string]interface{}, OrderedMap, []byte, RawMessage, and *big.Int, along with scalar values (which are
+// returned as-is). Values of other types are not copied. Clone is useful for caching a document and handing
+// out copies that callers may modify, without an encode/decode round trip.
+func Clone(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		c := make([]interface{}, len(v))
+		for i, elem := range v {
+			c[i] = Clone(elem)
+		}
+		return c
+	case map[interface{}]interface{}:
+		if v == nil {
+			return v
+		}
+		c := make(map[interface{}]interface{}, len(v))
+		for key, elem := range v {
+			c[Clone(key)] = Clone(elem)
+		}
+		return c
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		c := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			c[key] = Clone(elem)
+		}
+		return c
+	case OrderedMap:
+		if v == nil {
+			return v
+		}
+		c := make(OrderedMap, len(v))
+		for i, item := range v {
+			c[i] = MapItem{Clone(item.Key), Clone(item.Value)}
+		}
+		return c
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte{}, v...)
+	case RawMessage:
+		if v == nil {
+			return v
+		}
+		return append(RawMessage{}, v...)
+	case *big.Int:
+		if v == nil {
+			return v
+		}
+		return new(big.Int).Set(v)
+	}
+	return v
+}
@@ -0,0 +1,38 @@
+package cbor
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	orig := map[string]interface{}{
+		"list":  []interface{}{uint64(1), "a", []byte{1, 2}},
+		"map":   map[interface{}]interface{}{int64(-1): big.NewInt(5)},
+		"items": OrderedMap{{"k", RawMessage{0x01}}},
+		"nil":   []interface{}(nil),
+		"num":   1.5,
+	}
+	c := Clone(orig).(map[string]interface{})
+	if !reflect.DeepEqual(c, orig) {
+		t.Fatalf("clone %#v differs from original %#v", c, orig)
+	}
+
+	// Modify the clone everywhere; the original must be unchanged.
+	c["list"].([]interface{})[0] = "changed"
+	c["list"].([]interface{})[2].([]byte)[0] = 9
+	c["map"].(map[interface{}]interface{})[int64(-1)].(*big.Int).SetInt64(6)
+	c["items"].(OrderedMap)[0].Value.(RawMessage)[0] = 0x02
+	c["num"] = 2.5
+	expected := map[string]interface{}{
+		"list":  []interface{}{uint64(1), "a", []byte{1, 2}},
+		"map":   map[interface{}]interface{}{int64(-1): big.NewInt(5)},
+		"items": OrderedMap{{"k", RawMessage{0x01}}},
+		"nil":   []interface{}(nil),
+		"num":   1.5,
+	}
+	if !reflect.DeepEqual(orig, expected) {
+		t.Errorf("modifying the clone changed the original: %#v", orig)
+	}
+}
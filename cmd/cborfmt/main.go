@@ -0,0 +1,85 @@
+// Command cborfmt converts CBOR to indented diagnostic notation (RFC 8949, section 8, with the extensions of
+// RFC 8610, appendix G), and back.
+//
+// Usage:
+//
+//	cborfmt [-w] [file]
+//
+// By default, cborfmt reads CBOR from file (or standard input) and writes diagnostic notation to standard
+// output. Encoding details that aren't the preferred serialization, like indefinite lengths and integers or
+// floats wider than necessary, are recorded with encoding indicators (such as [_ 1, 2] and 1_1), so the
+// original bytes can be reproduced exactly. The exception is NaN: diagnostic notation can't express its sign
+// or payload, so every NaN comes back as the quiet NaN of the same width (f97e00 for a half-precision NaN).
+//
+// With -w, cborfmt reads diagnostic notation and writes the CBOR it describes, so fixtures can be kept in
+// readable form and regenerated deterministically.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cespare/cbor"
+)
+
+func main() {
+	toCBOR := flag.Bool("w", false, "Read diagnostic notation and write CBOR")
+	indent := flag.String("indent", "  ", "Indentation string for nested items")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-w] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		r = f
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	var err error
+	if *toCBOR {
+		var input, out []byte
+		input, err = io.ReadAll(r)
+		if err == nil {
+			out, err = parseDiag(string(input))
+		}
+		if err == nil {
+			_, err = w.Write(out)
+		}
+	} else {
+		// The input is formatted as it's read, so it may be arbitrarily large.
+		err = formatDiag(w, r, *indent)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+// formatDiag reads a CBOR sequence from r and writes it to w in indented diagnostic notation, one top-level
+// item per line (separated by commas).
+func formatDiag(w io.Writer, r io.Reader, indent string) error {
+	enc := cbor.NewDiagnosticEncoder(w)
+	enc.SetIndent(indent)
+	return enc.EncodeFrom(r)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "cborfmt:", err)
+	os.Exit(1)
+}
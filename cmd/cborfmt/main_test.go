@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	for _, test := range []struct {
+		input    string // hex
+		expected string
+	}{
+		{"", ""},
+		{"00", "0\n"},
+		{"0001", "0,\n1\n"},
+		{"3bffffffffffffffff", "-18446744073709551616\n"},
+		{"1817", "23_0\n"},
+		{"f93c00", "1.0\n"},
+		{"fa3f800000", "1.0_2\n"},
+		{"fa47c35000", "100000.0\n"},
+		{"fb3ff199999999999a", "1.1\n"},
+		{"f97e00", "NaN\n"},
+		{"f9fc00", "-Infinity\n"},
+		{"f98000", "-0.0\n"},
+		{"f4f5f6f7", "false,\ntrue,\nnull,\nundefined\n"},
+		{"f0f8ff", "simple(16),\nsimple(255)\n"},
+		{"4401020304", "h'01020304'\n"},
+		{"62225c", "\"\\\"\\\\\"\n"},
+		{"6b0a09c3bce6b0b4f0908591", "\"\\n\\tü水\U00010151\"\n"},
+		{"5f42010243030405ff", "(_ h'0102', h'030405')\n"},
+		{"5fff", "''_\n"},
+		{"7fff", "\"\"_\n"},
+		{"fb7ff8000000000000", "NaN_3\n"},
+		{"80", "[]\n"},
+		{"a0", "{}\n"},
+		{"83010203", "[\n  1,\n  2,\n  3\n]\n"},
+		{"a26161016162820203", "{\n  \"a\": 1,\n  \"b\": [\n    2,\n    3\n  ]\n}\n"},
+		{"9f018202039f0405ffff", "[_\n  1,\n  [\n    2,\n    3\n  ],\n  [_\n    4,\n    5\n  ]\n]\n"},
+		{"bf6346756ef563416d7421ff", "{_\n  \"Fun\": true,\n  \"Amt\": -2\n}\n"},
+		{"c074323031332d30332d32315432303a30343a30305a", "0(\"2013-03-21T20:04:00Z\")\n"},
+		{"d82076687474703a2f2f7777772e6578616d706c652e636f6d", "32(\"http://www.example.com\")\n"},
+		{"c1d9000101", "1(1_1(1))\n"},
+		{"98020102", "[_0\n  1,\n  2\n]\n"},
+	} {
+		data, err := hex.DecodeString(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := formatDiag(&buf, bytes.NewReader(data), "  "); err != nil {
+			t.Errorf("%s: %s", test.input, err)
+			continue
+		}
+		if buf.String() != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.input, test.expected, buf.String())
+		}
+		// Parsing the output must reproduce the input exactly.
+		back, err := parseDiag(buf.String())
+		if err != nil {
+			t.Errorf("%s: parsing %q: %s", test.input, buf.String(), err)
+			continue
+		}
+		if !bytes.Equal(back, data) {
+			t.Errorf("%s: round trip through %q produced %x", test.input, buf.String(), back)
+		}
+	}
+}
+
+func TestFormatNaNPayload(t *testing.T) {
+	// The sign and payload of a NaN are lost; only its width is kept.
+	for input, expected := range map[string]string{"f97e30": "f97e00", "f9fe00": "f97e00", "fa7f800001": "fa7fc00000"} {
+		data, _ := hex.DecodeString(input)
+		var buf bytes.Buffer
+		if err := formatDiag(&buf, bytes.NewReader(data), "  "); err != nil {
+			t.Fatalf("%s: %s", input, err)
+		}
+		back, err := parseDiag(buf.String())
+		if err != nil {
+			t.Fatalf("%s: parsing %q: %s", input, buf.String(), err)
+		}
+		if actual := hex.EncodeToString(back); actual != expected {
+			t.Errorf("%s: round trip through %q produced %s; want %s", input, buf.String(), actual, expected)
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, input := range []string{"1c", "8301", "ff", "9f01", "bf01ff", "5f01ff", "62c328"} {
+		data, _ := hex.DecodeString(input)
+		var buf bytes.Buffer
+		if err := formatDiag(&buf, bytes.NewReader(data), "  "); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected string // hex
+	}{
+		{`[1, [2, 3], {"a": b64'AQI', 'x': h'00 ff'}] # comment`, "83018202 03a2616142010241 78 4200ff"},
+		{`/ a comment / 1.5, 100000.0, 1.0e+300, 0x10, -0x10`, "f93e00fa47c35000fb7e37e43c8800759c 10 2f"},
+		{`(_ "ab", "c")`, "7f626162616 3ff"},
+		{`{_ 1: 2}`, "bf0102ff"},
+		{`24_3`, "1b0000000000000018"},
+		{`"x"_1`, "79000178"},
+	} {
+		out, err := parseDiag(test.input)
+		if err != nil {
+			t.Errorf("%q: %s", test.input, err)
+			continue
+		}
+		expected := string(bytes.ReplaceAll([]byte(test.expected), []byte(" "), nil))
+		if actual := hex.EncodeToString(out); actual != expected {
+			t.Errorf("%q: expected %s; got %s", test.input, expected, actual)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{`[1, 2`, `"abc`, `h'0'`, `300_0`, `1.1_1`, `-1(2)`, `(_ "a", h'00')`, `1 2`, `{1}`, `simple(24)`, `nope`, `(_ )`, `(_ ''_)`, `'a'_`} {
+		if _, err := parseDiag(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
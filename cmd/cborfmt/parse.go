@@ -0,0 +1,423 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds the nesting of the input.
+const maxDepth = 10000
+
+// A diagParser converts diagnostic notation to CBOR.
+type diagParser struct {
+	s     string
+	i     int
+	out   []byte
+	depth int
+}
+
+// parseDiag returns the CBOR sequence described by the comma-separated items in s.
+func parseDiag(s string) ([]byte, error) {
+	p := &diagParser{s: s}
+	p.skipSpace()
+	for p.i < len(p.s) {
+		if err := p.value(); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.i < len(p.s) {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p.out, nil
+}
+
+func (p *diagParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.s[:p.i], "\n")
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and comments (from # to the end of a line, or between slashes).
+func (p *diagParser) skipSpace() {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t', '\r', '\n':
+			p.i++
+		case '#':
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case '/':
+			end := strings.IndexByte(p.s[p.i+1:], '/')
+			if end < 0 {
+				p.i = len(p.s)
+			} else {
+				p.i += end + 2
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *diagParser) peek(prefix string) bool {
+	p.skipSpace()
+	return strings.HasPrefix(p.s[p.i:], prefix)
+}
+
+func (p *diagParser) expect(s string) error {
+	if !p.peek(s) {
+		return p.errorf("expected %q", s)
+	}
+	p.i += len(s)
+	return nil
+}
+
+// indicator reads an optional encoding indicator: "_" followed by a digit returns the digit; a bare "_"
+// (only allowed if indefinite is set) returns -2; and no indicator returns -1.
+func (p *diagParser) indicator(indefinite bool) (int, error) {
+	if p.i >= len(p.s) || p.s[p.i] != '_' {
+		return -1, nil
+	}
+	p.i++
+	if p.i < len(p.s) && '0' <= p.s[p.i] && p.s[p.i] <= '3' {
+		p.i++
+		return int(p.s[p.i-1] - '0'), nil
+	}
+	if !indefinite {
+		return 0, p.errorf("invalid encoding indicator")
+	}
+	return -2, nil
+}
+
+// header appends the initial byte and argument of an item, using the width selected by indicator (or the
+// shortest width if indicator is negative).
+func (p *diagParser) header(major byte, arg uint64, indicator int) error {
+	info := shortestInfo(arg)
+	if indicator >= 0 {
+		info = byte(24 + indicator)
+		if info < shortestInfo(arg) {
+			return p.errorf("%d doesn't fit in encoding indicator _%d", arg, indicator)
+		}
+	}
+	p.out = append(p.out, major<<5|info)
+	switch info {
+	case 24:
+		p.out = append(p.out, byte(arg))
+	case 25:
+		p.out = binary.BigEndian.AppendUint16(p.out, uint16(arg))
+	case 26:
+		p.out = binary.BigEndian.AppendUint32(p.out, uint32(arg))
+	case 27:
+		p.out = binary.BigEndian.AppendUint64(p.out, arg)
+	}
+	return nil
+}
+
+func (p *diagParser) value() error {
+	p.skipSpace()
+	if p.i >= len(p.s) {
+		return p.errorf("unexpected end of input")
+	}
+	if p.depth++; p.depth > maxDepth {
+		return p.errorf("nesting too deep")
+	}
+	defer func() { p.depth-- }()
+	rest := p.s[p.i:]
+	switch c := rest[0]; {
+	case c == '[' || c == '{':
+		return p.container()
+	case c == '"' || c == '\'' || strings.HasPrefix(rest, "h'") || strings.HasPrefix(rest, "b64'"):
+		return p.str()
+	case strings.HasPrefix(rest, "(_"):
+		return p.chunkedString()
+	case c == '-' || '0' <= c && c <= '9' || strings.HasPrefix(rest, "NaN") || strings.HasPrefix(rest, "Infinity"):
+		return p.number()
+	case strings.HasPrefix(rest, "simple("):
+		p.i += len("simple(")
+		j := strings.IndexByte(p.s[p.i:], ')')
+		if j < 0 {
+			return p.errorf("unterminated simple value")
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(p.s[p.i:p.i+j]), 10, 8)
+		if err != nil || n >= 24 && n < 32 {
+			return p.errorf("invalid simple value")
+		}
+		p.i += j + 1
+		return p.header(7, n, -1)
+	}
+	for word, info := range map[string]uint64{"false": 20, "true": 21, "null": 22, "undefined": 23} {
+		if strings.HasPrefix(rest, word) {
+			p.i += len(word)
+			return p.header(7, info, -1)
+		}
+	}
+	return p.errorf("unexpected %q", rest[0])
+}
+
+func (p *diagParser) container() error {
+	open := p.s[p.i]
+	p.i++
+	var major byte = 4
+	close := "]"
+	if open == '{' {
+		major, close = 5, "}"
+	}
+	ind, err := p.indicator(true)
+	if err != nil {
+		return err
+	}
+	// Encode the items separately, since the count comes first.
+	outer := p.out
+	p.out = nil
+	n := uint64(0)
+	for !p.peek(close) {
+		if n > 0 {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		if major == 5 {
+			if err := p.expect(":"); err != nil {
+				return err
+			}
+			if err := p.value(); err != nil {
+				return err
+			}
+		}
+		n++
+	}
+	p.i += len(close)
+	items := p.out
+	p.out = outer
+	if ind == -2 {
+		p.out = append(p.out, major<<5|31)
+		p.out = append(p.out, items...)
+		p.out = append(p.out, 0xFF)
+		return nil
+	}
+	if err := p.header(major, n, ind); err != nil {
+		return err
+	}
+	p.out = append(p.out, items...)
+	return nil
+}
+
+// str parses a text string ("..."), or a byte string in hex (h'...'), base64 (b64'...'), or text ('...').
+func (p *diagParser) str() error {
+	rest := p.s[p.i:]
+	var major byte
+	var b []byte
+	switch {
+	case rest[0] == '"':
+		end := 1
+		for ; end < len(rest) && rest[end] != '"'; end++ {
+			if rest[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(rest) {
+			return p.errorf("unterminated text string")
+		}
+		var s string
+		if err := json.Unmarshal([]byte(rest[:end+1]), &s); err != nil {
+			return p.errorf("invalid text string: %s", err)
+		}
+		major, b = 3, []byte(s)
+		p.i += end + 1
+	case rest[0] == '\'':
+		end := 1
+		var s strings.Builder
+		for ; end < len(rest) && rest[end] != '\''; end++ {
+			if rest[end] == '\\' && end+1 < len(rest) {
+				end++
+			}
+			s.WriteByte(rest[end])
+		}
+		if end >= len(rest) {
+			return p.errorf("unterminated byte string")
+		}
+		major, b = 2, []byte(s.String())
+		p.i += end + 1
+	default:
+		prefix, quoted, _ := strings.Cut(rest, "'")
+		end := strings.IndexByte(quoted, '\'')
+		if end < 0 {
+			return p.errorf("unterminated byte string")
+		}
+		contents := strings.Join(strings.Fields(quoted[:end]), "")
+		var err error
+		if prefix == "h" {
+			b, err = hex.DecodeString(contents)
+		} else {
+			contents = strings.TrimRight(contents, "=")
+			if strings.ContainsAny(contents, "+/") {
+				b, err = base64.RawStdEncoding.DecodeString(contents)
+			} else {
+				b, err = base64.RawURLEncoding.DecodeString(contents)
+			}
+		}
+		if err != nil {
+			return p.errorf("invalid byte string: %s", err)
+		}
+		major = 2
+		p.i += len(prefix) + 1 + end + 1
+	}
+	ind, err := p.indicator(true)
+	if err != nil {
+		return err
+	}
+	if ind == -2 {
+		// ''_ and ""_ are the empty indefinite-length strings.
+		if len(b) > 0 {
+			return p.errorf("invalid encoding indicator")
+		}
+		p.out = append(p.out, major<<5|31, 0xFF)
+		return nil
+	}
+	if err := p.header(major, uint64(len(b)), ind); err != nil {
+		return err
+	}
+	p.out = append(p.out, b...)
+	return nil
+}
+
+// chunkedString parses an indefinite-length string: (_ chunk, chunk, ...).
+func (p *diagParser) chunkedString() error {
+	p.i += len("(_")
+	start := len(p.out)
+	p.out = append(p.out, 0) // replaced once the type of the chunks is known
+	var major byte
+	for n := 0; !p.peek(")"); n++ {
+		if n > 0 {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+		p.skipSpace()
+		chunk := len(p.out)
+		if err := p.str(); err != nil {
+			return err
+		}
+		m := p.out[chunk] >> 5
+		if n > 0 && m != major {
+			return p.errorf("mixed chunk types in indefinite-length string")
+		}
+		if p.out[chunk]&0x1F == 31 {
+			return p.errorf("nested indefinite-length string")
+		}
+		major = m
+	}
+	p.i++
+	if len(p.out) == start+1 {
+		return p.errorf("empty indefinite-length string must be written as ''_ or \"\"_")
+	}
+	p.out[start] = major<<5 | 31
+	p.out = append(p.out, 0xFF)
+	return nil
+}
+
+// number parses an integer, a tag (an integer followed by a parenthesized item), or a float.
+func (p *diagParser) number() error {
+	rest := p.s[p.i:]
+	end := 0
+	for end < len(rest) && strings.IndexByte("+-0123456789.eExabcdefABCDEFNaInfity", rest[end]) >= 0 {
+		// Stop at an exponent sign only if it isn't part of an exponent.
+		if (rest[end] == '+' || rest[end] == '-') && end > 0 && rest[end-1] != 'e' && rest[end-1] != 'E' {
+			break
+		}
+		end++
+	}
+	lit := rest[:end]
+	p.i += end
+	ind, err := p.indicator(false)
+	if err != nil {
+		return err
+	}
+
+	isFloat := strings.ContainsAny(lit, ".nN") || !strings.HasPrefix(strings.TrimPrefix(lit, "-"), "0x") && strings.ContainsAny(lit, "eE")
+	if isFloat {
+		var v float64
+		switch lit {
+		case "NaN":
+			v = math.NaN()
+		case "Infinity":
+			v = math.Inf(1)
+		case "-Infinity":
+			v = math.Inf(-1)
+		default:
+			v, err = strconv.ParseFloat(lit, 64)
+			if err != nil {
+				return p.errorf("invalid number %s", lit)
+			}
+		}
+		return p.float(v, ind)
+	}
+
+	n, ok := new(big.Int).SetString(lit, 0)
+	if !ok {
+		return p.errorf("invalid number %s", lit)
+	}
+	var major byte
+	if n.Sign() < 0 {
+		major = 1
+		n.Neg(n).Sub(n, big.NewInt(1))
+	}
+	if !n.IsUint64() {
+		return p.errorf("integer %s is out of range", lit)
+	}
+	if p.peek("(") {
+		if major == 1 {
+			return p.errorf("negative tag number")
+		}
+		p.i++
+		if err := p.header(6, n.Uint64(), ind); err != nil {
+			return err
+		}
+		if err := p.value(); err != nil {
+			return err
+		}
+		return p.expect(")")
+	}
+	return p.header(major, n.Uint64(), ind)
+}
+
+func (p *diagParser) float(v float64, indicator int) error {
+	info := shortestFloatInfo(v)
+	if indicator >= 0 {
+		info = byte(24 + indicator)
+		if info == 24 || info < shortestFloatInfo(v) {
+			return p.errorf("%v doesn't fit in encoding indicator _%d", v, indicator)
+		}
+	}
+	p.out = append(p.out, 7<<5|info)
+	switch info {
+	case 25:
+		p.out = binary.BigEndian.AppendUint16(p.out, float64ToFloat16(v))
+	case 26:
+		bits := math.Float32bits(float32(v))
+		if math.IsNaN(v) {
+			bits = 0x7FC00000
+		}
+		p.out = binary.BigEndian.AppendUint32(p.out, bits)
+	default:
+		bits := math.Float64bits(v)
+		if math.IsNaN(v) {
+			bits = 0x7FF8000000000000
+		}
+		p.out = binary.BigEndian.AppendUint64(p.out, bits)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import "math"
+
+// These helpers choose the encodings that the parser writes for numbers without encoding indicators.
+
+// shortestInfo returns the additional information used by the shortest encoding of arg.
+func shortestInfo(arg uint64) byte {
+	switch {
+	case arg < 24:
+		return byte(arg)
+	case arg <= math.MaxUint8:
+		return 24
+	case arg <= math.MaxUint16:
+		return 25
+	case arg <= math.MaxUint32:
+		return 26
+	}
+	return 27
+}
+
+// shortestFloatInfo returns the additional information of the narrowest float encoding that represents v
+// exactly.
+func shortestFloatInfo(v float64) byte {
+	switch {
+	case fitsFloat16(v):
+		return 25
+	case float64(float32(v)) == v:
+		return 26
+	}
+	return 27
+}
+
+// fitsFloat16 reports whether f can be represented exactly as an IEEE 754 half-precision float.
+func fitsFloat16(f float64) bool {
+	if f == 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return true
+	}
+	frac, exp := math.Frexp(math.Abs(f))
+	switch {
+	case exp-1 > 15:
+		return false
+	case exp-1 >= -14:
+		// Normal numbers have 10 bits after the leading 1.
+		m := frac * 2048
+		return m == math.Trunc(m)
+	}
+	// Subnormal numbers are multiples of 2^-24.
+	m := math.Abs(f) * (1 << 24)
+	return m == math.Trunc(m)
+}
+
+// float64ToFloat16 converts f, which must satisfy fitsFloat16, to half-precision bits.
+func float64ToFloat16(f float64) uint16 {
+	var sign uint16
+	if math.Signbit(f) {
+		sign = 0x8000
+	}
+	switch {
+	case math.IsNaN(f):
+		return 0x7E00
+	case math.IsInf(f, 0):
+		return sign | 0x7C00
+	case f == 0:
+		return sign
+	}
+	frac, exp := math.Frexp(math.Abs(f))
+	if exp-1 >= -14 {
+		return sign | uint16(exp-1+15)<<10 | uint16(frac*2048)&0x3FF
+	}
+	return sign | uint16(math.Abs(f)*(1<<24))
+}
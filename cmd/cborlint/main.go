@@ -0,0 +1,301 @@
+// Command cborlint checks CBOR data for problems: malformed items, non-canonical encodings, duplicate map
+// keys, and excessive nesting.
+//
+// Usage:
+//
+//	cborlint [flags] [file ...]
+//
+// Each file (or standard input, if no files are given) is read as a CBOR sequence: zero or more items, one
+// after another. Problems are printed one per line as file:offset: message. The exit status is 0 if no
+// problems were found, 1 if there were problems, and 2 if a file couldn't be read or the flags were wrong.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+func main() {
+	var l linter
+	flag.IntVar(&l.maxDepth, "maxdepth", 32, "Report arrays, maps, and tags nested more deeply than this")
+	flag.BoolVar(&l.canonical, "canonical", true, "Report encodings that aren't canonical (RFC 7049, section 3.9)")
+	flag.BoolVar(&l.single, "single", false, "Require each input to hold exactly one item")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	l.w = os.Stdout
+
+	status := 0
+	lint := func(name string, r io.Reader) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			return
+		}
+		if l.lint(name, data) > 0 && status == 0 {
+			status = 1
+		}
+	}
+	if flag.NArg() == 0 {
+		lint("<stdin>", os.Stdin)
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			continue
+		}
+		lint(name, f)
+		f.Close()
+	}
+	os.Exit(status)
+}
+
+// hardMaxDepth is the nesting depth at which cborlint gives up on an item entirely.
+const hardMaxDepth = 10000
+
+type linter struct {
+	maxDepth  int
+	canonical bool
+	single    bool
+	w         io.Writer
+
+	name     string
+	data     []byte
+	off      int
+	problems int
+	deepest  int
+}
+
+// A malformedError stops linting an input, since there's no way to find where the next item starts.
+type malformedError struct {
+	off int
+	msg string
+}
+
+func (l *linter) report(off int, format string, args ...interface{}) {
+	fmt.Fprintf(l.w, "%s:%d: %s\n", l.name, off, fmt.Sprintf(format, args...))
+	l.problems++
+}
+
+// lint checks the sequence of items in data, returning the number of problems reported.
+func (l *linter) lint(name string, data []byte) int {
+	l.name, l.data, l.off, l.problems = name, data, 0, 0
+	n := 0
+	for l.off < len(data) {
+		start := l.off
+		l.deepest = 0
+		if err := l.item(0); err != nil {
+			l.report(err.off, "malformed item: %s", err.msg)
+			return l.problems
+		}
+		if l.deepest > l.maxDepth {
+			l.report(start, "item is nested %d levels deep (more than %d)", l.deepest, l.maxDepth)
+		}
+		n++
+	}
+	if l.single && n != 1 {
+		l.report(0, "expected exactly one item but found %d", n)
+	}
+	return l.problems
+}
+
+// header reads the initial byte of an item and its argument. For indefinite-length items (and the break
+// code), indefinite is true.
+func (l *linter) header() (major, info byte, arg uint64, indefinite bool, err *malformedError) {
+	if l.off >= len(l.data) {
+		return 0, 0, 0, false, &malformedError{l.off, "unexpected end of input"}
+	}
+	start := l.off
+	b := l.data[l.off]
+	major, info = b>>5, b&0x1F
+	switch {
+	case info < 24:
+		l.off++
+		return major, info, uint64(info), false, nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(l.data)-l.off-1 < n {
+			return 0, 0, 0, false, &malformedError{l.off, "unexpected end of input"}
+		}
+		for _, c := range l.data[l.off+1 : l.off+1+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		l.off += 1 + n
+		if l.canonical && major != 7 && (info > 24 && arg < 1<<(8*(n/2)) || info == 24 && arg < 24) {
+			l.report(start, "argument %d is not encoded in its shortest form", arg)
+		}
+		return major, info, arg, false, nil
+	case info == 31 && major != 0 && major != 1 && major != 6:
+		l.off++
+		if l.canonical && major != 7 {
+			l.report(start, "indefinite-length item")
+		}
+		return major, info, 0, true, nil
+	}
+	return 0, 0, 0, false, &malformedError{l.off, fmt.Sprintf("invalid additional information %d for major type %d", info, major)}
+}
+
+func (l *linter) atBreak() bool {
+	return l.off < len(l.data) && l.data[l.off] == 0xFF
+}
+
+// item checks one item (and everything inside it).
+func (l *linter) item(depth int) *malformedError {
+	start := l.off
+	major, info, arg, indefinite, err := l.header()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case 0, 1:
+		return nil
+	case 2, 3:
+		if !indefinite {
+			return l.skip(arg)
+		}
+		for !l.atBreak() {
+			chunkStart := l.off
+			m, _, n, indef, err := l.header()
+			if err != nil {
+				return err
+			}
+			if m != major || indef {
+				return &malformedError{chunkStart, "invalid chunk in indefinite-length string"}
+			}
+			if err := l.skip(n); err != nil {
+				return err
+			}
+		}
+		l.off++
+		return nil
+	case 4, 5, 6:
+		depth++
+		if depth > l.deepest {
+			l.deepest = depth
+		}
+		if depth > hardMaxDepth {
+			return &malformedError{start, "nesting too deep to check"}
+		}
+	}
+	switch major {
+	case 4:
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && l.atBreak() {
+				l.off++
+				break
+			}
+			if err := l.item(depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 5:
+		return l.mapItems(depth, arg, indefinite)
+	case 6:
+		return l.item(depth)
+	}
+	switch {
+	case indefinite:
+		return &malformedError{start, "unexpected break"}
+	case info == 24 && arg < 32:
+		return &malformedError{start, fmt.Sprintf("invalid simple value %d in two-byte form", arg)}
+	case l.canonical && info >= 25 && info <= 27 && !shortestFloat(info, arg):
+		l.report(start, "float is not encoded in its shortest form")
+	}
+	return nil
+}
+
+func (l *linter) mapItems(depth int, n uint64, indefinite bool) *malformedError {
+	var prev []byte
+	seen := make(map[string]bool)
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite && l.atBreak() {
+			l.off++
+			break
+		}
+		keyStart := l.off
+		if err := l.item(depth); err != nil {
+			return err
+		}
+		key := l.data[keyStart:l.off]
+		if seen[string(key)] {
+			l.report(keyStart, "duplicate map key")
+		}
+		seen[string(key)] = true
+		if l.canonical && prev != nil && !keyLess(prev, key) {
+			l.report(keyStart, "map keys are not in canonical order")
+		}
+		prev = key
+		if l.atBreak() {
+			return &malformedError{l.off, "map key without a value"}
+		}
+		if err := l.item(depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *linter) skip(n uint64) *malformedError {
+	if uint64(len(l.data)-l.off) < n {
+		return &malformedError{l.off, "unexpected end of input"}
+	}
+	l.off += int(n)
+	return nil
+}
+
+// keyLess reports whether encoded key a sorts before b in canonical order: shorter keys first, then
+// bytewise.
+func keyLess(a, b []byte) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return bytes.Compare(a, b) < 0
+}
+
+// shortestFloat reports whether the float with the given bits, encoded with additional information info,
+// could not have been encoded in fewer bytes without losing information.
+func shortestFloat(info byte, bits uint64) bool {
+	var f float64
+	switch info {
+	case 25:
+		return true
+	case 26:
+		f = float64(math.Float32frombits(uint32(bits)))
+	case 27:
+		f = math.Float64frombits(bits)
+		if float64(float32(f)) == f || math.IsNaN(f) {
+			return false
+		}
+		return true
+	}
+	return !fitsFloat16(f)
+}
+
+// fitsFloat16 reports whether f can be represented exactly as an IEEE 754 half-precision float.
+func fitsFloat16(f float64) bool {
+	if f == 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return true
+	}
+	frac, exp := math.Frexp(math.Abs(f))
+	switch {
+	case exp-1 > 15:
+		return false
+	case exp-1 >= -14:
+		// Normal numbers have 10 bits after the leading 1.
+		m := frac * 2048
+		return m == math.Trunc(m)
+	}
+	// Subnormal numbers are multiples of 2^-24.
+	m := math.Abs(f) * (1 << 24)
+	return m == math.Trunc(m)
+}
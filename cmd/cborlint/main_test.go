@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"00", nil},
+		{"0001a161610183010203", nil},
+		{"f93c00", nil},
+		{"fa47c35000", nil},
+		{"1801", []string{"x:0: argument 1 is not encoded in its shortest form"}},
+		{"19000a", []string{"x:0: argument 10 is not encoded in its shortest form"}},
+		{"fa3f800000", []string{"x:0: float is not encoded in its shortest form"}},
+		{"fb3ff0000000000000", []string{"x:0: float is not encoded in its shortest form"}},
+		{"9f01ff", []string{"x:0: indefinite-length item"}},
+		{"a201020103", []string{"x:3: duplicate map key", "x:3: map keys are not in canonical order"}},
+		{"a2616201616101", []string{"x:4: map keys are not in canonical order"}},
+		{"a2616101186401", []string{"x:4: map keys are not in canonical order"}},
+		{"818181818100", []string{"x:0: item is nested 5 levels deep (more than 4)"}},
+		{"8301", []string{"x:2: malformed item: unexpected end of input"}},
+		{"1c", []string{"x:0: malformed item: invalid additional information 28 for major type 0"}},
+		{"bf01ff", []string{"x:0: indefinite-length item", "x:2: malformed item: map key without a value"}},
+		{"ff", []string{"x:0: malformed item: unexpected break"}},
+	} {
+		var buf bytes.Buffer
+		l := &linter{maxDepth: 4, canonical: true, w: &buf}
+		data, err := hex.DecodeString(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := l.lint("x", data)
+		var actual []string
+		if buf.Len() > 0 {
+			actual = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		}
+		if n != len(actual) || strings.Join(actual, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: expected problems\n%s\ngot (%d)\n%s", test.input,
+				strings.Join(test.expected, "\n"), n, strings.Join(actual, "\n"))
+		}
+	}
+}
+
+func TestLintSingle(t *testing.T) {
+	var buf bytes.Buffer
+	l := &linter{maxDepth: 4, single: true, w: &buf}
+	if n := l.lint("x", []byte{0x01, 0x02}); n != 1 {
+		t.Errorf("expected 1 problem; got %d: %s", n, buf.String())
+	}
+}
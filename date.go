@@ -0,0 +1,63 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// A Date is a calendar date, without a time of day or a time zone, such as a date of birth. Dates are
+// encoded with the tags of RFC 8943, in the form chosen with Encoder.SetDateFormat.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date on which t falls, in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{y, m, d}
+}
+
+// Time returns the start of the day d in UTC. Out-of-range months and days are normalized, as with
+// time.Date.
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// String returns d in the form YYYY-MM-DD.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// A DateFormat says how Date values are encoded.
+type DateFormat int
+
+const (
+	// DateString encodes dates as tag 1004 with an RFC 3339 full-date string ("YYYY-MM-DD"), which only
+	// covers the years 0 through 9999. This is the default.
+	DateString DateFormat = iota
+	// DateDays encodes dates as tag 100 with the integer number of days since 1970-01-01.
+	DateDays
+)
+
+var dateType = reflect.TypeOf(Date{})
+
+func (e *encodeState) writeDate(d Date) {
+	t := d.Time()
+	switch e.dateFormat {
+	case DateDays:
+		e.writeMajorWithNumber(typeTag, tagDays)
+		e.writeInt(t.Unix() / (24 * 60 * 60))
+	default:
+		if t.Year() < 0 || t.Year() > 9999 {
+			e.error(&UnsupportedValueError{
+				Value: reflect.ValueOf(d),
+				Str:   fmt.Sprintf("date %v outside the range of full-date strings", d),
+			})
+		}
+		e.writeMajorWithNumber(typeTag, tagFullDate)
+		e.writeTextString(DateOf(t).String())
+	}
+}
@@ -0,0 +1,428 @@
+package cbor
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A DiagnosticEncoder writes CBOR in indented diagnostic notation (RFC 8949, section 8, with the extensions
+// of RFC 8610, appendix G). It reads its input incrementally, holding no more than a small buffer in memory,
+// so that captures of any size can be rendered as text for reading or grepping.
+//
+// Encoding details that aren't the preferred serialization, like indefinite lengths and integers or floats
+// wider than necessary, are recorded with encoding indicators (such as [_ 1, 2] and 1_1), so the original
+// bytes can be reproduced exactly, except for NaNs: every NaN is written as NaN (with its width if that isn't
+// half precision), since diagnostic notation can't express a NaN's sign or payload.
+type DiagnosticEncoder struct {
+	w      io.Writer
+	indent string
+}
+
+// NewDiagnosticEncoder returns a DiagnosticEncoder that writes to w.
+func NewDiagnosticEncoder(w io.Writer) *DiagnosticEncoder {
+	return &DiagnosticEncoder{w: w, indent: "  "}
+}
+
+// SetIndent sets the string written once for each level of nesting before the elements of arrays and maps.
+// The default is two spaces.
+func (d *DiagnosticEncoder) SetIndent(indent string) {
+	d.indent = indent
+}
+
+// EncodeFrom reads a CBOR sequence (zero or more items, one after another) from r until EOF and writes the
+// items, one per line and separated by commas. If the input is malformed, EncodeFrom returns a
+// *SyntaxError or *UnexpectedEOFError after writing whatever it rendered before the problem.
+func (d *DiagnosticEncoder) EncodeFrom(r io.Reader) error {
+	s := &diagState{
+		r:      bufio.NewReaderSize(r, 64<<10),
+		w:      bufio.NewWriter(d.w),
+		indent: d.indent,
+	}
+	err := s.sequence()
+	if ferr := s.w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// diagState is the state of one call to EncodeFrom. Write errors are kept by w and reported when it's
+// flushed.
+type diagState struct {
+	r      *bufio.Reader
+	w      *bufio.Writer
+	indent string
+	off    int64 // bytes of input consumed
+	depth  int
+}
+
+func (s *diagState) sequence() error {
+	for i := 0; ; i++ {
+		if _, err := s.r.Peek(1); err == io.EOF {
+			if i > 0 {
+				s.w.WriteString("\n")
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if i > 0 {
+			s.w.WriteString(",\n")
+		}
+		if err := s.item(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *diagState) errorf(off int64, format string, args ...interface{}) error {
+	return &SyntaxError{fmt.Sprintf(format, args...), off, ErrMalformed}
+}
+
+// read calls fn with the next n bytes of input, a piece at a time. The pieces are only valid during the call.
+func (s *diagState) read(n uint64, fn func([]byte)) error {
+	for n > 0 {
+		want := int(min(n, uint64(s.r.Size())))
+		b, err := s.r.Peek(want)
+		if len(b) < want {
+			if err == io.EOF {
+				return &UnexpectedEOFError{s.off + int64(len(b)), int(min(n-uint64(len(b)), math.MaxInt))}
+			}
+			return err
+		}
+		fn(b)
+		s.r.Discard(want)
+		s.off += int64(want)
+		n -= uint64(want)
+	}
+	return nil
+}
+
+// header reads the initial byte of an item and its argument. The returned indicator is the encoding
+// indicator ("_0" through "_3") needed if the argument isn't encoded in its shortest form, "_" for an
+// indefinite length, or "".
+func (s *diagState) header() (major, info byte, arg uint64, indicator string, err error) {
+	start := s.off
+	var b byte
+	if err := s.read(1, func(p []byte) { b = p[0] }); err != nil {
+		return 0, 0, 0, "", err
+	}
+	major, info = b>>5, b&0x1F
+	switch {
+	case info < 24:
+		return major, info, uint64(info), "", nil
+	case info <= 27:
+		err := s.read(1<<(info-24), func(p []byte) {
+			for _, c := range p {
+				arg = arg<<8 | uint64(c)
+			}
+		})
+		if err != nil {
+			return 0, 0, 0, "", err
+		}
+		if major != typeMajor7 && shortestInfo(arg) != info {
+			indicator = fmt.Sprintf("_%d", info-24)
+		}
+		return major, info, arg, indicator, nil
+	case info == indefiniteLength:
+		switch major {
+		case typePosInt, typeNegInt, typeTag:
+			return 0, 0, 0, "", s.errorf(start, "indefinite length not allowed for major type %d", major)
+		}
+		return major, info, 0, "_", nil
+	}
+	return 0, 0, 0, "", s.errorf(start, "reserved additional information value %d", info)
+}
+
+// shortestInfo returns the additional information used by the shortest encoding of arg.
+func shortestInfo(arg uint64) byte {
+	switch {
+	case arg < 24:
+		return byte(arg)
+	case arg <= math.MaxUint8:
+		return 24
+	case arg <= math.MaxUint16:
+		return 25
+	case arg <= math.MaxUint32:
+		return 26
+	}
+	return 27
+}
+
+func (s *diagState) atBreak() bool {
+	b, err := s.r.Peek(1)
+	return err == nil && b[0] == makeIDByte(typeMajor7, typeBreak)
+}
+
+func (s *diagState) skipBreak() {
+	s.r.Discard(1)
+	s.off++
+}
+
+func (s *diagState) newline() {
+	s.w.WriteString("\n")
+	for i := 0; i < s.depth; i++ {
+		s.w.WriteString(s.indent)
+	}
+}
+
+func (s *diagState) item() error {
+	start := s.off
+	major, info, arg, indicator, err := s.header()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case typePosInt:
+		s.w.WriteString(strconv.FormatUint(arg, 10) + indicator)
+	case typeNegInt:
+		if arg == math.MaxUint64 {
+			s.w.WriteString("-18446744073709551616" + indicator)
+		} else {
+			s.w.WriteString("-" + strconv.FormatUint(arg+1, 10) + indicator)
+		}
+	case typeByteString, typeTextString:
+		if indicator != "_" {
+			return s.str(major, arg, indicator)
+		}
+		if s.atBreak() {
+			s.skipBreak()
+			if major == typeByteString {
+				s.w.WriteString("''_")
+			} else {
+				s.w.WriteString(`""_`)
+			}
+			return nil
+		}
+		s.w.WriteString("(_ ")
+		for i := 0; !s.atBreak(); i++ {
+			if i > 0 {
+				s.w.WriteString(", ")
+			}
+			chunkStart := s.off
+			m, _, n, ind, err := s.header()
+			if err != nil {
+				return err
+			}
+			if m != major || ind == "_" {
+				return s.errorf(chunkStart, "invalid chunk in indefinite-length string")
+			}
+			if err := s.str(major, n, ind); err != nil {
+				return err
+			}
+		}
+		s.skipBreak()
+		s.w.WriteString(")")
+	case typeList, typeMap:
+		open, close := "[", "]"
+		if major == typeMap {
+			open, close = "{", "}"
+		}
+		s.w.WriteString(open + indicator)
+		if s.depth++; s.depth > maxNestingDepth {
+			return &SyntaxError{"exceeded max depth", start, ErrMaxDepth}
+		}
+		n := arg
+		if major == typeMap {
+			n *= 2
+		}
+		i := uint64(0)
+		for ; indicator == "_" || i < n; i++ {
+			if indicator == "_" && s.atBreak() && (major == typeList || i%2 == 0) {
+				s.skipBreak()
+				break
+			}
+			switch {
+			case i%2 == 1 && major == typeMap:
+				s.w.WriteString(": ")
+			case i > 0:
+				s.w.WriteString(",")
+				fallthrough
+			default:
+				s.newline()
+			}
+			if err := s.item(); err != nil {
+				return err
+			}
+		}
+		s.depth--
+		if i > 0 {
+			s.newline()
+		}
+		s.w.WriteString(close)
+	case typeTag:
+		s.w.WriteString(strconv.FormatUint(arg, 10) + indicator + "(")
+		if s.depth++; s.depth > maxNestingDepth {
+			return &SyntaxError{"exceeded max depth", start, ErrMaxDepth}
+		}
+		if err := s.item(); err != nil {
+			return err
+		}
+		s.depth--
+		s.w.WriteString(")")
+	default:
+		return s.simple(start, info, arg, indicator)
+	}
+	return nil
+}
+
+// str writes a string of n bytes, which may be much larger than memory.
+func (s *diagState) str(major byte, n uint64, indicator string) error {
+	start := s.off
+	if major == typeByteString {
+		s.w.WriteString("h'")
+		enc := hex.NewEncoder(s.w)
+		if err := s.read(n, func(b []byte) { enc.Write(b) }); err != nil {
+			return err
+		}
+		s.w.WriteString("'" + indicator)
+		return nil
+	}
+	s.w.WriteString(`"`)
+	// A rune may be split between pieces; its first bytes are held in partial until the rest arrive.
+	var partial []byte
+	valid := true
+	err := s.read(n, func(b []byte) {
+		if !valid {
+			return
+		}
+		if len(partial) > 0 {
+			need := 0
+			for need < len(b) && need < utf8.UTFMax && !utf8.FullRune(partial) {
+				partial = append(partial, b[need])
+				need++
+			}
+			if !utf8.FullRune(partial) {
+				return
+			}
+			valid = writeQuoted(s.w, partial)
+			partial = partial[:0]
+			b = b[need:]
+		}
+		cut := len(b)
+		for i := len(b) - 1; i >= 0 && i > len(b)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(b[i]) {
+				if !utf8.FullRune(b[i:]) {
+					cut = i
+				}
+				break
+			}
+		}
+		if valid = valid && writeQuoted(s.w, b[:cut]); valid {
+			partial = append(partial, b[cut:]...)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if !valid || len(partial) > 0 {
+		return &SyntaxError{"invalid UTF-8 in text string", start, ErrInvalidUTF8}
+	}
+	s.w.WriteString(`"` + indicator)
+	return nil
+}
+
+// writeQuoted writes the text b with the escaping of a JSON string, reporting false if b isn't valid UTF-8.
+func writeQuoted(w *bufio.Writer, b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		switch {
+		case r == '"' || r == '\\':
+			w.WriteByte('\\')
+			w.WriteRune(r)
+		case r == '\n':
+			w.WriteString(`\n`)
+		case r == '\t':
+			w.WriteString(`\t`)
+		case r < 0x20 || r == 0x7F:
+			fmt.Fprintf(w, `\u%04x`, r)
+		default:
+			w.WriteRune(r)
+		}
+	}
+	return true
+}
+
+func (s *diagState) simple(start int64, info byte, arg uint64, indicator string) error {
+	switch {
+	case indicator == "_":
+		return s.errorf(start, "unexpected break")
+	case info == 24 && arg < 32:
+		return s.errorf(start, "invalid simple value %d in two-byte form", arg)
+	}
+	switch info {
+	case typeFalse:
+		s.w.WriteString("false")
+	case typeTrue:
+		s.w.WriteString("true")
+	case typeNull:
+		s.w.WriteString("null")
+	case typeUndefined:
+		s.w.WriteString("undefined")
+	case typeFloat16, typeFloat32, typeFloat64:
+		v := floatValue(info, arg)
+		str := formatDiagFloat(v)
+		if info != shortestFloatInfo(v) {
+			str += fmt.Sprintf("_%d", info-24)
+		}
+		s.w.WriteString(str)
+	default:
+		fmt.Fprintf(s.w, "simple(%d)", arg)
+	}
+	return nil
+}
+
+// formatDiagFloat formats v so that it is distinguishable from an integer and parses back to exactly v.
+func formatDiagFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	str := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".e") {
+		str += ".0"
+	}
+	return str
+}
+
+// shortestFloatInfo returns the additional information of the narrowest float encoding that represents v
+// exactly.
+func shortestFloatInfo(v float64) byte {
+	switch {
+	case fitsFloat16(v):
+		return typeFloat16
+	case float64(float32(v)) == v:
+		return typeFloat32
+	}
+	return typeFloat64
+}
+
+// fitsFloat16 reports whether f can be represented exactly as an IEEE 754 half-precision float.
+func fitsFloat16(f float64) bool {
+	if f == 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return true
+	}
+	frac, exp := math.Frexp(math.Abs(f))
+	switch {
+	case exp-1 > 15:
+		return false
+	case exp-1 >= -14:
+		// Normal numbers have 10 bits after the leading 1.
+		m := frac * 2048
+		return m == math.Trunc(m)
+	}
+	// Subnormal numbers are multiples of 2^-24.
+	m := math.Abs(f) * (1 << 24)
+	return m == math.Trunc(m)
+}
@@ -0,0 +1,80 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticEncoder(t *testing.T) {
+	for _, test := range []struct {
+		input    string // hex
+		expected string
+	}{
+		{"", ""},
+		{"0001", "0,\n1\n"},
+		{"3bffffffffffffffff", "-18446744073709551616\n"},
+		{"1817", "23_0\n"},
+		{"fa3f800000", "1.0_2\n"},
+		{"f97e00", "NaN\n"},
+		{"f4f5f6f7f0f8ff", "false,\ntrue,\nnull,\nundefined,\nsimple(16),\nsimple(255)\n"},
+		{"6b0a09c3bce6b0b4f0908591", "\"\\n\\tü水\U00010151\"\n"},
+		{"5f42010243030405ff", "(_ h'0102', h'030405')\n"},
+		{"a26161016162820203", "{\n  \"a\": 1,\n  \"b\": [\n    2,\n    3\n  ]\n}\n"},
+		{"9f01bf6141f5ffff", "[_\n  1,\n  {_\n    \"A\": true\n  }\n]\n"},
+		{"c1d9000101", "1(1_1(1))\n"},
+	} {
+		var buf bytes.Buffer
+		if err := NewDiagnosticEncoder(&buf).EncodeFrom(bytes.NewReader(mustDecodeHex(t, test.input))); err != nil {
+			t.Errorf("%s: %s", test.input, err)
+			continue
+		}
+		if buf.String() != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.input, test.expected, buf.String())
+		}
+	}
+}
+
+func TestDiagnosticEncoderLongStrings(t *testing.T) {
+	// These are longer than the input buffer, so runes are split between the pieces that are read.
+	s := strings.Repeat("水", 30000)
+	var buf bytes.Buffer
+	enc := NewDiagnosticEncoder(&buf)
+	enc.SetIndent("\t")
+	if err := enc.EncodeFrom(bytes.NewReader(mustMarshal(t, []interface{}{s, []byte(s)}))); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[\n\t\"" + s + "\",\n\th'" + strings.Repeat("e6b0b4", 30000) + "'\n]\n"
+	if buf.String() != expected {
+		t.Errorf("got %d bytes of unexpected output", buf.Len())
+	}
+
+	b := mustMarshal(t, s)
+	b[len(b)-3] = 'x' // breaks up a rune in the second piece
+	if err := NewDiagnosticEncoder(&buf).EncodeFrom(bytes.NewReader(b)); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("expected ErrInvalidUTF8; got %v", err)
+	}
+}
+
+func TestDiagnosticEncoderErrors(t *testing.T) {
+	for _, test := range []struct {
+		input    string // hex
+		expected error
+	}{
+		{"1c", ErrMalformed},
+		{"8301", ErrTruncated},
+		{"ff", ErrMalformed},
+		{"9f01", ErrTruncated},
+		{"5f01ff", ErrMalformed},
+		{"62c328", ErrInvalidUTF8},
+		{"f818", ErrMalformed},
+		{strings.Repeat("81", maxNestingDepth+1) + "00", ErrMaxDepth},
+	} {
+		var buf bytes.Buffer
+		err := NewDiagnosticEncoder(&buf).EncodeFrom(bytes.NewReader(mustDecodeHex(t, test.input)))
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%.20s: expected %v; got %v", test.input, test.expected, err)
+		}
+	}
+}
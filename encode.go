@@ -2,12 +2,24 @@ package cbor
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"iter"
 	"math"
+	"math/big"
+	"net/netip"
 	"reflect"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -20,31 +32,102 @@ func Marshal(v interface{}) ([]byte, error) {
 	return e.Bytes(), nil
 }
 
+// EncodedSize returns the number of bytes that Marshal(v) would produce, without building the encoding. (Map
+// keys are still encoded, to sort them.) It's useful for checking messages against size limits and for
+// sizing buffers ahead of time.
+func EncodedSize(v interface{}) (int, error) {
+	e := &encodeState{sizeOnly: true}
+	if err := e.marshal(v); err != nil {
+		return 0, err
+	}
+	return e.size, nil
+}
+
+// MarshalToHash writes the encoding of v to h, as Marshal would produce it, a piece at a time rather than
+// building the entire encoding in memory first. The encoding is deterministic (Go maps are written with
+// their keys sorted), so the resulting hash can be used to sign or compare very large values. If it returns an
+// error, some of the encoding may have been written to h already.
+//
+// A few parts of v are still held in memory whole: the encoded keys of each Go map (to sort them), the
+// output of each Marshaler, and the contents of each MapIterator, ArrayIterator, and iter.Seq function, which
+// have to be encoded before their length can be written at the front.
+//
+// MarshalToHash takes no options: it always uses the default settings, as Marshal does, so that the signer
+// and the verifier of a hash can't end up encoding the same value differently.
+func MarshalToHash(h hash.Hash, v interface{}) error {
+	e := &encodeState{flushTo: h}
+	if err := e.marshal(v); err != nil {
+		return err
+	}
+	_, err := h.Write(e.Bytes())
+	return err
+}
+
 func (e *encodeState) error(err error) {
 	panic(err)
 }
 
+// Marshaler is implemented by types that supply their own encoding. MarshalCBOR must return a single
+// well-formed item, which is written in place of the value; other output is reported as a MarshalerError.
 type Marshaler interface {
 	MarshalCBOR() ([]byte, error)
 }
 
+// StreamMarshaler is implemented by types that encode themselves by writing to an Encoder, rather than
+// returning a new byte slice as with Marshaler. The Encoder writes directly into the encoding of the value
+// being marshaled, using the settings in effect for it; its setters and Written counts have no effect. A type
+// implementing both StreamMarshaler and Marshaler is encoded with MarshalCBORTo.
+type StreamMarshaler interface {
+	MarshalCBORTo(enc *Encoder) error
+}
+
+// MapIterator is implemented by map-like types that supply their own entries to the encoder. Such a type is
+// encoded as a map whose entries are in the order produced by CBORMapItems, rather than sorted by key.
+type MapIterator interface {
+	CBORMapItems() iter.Seq2[interface{}, interface{}]
+}
+
+// ArrayIterator is implemented by types that supply the elements of an array to the encoder one at a time,
+// so large arrays can be generated lazily rather than built as a slice first. Such a type is encoded as an
+// array of the elements produced by CBORArrayItems. Functions of type iter.Seq[T], for any T that can be
+// encoded, are encoded the same way.
+type ArrayIterator interface {
+	CBORArrayItems() iter.Seq[interface{}]
+}
+
 type UnsupportedTypeError struct {
 	Type reflect.Type
+	Path string // where Type was found within the type passed to CanMarshal, if not at the top level
 }
 
 func (e *UnsupportedTypeError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("cbor: unsupported type: %s (at %s)", e.Type, e.Path)
+	}
 	return fmt.Sprintf("cbor: unsupported type: %s", e.Type)
 }
 
+func (e *UnsupportedTypeError) Unwrap() error {
+	return ErrUnsupportedType
+}
+
 type UnsupportedValueError struct {
 	Value reflect.Value
 	Str   string
+	err   error // a more specific sentinel error, if any
 }
 
 func (e *UnsupportedValueError) Error() string {
 	return fmt.Sprintf("cbor: unsupported value: %s", e.Str)
 }
 
+func (e *UnsupportedValueError) Unwrap() []error {
+	if e.err != nil {
+		return []error{ErrUnsupportedValue, e.err}
+	}
+	return []error{ErrUnsupportedValue}
+}
+
 type InvalidUTF8Error struct {
 	Str string
 }
@@ -53,6 +136,10 @@ func (e *InvalidUTF8Error) Error() string {
 	return fmt.Sprintf("cbor: string is not valid UTF-8: %s", e.Str)
 }
 
+func (e *InvalidUTF8Error) Unwrap() error {
+	return ErrInvalidUTF8
+}
+
 type MarshalerError struct {
 	Type reflect.Type
 	Err  error
@@ -62,11 +149,32 @@ func (e *MarshalerError) Error() string {
 	return fmt.Sprintf("cbor: error calling MarshalCBOR for type %s: %s", e.Type, e.Err)
 }
 
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
+
 func (e *encodeState) reflectValue(v reflect.Value) {
 	if !v.IsValid() {
 		e.writeSimple(typeNull)
 		return
 	}
+	if s, ok := e.simpleValue(v); ok {
+		e.writeMajorWithNumber(typeMajor7, uint64(s))
+		return
+	}
+	sm, ok := v.Interface().(StreamMarshaler)
+	if !ok && v.Kind() != reflect.Ptr && v.CanAddr() {
+		sm, ok = v.Addr().Interface().(StreamMarshaler)
+		if ok {
+			v = v.Addr()
+		}
+	}
+	if ok && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		if err := sm.MarshalCBORTo(&Encoder{encOpts: e.encOpts, inner: e}); err != nil {
+			e.error(&MarshalerError{v.Type(), err})
+		}
+		return
+	}
 	m, ok := v.Interface().(Marshaler)
 	if !ok {
 		// T isn't a Marshaler. Check *T as well.
@@ -77,15 +185,69 @@ func (e *encodeState) reflectValue(v reflect.Value) {
 			}
 		}
 	}
-	if ok && (v.Kind() != reflect.Ptr && !v.IsNil()) {
+	if ok && (v.Kind() != reflect.Ptr || !v.IsNil()) {
 		b, err := m.MarshalCBOR()
+		if err == nil {
+			// Like encoding/json with MarshalJSON, check that the output is a single well-formed item, so
+			// that a bad Marshaler can't corrupt the rest of the encoding.
+			err = checkValid(b)
+		}
 		if err != nil {
-			// TODO: encoding/json parses the output of MarshalJSON here to check its validity. Do we want to do
-			// that? (Punt until after a reasonable decoder is written, anyway.)
-			e.Write(b)
-			return
+			e.error(&MarshalerError{v.Type(), err})
 		}
-		e.error(&MarshalerError{v.Type(), err})
+		e.Write(b)
+		return
+	}
+	mi, ok := v.Interface().(MapIterator)
+	if !ok && v.Kind() != reflect.Ptr && v.CanAddr() {
+		mi, ok = v.Addr().Interface().(MapIterator)
+		if ok {
+			v = v.Addr()
+		}
+	}
+	if ok {
+		switch v.Kind() {
+		case reflect.Map, reflect.Ptr, reflect.Slice:
+			if v.IsNil() {
+				e.writeSimple(typeNull)
+				return
+			}
+		}
+		e.enter(v)
+		defer e.leave()
+		e.writeMapItems(mi.CBORMapItems())
+		return
+	}
+	ai, ok := v.Interface().(ArrayIterator)
+	if !ok && v.Kind() != reflect.Ptr && v.CanAddr() {
+		ai, ok = v.Addr().Interface().(ArrayIterator)
+		if ok {
+			v = v.Addr()
+		}
+	}
+	if ok {
+		switch v.Kind() {
+		case reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice:
+			if v.IsNil() {
+				e.writeSimple(typeNull)
+				return
+			}
+		}
+		e.enter(v)
+		defer e.leave()
+		e.writeArrayItems(func(yield func(reflect.Value) bool) {
+			for item := range ai.CBORArrayItems() {
+				if !yield(reflect.ValueOf(item)) {
+					return
+				}
+			}
+		})
+		return
+	}
+
+	if e.stringerEnums && isStringerEnum(v.Type()) {
+		e.reflectValue(reflect.ValueOf(v.Interface().(fmt.Stringer).String()))
+		return
 	}
 
 	switch v.Kind() {
@@ -97,14 +259,11 @@ func (e *encodeState) reflectValue(v reflect.Value) {
 			e.writeSimple(typeFalse)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i := v.Int()
-		typ := typePosInt
-		negative := i < 0
-		if negative {
-			i = -1 - i
-			typ = typeNegInt
-		}
-		e.writeMajorWithNumber(typ, uint64(i))
+		if v.Type() == durationType {
+			e.writeDuration(Duration(v.Int()))
+			return
+		}
+		e.writeInt(v.Int())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		e.writeMajorWithNumber(typePosInt, v.Uint())
 
@@ -113,37 +272,69 @@ func (e *encodeState) reflectValue(v reflect.Value) {
 		e.WriteByte(makeIDByte(typeMajor7, additionalLength[4]))
 		e.putUint32(math.Float32bits(float32(v.Float())))
 	case reflect.Float64:
-		f := v.Float()
-		f32 := float32(f)
-		// See if f is representable as a float32.
-		if f == float64(f32) {
-			e.WriteByte(makeIDByte(typeMajor7, additionalLength[4]))
-			e.putUint32(math.Float32bits(f32))
+		e.writeFloat64(v.Float())
+	case reflect.String:
+		if v.Type() == jsonNumberType {
+			e.writeJSONNumber(v)
 			return
 		}
-		e.WriteByte(makeIDByte(typeMajor7, additionalLength[8]))
-		e.putUint64(math.Float64bits(v.Float()))
-	case reflect.String:
 		s := v.String()
 		if !utf8.ValidString(s) {
 			e.error(&InvalidUTF8Error{s})
 		}
-		e.writeMajorWithNumber(typeTextString, uint64(len(s)))
-		e.WriteString(s)
+		e.writeTextString(s)
 	case reflect.Struct:
+		switch v.Type() {
+		case bigIntType:
+			n := v.Interface().(big.Int)
+			e.writeBigInt(&n)
+			return
+		case bigRatType:
+			r := v.Interface().(big.Rat)
+			e.writeRat(&r)
+			return
+		case timeType:
+			e.writeTime(v.Interface().(time.Time))
+			return
+		case dateType:
+			e.writeDate(v.Interface().(Date))
+			return
+		case extendedTimeType:
+			e.writeExtendedTime(v.Interface().(ExtendedTime))
+			return
+		case netipAddrType:
+			e.writeAddr(v.Interface().(netip.Addr))
+			return
+		case netipPrefixType:
+			e.writePrefix(v.Interface().(netip.Prefix))
+			return
+		}
+		e.enter(v)
+		defer e.leave()
 		allFields := cachedFieldsForType(v.Type())
 		fields := make([]structKeyValPair, 0, len(allFields))
-		for _, f := range allFields {
+		for i, f := range allFields {
 			value := v.Field(f.index)
 			if !value.IsValid() || f.omitEmpty && isEmptyValue(value) {
 				continue
 			}
-			fields = append(fields, structKeyValPair{f.name, value})
+			fields = append(fields, structKeyValPair{&allFields[i], value})
 		}
 		e.writeMajorWithNumber(typeMap, uint64(len(fields)))
 		for _, f := range fields {
-			e.writeMajorWithNumber(typeTextString, uint64(len(f.key)))
-			e.WriteString(f.key)
+			if e.byteStringFieldNames {
+				e.Write(f.key.byteStringKey)
+			} else {
+				e.Write(f.key.key)
+			}
+			if f.key.undefined && isUnset(f.value) {
+				e.writeSimple(typeUndefined)
+				continue
+			}
+			if f.key.intSize > 0 {
+				e.writeSizedInt(f.value, f.key.intSize)
+				continue
+			}
 			e.reflectValue(f.value)
 		}
 	case reflect.Slice:
@@ -151,16 +342,30 @@ func (e *encodeState) reflectValue(v reflect.Value) {
 			e.writeSimple(typeNull)
 			return
 		}
-		if v.Type().Elem().Kind() == reflect.Uint8 {
-			// Byte slices are encoded as byte strings, not lists.
+		if v.Type() == jsonRawMessageType {
+			// JSON fragments are embedded as a tagged byte string.
 			s := v.Bytes()
+			if !json.Valid(s) {
+				e.error(&UnsupportedValueError{Value: v, Str: "json.RawMessage containing invalid JSON"})
+			}
+			e.writeMajorWithNumber(typeTag, tagEmbeddedJSON)
 			e.writeMajorWithNumber(typeByteString, uint64(len(s)))
 			e.Write(s)
 			return
 		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as byte strings, not lists.
+			e.writeByteString(v.Bytes())
+			return
+		}
 		// Slices can be nil (null in CBOR) but otherwise are handled the same way as arrays.
 		fallthrough
 	case reflect.Array:
+		if e.typedArrays && e.writeTypedArray(v) {
+			return
+		}
+		e.enter(v)
+		defer e.leave()
 		n := v.Len()
 		e.writeMajorWithNumber(typeList, uint64(n))
 		for i := 0; i < n; i++ {
@@ -171,16 +376,31 @@ func (e *encodeState) reflectValue(v reflect.Value) {
 			e.writeSimple(typeNull)
 			return
 		}
+		e.enter(v)
+		defer e.leave()
+		if v.Type() == rawMessageMapType {
+			e.writeRawMessageMap(v.Interface().(map[string]RawMessage))
+			return
+		}
 		n := v.Len()
 		pairs := make(mapKeyValPairs, n)
 		for i, key := range v.MapKeys() {
-			marshaledKey, err := Marshal(key.Interface())
-			if err != nil {
-				e.error(err)
+			pairs[i] = mapKeyValPair{e.encodeKey(key), v.MapIndex(key)}
+		}
+		less := e.mapKeyOrder
+		if less == nil {
+			less = LengthFirstKeyOrder
+		}
+		sort.Slice(pairs, func(i, j int) bool { return less(pairs[i].key, pairs[j].key) })
+		for i := 1; i < n; i++ {
+			if bytes.Equal(pairs[i-1].key, pairs[i].key) {
+				e.error(&UnsupportedValueError{
+					Value: v,
+					Str:   fmt.Sprintf("map has more than one key encoded as %x", pairs[i].key),
+					err:   ErrDuplicateKey,
+				})
 			}
-			pairs[i] = mapKeyValPair{marshaledKey, v.MapIndex(key)}
 		}
-		sort.Sort(pairs)
 		e.writeMajorWithNumber(typeMap, uint64(n))
 		for _, pair := range pairs {
 			e.Write(pair.key)
@@ -191,14 +411,287 @@ func (e *encodeState) reflectValue(v reflect.Value) {
 			e.writeSimple(typeNull)
 			return
 		}
+		if v.Kind() == reflect.Ptr {
+			// A cycle may go through pointers alone, as in x = &x for an interface{} x.
+			e.enter(v)
+			defer e.leave()
+		}
 		e.reflectValue(v.Elem())
+	case reflect.Func:
+		if !isSeqType(v.Type()) {
+			e.error(&UnsupportedTypeError{Type: v.Type()})
+		}
+		if v.IsNil() {
+			e.writeSimple(typeNull)
+			return
+		}
+		e.enter(v)
+		defer e.leave()
+		e.writeArrayItems(v.Seq())
 	default:
-		e.error(&UnsupportedTypeError{v.Type()})
+		e.error(&UnsupportedTypeError{Type: v.Type()})
 	}
 }
 
+// isSeqType reports whether t is a function type like iter.Seq[T].
+func isSeqType(t reflect.Type) bool {
+	return t.Kind() == reflect.Func && t.CanSeq()
+}
+
+var (
+	jsonRawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	bigIntType         = reflect.TypeOf(big.Int{})
+	bigRatType         = reflect.TypeOf(big.Rat{})
+	stringerType       = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// quantityStringers are integer types whose String methods format a quantity rather than name an enum
+// constant, so SetStringerEnums leaves them alone.
+var quantityStringers = map[reflect.Type]bool{
+	reflect.TypeOf(time.Duration(0)): true,
+	reflect.TypeOf(fs.FileMode(0)):   true,
+}
+
 type encodeState struct {
 	bytes.Buffer
+	encOpts
+
+	// If sizeOnly is set, the encoding is not written to the buffer; size counts its length instead.
+	sizeOnly bool
+	size     int
+
+	// If flushTo is set, the buffer is written to it (and emptied) whenever it fills up to flushSize, so
+	// that the whole encoding is never held in memory.
+	flushTo io.Writer
+
+	depth    int // the number of arrays and maps that contain the current value
+	maxDepth int // the greatest depth reached
+}
+
+const flushSize = 4096
+
+func (e *encodeState) Write(p []byte) (int, error) {
+	if e.sizeOnly {
+		e.size += len(p)
+		return len(p), nil
+	}
+	if e.flushTo != nil && len(p) >= flushSize {
+		// Write large pieces directly rather than copying them into the buffer.
+		e.flush()
+		if _, err := e.flushTo.Write(p); err != nil {
+			e.error(err)
+		}
+		return len(p), nil
+	}
+	n, err := e.Buffer.Write(p)
+	e.maybeFlush()
+	return n, err
+}
+
+func (e *encodeState) WriteByte(c byte) error {
+	if e.sizeOnly {
+		e.size++
+		return nil
+	}
+	err := e.Buffer.WriteByte(c)
+	e.maybeFlush()
+	return err
+}
+
+func (e *encodeState) WriteString(s string) (int, error) {
+	if e.sizeOnly {
+		e.size += len(s)
+		return len(s), nil
+	}
+	n, err := e.Buffer.WriteString(s)
+	e.maybeFlush()
+	return n, err
+}
+
+func (e *encodeState) maybeFlush() {
+	if e.flushTo != nil && e.Len() >= flushSize {
+		e.flush()
+	}
+}
+
+// flush writes the buffered encoding to e.flushTo.
+func (e *encodeState) flush() {
+	if _, err := e.flushTo.Write(e.Bytes()); err != nil {
+		e.error(err)
+	}
+	e.Reset()
+}
+
+// encOpts holds the Encoder settings that affect how values are encoded.
+type encOpts struct {
+	// If positive, strings longer than this are encoded as indefinite-length strings made of chunks no
+	// longer than this.
+	stringChunkSize int
+	// If set, struct field names are encoded as byte strings rather than text strings.
+	byteStringFieldNames bool
+	// The order of the keys of encoded Go maps; nil means LengthFirstKeyOrder.
+	mapKeyOrder func(a, b []byte) bool
+	// If set, slices and arrays of numbers are encoded as typed arrays (RFC 8746).
+	typedArrays bool
+	timeFormat  TimeFormat
+	dateFormat  DateFormat
+	// How json.Number values are encoded.
+	jsonNumberFormat JSONNumberFormat
+	// If set, values of integer types that implement fmt.Stringer are encoded as their String.
+	stringerEnums bool
+	// If set, netip.Addr and netip.Prefix values are encoded with tags 260 and 261 rather than 52 and 54.
+	legacyIPTags bool
+	// Go values that are encoded as application-assigned simple values.
+	simpleValues *SimpleValues
+}
+
+// enter records that the array, map, or struct v is being encoded, or that the pointer v is being followed.
+// Encoding fails if they are nested too deeply, so that very deep (or cyclic) values don't overflow the stack.
+func (e *encodeState) enter(v reflect.Value) {
+	if err := e.tryEnter(v); err != nil {
+		e.error(err)
+	}
+}
+
+// tryEnter is like enter but returns the error rather than panicking with it, for encoders (such as a
+// CompiledShape's) that return their errors.
+func (e *encodeState) tryEnter(v reflect.Value) error {
+	if e.depth++; e.depth > maxNestingDepth {
+		e.depth--
+		return &UnsupportedValueError{
+			Value: v,
+			Str:   fmt.Sprintf("exceeded max nesting depth of %d", maxNestingDepth),
+			err:   ErrMaxDepth,
+		}
+	}
+	e.maxDepth = max(e.maxDepth, e.depth)
+	return nil
+}
+
+func (e *encodeState) leave() {
+	e.depth--
+}
+
+// writeMapItems writes a map containing the given entries, in order.
+func (e *encodeState) writeMapItems(items iter.Seq2[interface{}, interface{}]) {
+	// The entries must be counted before the map header can be written.
+	body := &encodeState{encOpts: e.encOpts, sizeOnly: e.sizeOnly, depth: e.depth}
+	n := 0
+	for key, value := range items {
+		body.reflectValue(reflect.ValueOf(key))
+		body.reflectValue(reflect.ValueOf(value))
+		n++
+	}
+	e.maxDepth = max(e.maxDepth, body.maxDepth)
+	e.writeMajorWithNumber(typeMap, uint64(n))
+	if e.sizeOnly {
+		e.size += body.size
+		return
+	}
+	e.Write(body.Bytes())
+}
+
+// encodeKey returns the encoding of the map key key, using the same settings as e (though keys are never
+// split into chunks). Byte arrays, such as hashes and IDs, are written as byte strings rather than as
+// arrays. Keys encoded by their own methods are checked to be single well-formed items, since a bad key
+// would corrupt the map around it, and to be in the deterministic encoding (no indefinite lengths, and
+// arguments and floats in their shortest forms), so that equal keys are written the same way.
+func (e *encodeState) encodeKey(key reflect.Value) []byte {
+	ke := &encodeState{encOpts: e.encOpts, depth: e.depth}
+	ke.stringChunkSize = 0
+	k := key
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+	}
+	if isByteArray(k.Type()) {
+		b := make([]byte, k.Len())
+		reflect.Copy(reflect.ValueOf(b), k)
+		ke.writeByteString(b)
+		return ke.Bytes()
+	}
+	ke.reflectValue(key)
+	if hasEncodingMethods(k.Type()) {
+		if err := checkValid(ke.Bytes()); err != nil {
+			e.error(&MarshalerError{k.Type(), err})
+		}
+		if err := checkDeterministic(ke.Bytes()); err != nil {
+			e.error(&UnsupportedValueError{Value: key, Str: fmt.Sprintf("map key of type %s: %s", k.Type(), err)})
+		}
+	}
+	return ke.Bytes()
+}
+
+// isPlainInt reports whether t is an integer type that is encoded as an integer, rather than by its own
+// methods.
+func isPlainInt(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return !hasEncodingMethods(t)
+	}
+	return false
+}
+
+// isStringerEnum reports whether t is an integer type that SetStringerEnums writes as its String form.
+func isStringerEnum(t reflect.Type) bool {
+	return isPlainInt(t) && t.Implements(stringerType) && !quantityStringers[t]
+}
+
+// isByteArray reports whether t is an array of bytes that is encoded as an array, rather than by its own
+// methods.
+func isByteArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8 && !hasEncodingMethods(t)
+}
+
+// hasEncodingMethods reports whether t or *t implements one of the interfaces that take over encoding.
+func hasEncodingMethods(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	for _, it := range []reflect.Type{marshalerType, streamMarshalerType, mapIteratorType, arrayIteratorType} {
+		if t.Implements(it) || pt.Implements(it) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSizedInt writes the integer v with an argument of exactly size bytes.
+func (e *encodeState) writeSizedInt(v reflect.Value, size int) {
+	major, n := byte(typePosInt), uint64(0)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i < 0 {
+			major, n = typeNegInt, uint64(-1-i)
+		} else {
+			n = uint64(i)
+		}
+	default:
+		n = v.Uint()
+	}
+	if size < 8 && n>>(8*size) != 0 {
+		e.error(&UnsupportedValueError{Value: v, Str: fmt.Sprintf("%v doesn't fit in a %d-byte integer", v, size)})
+	}
+	e.WriteByte(makeIDByte(major, additionalLength[size]))
+	for i := size - 1; i >= 0; i-- {
+		e.WriteByte(byte(n >> (8 * i)))
+	}
+}
+
+// writeArrayItems writes an array containing the given elements, in order.
+func (e *encodeState) writeArrayItems(items iter.Seq[reflect.Value]) {
+	// The elements must be counted before the array header can be written.
+	body := &encodeState{encOpts: e.encOpts, sizeOnly: e.sizeOnly, depth: e.depth}
+	n := 0
+	for item := range items {
+		body.reflectValue(item)
+		n++
+	}
+	e.maxDepth = max(e.maxDepth, body.maxDepth)
+	e.writeMajorWithNumber(typeList, uint64(n))
+	if e.sizeOnly {
+		e.size += body.size
+		return
+	}
+	e.Write(body.Bytes())
 }
 
 // makeIDByte returns a byte with the top 3 bits set to the value of major (should be < 8) and the bottom 5
@@ -208,6 +701,132 @@ func makeIDByte(major, value byte) byte {
 	return (value & 0x1F) | (major << 5)
 }
 
+// Typed array tags (RFC 8746, section 2) for big-endian elements, indexed by element kind.
+var typedArrayTags = map[reflect.Kind]uint64{
+	reflect.Uint16:  65,
+	reflect.Uint32:  66,
+	reflect.Uint64:  67,
+	reflect.Uint:    67,
+	reflect.Int8:    72,
+	reflect.Int16:   73,
+	reflect.Int32:   74,
+	reflect.Int64:   75,
+	reflect.Int:     75,
+	reflect.Float32: 81,
+	reflect.Float64: 82,
+}
+
+// writeTypedArray writes the slice or array v as a typed array if its elements are numbers, and reports
+// whether it did. (Arrays of uint8 use tag 64; slices of uint8 are always byte strings.) Ints and uints are
+// written as 64-bit elements. Elements that are encoded some other way than as numbers (by their own
+// methods, as Durations, as registered simple values, or as enum names) are left to be encoded one by one.
+func (e *encodeState) writeTypedArray(v reflect.Value) bool {
+	elemType := v.Type().Elem()
+	if hasEncodingMethods(elemType) || elemType == durationType || e.simpleValues.hasType(elemType) ||
+		e.stringerEnums && isStringerEnum(elemType) {
+		return false
+	}
+	kind := elemType.Kind()
+	tag, ok := typedArrayTags[kind]
+	if kind == reflect.Uint8 {
+		tag, ok = 64, true
+	}
+	if !ok {
+		return false
+	}
+	n := v.Len()
+	b := make([]byte, 0, n*typedArrayElemSize(kind))
+	for i := 0; i < n; i++ {
+		elem := v.Index(i)
+		switch kind {
+		case reflect.Uint8:
+			b = append(b, byte(elem.Uint()))
+		case reflect.Int8:
+			b = append(b, byte(elem.Int()))
+		case reflect.Uint16:
+			b = binary.BigEndian.AppendUint16(b, uint16(elem.Uint()))
+		case reflect.Int16:
+			b = binary.BigEndian.AppendUint16(b, uint16(elem.Int()))
+		case reflect.Uint32:
+			b = binary.BigEndian.AppendUint32(b, uint32(elem.Uint()))
+		case reflect.Int32:
+			b = binary.BigEndian.AppendUint32(b, uint32(elem.Int()))
+		case reflect.Uint64, reflect.Uint:
+			b = binary.BigEndian.AppendUint64(b, elem.Uint())
+		case reflect.Int64, reflect.Int:
+			b = binary.BigEndian.AppendUint64(b, uint64(elem.Int()))
+		case reflect.Float32:
+			b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(elem.Float())))
+		case reflect.Float64:
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(elem.Float()))
+		}
+	}
+	e.writeMajorWithNumber(typeTag, tag)
+	e.writeMajorWithNumber(typeByteString, uint64(len(b)))
+	e.Write(b)
+	return true
+}
+
+func typedArrayElemSize(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Uint8, reflect.Int8:
+		return 1
+	case reflect.Uint16, reflect.Int16:
+		return 2
+	case reflect.Uint32, reflect.Int32, reflect.Float32:
+		return 4
+	}
+	return 8
+}
+
+// writeBigInt writes n as an unsigned or negative integer if it fits, and otherwise as a bignum (tag 2 or 3).
+func (e *encodeState) writeBigInt(n *big.Int) {
+	typ, tag := byte(typePosInt), uint64(tagPosBignum)
+	if n.Sign() < 0 {
+		// Negative integers are encoded as -1-n.
+		n = new(big.Int).Not(n)
+		typ, tag = typeNegInt, tagNegBignum
+	}
+	if n.IsUint64() {
+		e.writeMajorWithNumber(typ, n.Uint64())
+		return
+	}
+	e.writeMajorWithNumber(typeTag, tag)
+	e.writeMajorWithNumber(typeByteString, uint64(len(n.Bytes())))
+	e.Write(n.Bytes())
+}
+
+// writeRat writes r as a rational number (tag 30): an array of its numerator and its (positive) denominator,
+// in lowest terms.
+func (e *encodeState) writeRat(r *big.Rat) {
+	e.writeMajorWithNumber(typeTag, tagRational)
+	e.writeMajorWithNumber(typeList, 2)
+	e.writeBigInt(r.Num())
+	e.writeBigInt(r.Denom())
+}
+
+// writeInt writes i as an unsigned or negative integer.
+func (e *encodeState) writeInt(i int64) {
+	if i < 0 {
+		e.writeMajorWithNumber(typeNegInt, uint64(-1-i))
+		return
+	}
+	e.writeMajorWithNumber(typePosInt, uint64(i))
+}
+
+// writeFloat64 writes f as a single-precision float if that represents it exactly, and as a double-precision
+// float otherwise.
+func (e *encodeState) writeFloat64(f float64) {
+	f32 := float32(f)
+	if f == float64(f32) {
+		e.WriteByte(makeIDByte(typeMajor7, additionalLength[4]))
+		e.putUint32(math.Float32bits(f32))
+		return
+	}
+	e.WriteByte(makeIDByte(typeMajor7, additionalLength[8]))
+	e.putUint64(math.Float64bits(f))
+}
+
 func (e *encodeState) writeSimple(typ byte) {
 	switch typ {
 	case typeFalse, typeTrue, typeNull, typeUndefined, typeBreak:
@@ -217,15 +836,6 @@ func (e *encodeState) writeSimple(typ byte) {
 	}
 }
 
-func (e *encodeState) putUint8(i uint8) {
-	e.WriteByte(byte(i))
-}
-
-func (e *encodeState) putUint16(i uint16) {
-	e.WriteByte(byte(i >> 8))
-	e.WriteByte(byte(i))
-}
-
 func (e *encodeState) putUint32(i uint32) {
 	e.WriteByte(byte(i >> 24))
 	e.WriteByte(byte(i >> 16))
@@ -249,22 +859,52 @@ func (e *encodeState) putUint64(i uint64) {
 // is used for number encoding as well as the lengths of arrays and maps.
 func (e *encodeState) writeMajorWithNumber(major byte, count uint64) {
 	// Canonically, numbers are put into the smallest possible representation.
-	switch {
-	case count < 24:
-		e.WriteByte(makeIDByte(major, byte(count)))
-	case count < 256:
-		e.WriteByte(makeIDByte(major, additionalLength[1]))
-		e.putUint8(uint8(count))
-	case count < 65536:
-		e.WriteByte(makeIDByte(major, additionalLength[2]))
-		e.putUint16(uint16(count))
-	case count < 4294967296:
-		e.WriteByte(makeIDByte(major, additionalLength[4]))
-		e.putUint32(uint32(count))
-	default:
-		e.WriteByte(makeIDByte(major, additionalLength[8]))
-		e.putUint64(uint64(count))
+	var buf [9]byte
+	e.Write(AppendHeader(buf[:0], MajorType(major), count))
+}
+
+// writeByteString writes b as a byte string, splitting it into chunks if stringChunkSize is set.
+func (e *encodeState) writeByteString(b []byte) {
+	size := e.stringChunkSize
+	if size <= 0 || len(b) <= size {
+		e.writeMajorWithNumber(typeByteString, uint64(len(b)))
+		e.Write(b)
+		return
 	}
+	e.WriteByte(makeIDByte(typeByteString, indefiniteLength))
+	for len(b) > 0 {
+		n := min(size, len(b))
+		e.writeMajorWithNumber(typeByteString, uint64(n))
+		e.Write(b[:n])
+		b = b[n:]
+	}
+	e.writeSimple(typeBreak)
+}
+
+// writeTextString writes s as a text string, splitting it into chunks if stringChunkSize is set. Each chunk
+// must be valid UTF-8 by itself, so chunks only end on character boundaries (and a chunk may be longer than
+// stringChunkSize if that is smaller than a single character).
+func (e *encodeState) writeTextString(s string) {
+	size := e.stringChunkSize
+	if size <= 0 || len(s) <= size {
+		e.writeMajorWithNumber(typeTextString, uint64(len(s)))
+		e.WriteString(s)
+		return
+	}
+	e.WriteByte(makeIDByte(typeTextString, indefiniteLength))
+	for len(s) > 0 {
+		n := min(size, len(s))
+		for n < len(s) && n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRuneInString(s)
+		}
+		e.writeMajorWithNumber(typeTextString, uint64(n))
+		e.WriteString(s[:n])
+		s = s[n:]
+	}
+	e.writeSimple(typeBreak)
 }
 
 func (e *encodeState) marshal(v interface{}) (err error) {
@@ -281,7 +921,7 @@ func (e *encodeState) marshal(v interface{}) (err error) {
 }
 
 type structKeyValPair struct {
-	key   string
+	key   *field
 	value reflect.Value
 }
 
@@ -292,30 +932,21 @@ type mapKeyValPair struct {
 
 type mapKeyValPairs []mapKeyValPair
 
-func (p mapKeyValPairs) Len() int { return len(p) }
-
-func (p mapKeyValPairs) Less(i, j int) bool {
-	n1 := len(p[i].key)
-	n2 := len(p[j].key)
-	switch {
-	case n1 < n2:
-		return true
-	case n1 > n2:
-		return false
+// LengthFirstKeyOrder reports whether the map key encoded as a sorts before the key encoded as b in the
+// canonical order of RFC 7049, section 3.9: shorter keys first, and keys of the same length in bytewise
+// lexicographic order. Go maps are encoded with their keys in this order by default.
+func LengthFirstKeyOrder(a, b []byte) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
 	}
-	for k := 0; k < n1; k++ {
-		d := p[i].key[k] - p[j].key[k]
-		switch {
-		case d < 0:
-			return true
-		case d > 0:
-			return false
-		}
-	}
-	return false
+	return bytes.Compare(a, b) < 0
 }
 
-func (p mapKeyValPairs) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+// BytewiseKeyOrder reports whether the map key encoded as a sorts before the key encoded as b in bytewise
+// lexicographic order, as required for deterministic encoding by RFC 8949, section 4.2.1.
+func BytewiseKeyOrder(a, b []byte) bool {
+	return bytes.Compare(a, b) < 0
+}
 
 // A field represents a single field found in a struct.
 type field struct {
@@ -325,6 +956,16 @@ type field struct {
 	index     int
 	typ       reflect.Type
 	omitEmpty bool
+	undefined bool // write undefined rather than null when the field is unset
+
+	// The encoded map key: usually the name as a text string, or an integer for keyasint fields.
+	key []byte
+	// The encoded map key when field names are written as byte strings.
+	byteStringKey []byte
+	// If nonzero, the field is an integer written with an argument of exactly this many bytes.
+	intSize int
+	// Other names that the field may be read from; it's only ever written with name.
+	aliases []string
 }
 
 // fieldsForType returns a list of fields that CBOR recognizes for the given type. Right now that just means
@@ -334,6 +975,20 @@ type field struct {
 // - Tag with "-" to ignore the field always
 // - Use "omitempty" to indicate the field should be omitted when 0, empty, etc (see encoding/json rules for
 //	 omitempty)
+// - Use "keyasint" with a numeric name (like `cbor:"-3,keyasint"`) to make the field's map key that integer
+//	 rather than a text string. The option is ignored if the name isn't an integer.
+// - Use "hexkey" with a name written in hex (like `cbor:"0x01aa,hexkey"`; the 0x is optional) to make the
+//	 field's map key a byte string holding those bytes, for protocols whose keys aren't text. The option is
+//	 ignored if the name isn't valid hex.
+// - Use "int8size", "int16size", "int32size", or "int64size" on an integer field to always write its value
+//	 with an argument of that width (1, 2, 4, or 8 bytes), even when a shorter one would do, for wire formats
+//	 that fix the width. Encoding fails if the value doesn't fit. The options are ignored for other fields.
+// - Use "alias" with a list of names separated by | (like `cbor:"new_name,alias=old_name|legacy"`) to give
+//	 the field other names, for renamed fields during a migration. The field is still encoded with its name;
+//	 the aliases are for decoding.
+// - Use "undefined" to write the field as undefined (0xf7) rather than null when it's unset: a nil pointer,
+//	 interface, map, or slice, or an Optional without a value. This is for peers that tell an explicitly
+//	 undefined field apart from a missing one. If omitempty is given too, an unset field is omitted instead.
 func fieldsForType(t reflect.Type) []field {
 	fields := []field{}
 	for i := 0; i < t.NumField(); i++ {
@@ -352,12 +1007,40 @@ func fieldsForType(t reflect.Type) []field {
 		if name == "" {
 			name = sf.Name
 		}
-		fields = append(fields, field{
+		f := field{
 			name:      name,
 			index:     i,
 			typ:       sf.Type,
 			omitEmpty: options.Contains("omitempty"),
-		})
+			undefined: options.Contains("undefined"),
+		}
+		var key, byteStringKey encodeState
+		if n, err := strconv.ParseInt(name, 10, 64); err == nil && options.Contains("keyasint") {
+			key.writeInt(n)
+			byteStringKey.writeInt(n)
+		} else if b, err := hex.DecodeString(strings.TrimPrefix(name, "0x")); err == nil && options.Contains("hexkey") {
+			key.writeByteString(b)
+			byteStringKey.writeByteString(b)
+		} else {
+			key.writeMajorWithNumber(typeTextString, uint64(len(name)))
+			key.WriteString(name)
+			byteStringKey.writeMajorWithNumber(typeByteString, uint64(len(name)))
+			byteStringKey.WriteString(name)
+		}
+		f.key = key.Bytes()
+		f.byteStringKey = byteStringKey.Bytes()
+		if aliases, ok := options.Value("alias"); ok && aliases != "" {
+			f.aliases = strings.Split(aliases, "|")
+		}
+		for _, opt := range []struct {
+			name string
+			size int
+		}{{"int8size", 1}, {"int16size", 2}, {"int32size", 4}, {"int64size", 8}} {
+			if options.Contains(opt.name) && isPlainInt(sf.Type) {
+				f.intSize = opt.size
+			}
+		}
+		fields = append(fields, f)
 	}
 	return fields
 }
@@ -390,6 +1073,19 @@ func cachedFieldsForType(t reflect.Type) []field {
 	return f
 }
 
+// isUnset reports whether v, the value of a struct field, holds no value at all, as opposed to a zero one.
+func isUnset(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	case reflect.Struct:
+		if o, ok := v.Interface().(absenter); ok {
+			return o.isAbsent()
+		}
+	}
+	return false
+}
+
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -404,6 +1100,10 @@ func isEmptyValue(v reflect.Value) bool {
 		return v.Float() == 0
 	case reflect.Interface, reflect.Ptr:
 		return v.IsNil()
+	case reflect.Struct:
+		if o, ok := v.Interface().(absenter); ok {
+			return o.isAbsent()
+		}
 	}
 	return false
 }
@@ -1,14 +1,72 @@
 package cbor
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
 	"math"
-	"regexp"
+	"math/big"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/cespare/cbor/cbortest"
 )
 
+// pairList is a MapIterator with a pointer receiver, standing in for a third-party ordered map type.
+type pairList [][2]string
+
+func (p *pairList) CBORMapItems() iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		for _, pair := range *p {
+			if !yield(pair[0], pair[1]) {
+				return
+			}
+		}
+	}
+}
+
+// countdown is an ArrayIterator producing n, n-1, ..., 1.
+type countdown int
+
+func (c countdown) CBORArrayItems() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for i := int(c); i > 0; i-- {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// upperKey is a map key type with its own encoding.
+type upperKey string
+
+func (k upperKey) MarshalCBOR() ([]byte, error) { return Marshal(strings.ToUpper(string(k))) }
+
+// badKey is a map key type whose encoding is two items rather than one.
+type badKey int
+
+func (badKey) MarshalCBOR() ([]byte, error) { return []byte{0x01, 0x02}, nil }
+
+// rawKey is a map key type encoded as its own bytes.
+type rawKey string
+
+func (k rawKey) MarshalCBOR() ([]byte, error) { return []byte(k), nil }
+
+func bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("bad integer " + s)
+	}
+	return n
+}
+
 type testCase struct {
 	input    interface{}
 	expected string // hex bytes
@@ -28,11 +86,11 @@ var rfc7049TestCases = []testCase{
 	{1000000, "1a000f4240"},
 	{1000000000000, "1b000000e8d4a51000"},
 
-	// Bignums?
-	//{18446744073709551615, "1bffffffffffffffff"},
-	//{18446744073709551616, "c249010000000000000000"},
-	//{-18446744073709551616, "3bffffffffffffffff"},
-	//{-18446744073709551617, "c349010000000000000000"},
+	// Bignums
+	{bigInt("18446744073709551615"), "1bffffffffffffffff"},
+	{bigInt("18446744073709551616"), "c249010000000000000000"},
+	{bigInt("-18446744073709551616"), "3bffffffffffffffff"},
+	{bigInt("-18446744073709551617"), "c349010000000000000000"},
 
 	// Negative integers
 	{-1, "20"},
@@ -169,6 +227,118 @@ var additionalTestCases = []testCase{
 		}{"", nil, 0, nil},
 		"a0",
 	},
+
+	// Numeric names with keyasint become integer keys.
+	{
+		struct {
+			Alg  int    `cbor:"1,keyasint"`
+			Kid  string `cbor:"-4,keyasint,omitempty"`
+			Name string `cbor:"name,keyasint"`
+			Num  int    `cbor:"3"`
+		}{-7, "k", "n", 0},
+		"a4012623616b646e616d65616e613300",
+	},
+
+	// Hex names with hexkey become byte string keys.
+	{
+		struct {
+			A int `cbor:"0x01aa,hexkey"`
+			B int `cbor:"FF,hexkey,omitempty"`
+			C int `cbor:"0xzz,hexkey"`
+		}{1, 2, 3},
+		"a34201aa0141ff026430787a7a03",
+	},
+
+	// Unset fields tagged undefined are written as undefined.
+	{
+		struct {
+			A *int          `cbor:"a,undefined"`
+			B []int         `cbor:"b,undefined"`
+			C Optional[int] `cbor:"c,undefined"`
+			D Optional[int] `cbor:"d,undefined"`
+			E int           `cbor:"e,undefined"`
+			F *int          `cbor:"f,undefined,omitempty"`
+			G []int         `cbor:"g,undefined"`
+			H interface{}   `cbor:"h"`
+		}{C: Null[int](), G: []int{}},
+		"a76161f76162f76163f66164f76165006167806168f6",
+	},
+
+	// Aliases are only for decoding.
+	{
+		struct {
+			A int `cbor:"new,alias=old|legacy,omitempty"`
+		}{1},
+		"a1636e657701",
+	},
+
+	// big.Ints use plain integers where they fit.
+	{big.NewInt(0), "00"},
+	{big.NewInt(-1), "20"},
+	{*big.NewInt(1000), "1903e8"},
+	{(*big.Int)(nil), "f6"},
+	{struct{ N *big.Int }{big.NewInt(-1000)}, "a1614e3903e7"},
+	{bigInt("-340282366920938463463374607431768211456"), "c350ffffffffffffffffffffffffffffffff"},
+
+	// big.Rats are written as tag 30, in lowest terms.
+	{big.NewRat(1, 3), "d81e820103"},
+	{big.NewRat(-6, 4), "d81e822202"},
+	{*new(big.Rat), "d81e820001"},
+	{(*big.Rat)(nil), "f6"},
+	{new(big.Rat).SetFrac(bigInt("18446744073709551616"), big.NewInt(3)), "d81e82c24901000000000000000003"},
+
+	// RawMessages are written verbatim.
+	{RawMessage{0x83, 0x01, 0x02, 0x03}, "83010203"},
+	{RawMessage(nil), "f6"},
+	{struct{ Foo RawMessage }{RawMessage{0x61, 0x61}}, "a163466f6f6161"},
+	{map[string]RawMessage{"bb": {0x01}, "a": nil, "c": {0x82, 0x01, 0x02}}, "a36161f6616382010262626201"},
+	{struct{ Env map[string]RawMessage }{map[string]RawMessage{"x": {0x01}}}, "a163456e76a1617801"},
+	{map[string]RawMessage(nil), "f6"},
+
+	// OrderedMaps keep their entries in order.
+	{OrderedMap{{"b", 1}, {"a", []int{2}}, {1, nil}}, "a36162016161810201f6"},
+	{OrderedMap{}, "a0"},
+	{OrderedMap(nil), "f6"},
+	{&pairList{{"b", "x"}, {"a", "y"}}, "a26162617861616179"},
+	{&struct{ M pairList }{pairList{{"b", "x"}}}, "a1614da161626178"},
+	{(*pairList)(nil), "f6"},
+
+	// Map keys use their own encodings.
+	{map[upperKey]int{"b": 1, "a": 2}, "a2614102614201"},
+	{map[interface{}]int{upperKey("c"): 1, "b": 2}, "a2614301616202"},
+
+	// Byte array map keys are byte strings.
+	{map[[2]byte]int{{1, 2}: 3}, "a142010203"},
+	{map[interface{}]int{[2]byte{1, 2}: 3}, "a142010203"},
+
+	// Integer width hints.
+	{struct {
+		Seq uint32 `cbor:"seq,int64size"`
+	}{5}, "a1637365711b0000000000000005"},
+	{struct {
+		N int `cbor:"n,int16size"`
+	}{-1}, "a1616e390000"},
+	{struct {
+		N int8 `cbor:"n,int8size,omitempty"`
+	}{-128}, "a1616e387f"},
+	{struct {
+		R rawInt `cbor:"r,int32size"`
+	}{1}, "a1617263313233"},
+	{struct {
+		S string `cbor:"s,int8size"`
+	}{"x"}, "a161736178"},
+
+	// Iterators are written as arrays.
+	{slices.Values([]int{1, 2, 3}), "83010203"},
+	{slices.Values([]string{}), "80"},
+	{iter.Seq[int](nil), "f6"},
+	{struct{ S iter.Seq[interface{}] }{slices.Values([]interface{}{"a", nil})}, "a16153826161f6"},
+	{countdown(3), "83030201"},
+	{[]countdown{0}, "8180"},
+
+	// json.RawMessages are embedded as tag 262.
+	{json.RawMessage(`{"a":1}`), "d90106477b2261223a317d"},
+	{json.RawMessage(nil), "f6"},
 }
 
 func TestEncoding(t *testing.T) {
@@ -192,13 +362,65 @@ func TestEncoding(t *testing.T) {
 	}
 }
 
+func TestConformance(t *testing.T) {
+	var vectors []cbortest.Vector
+	for _, v := range append(cbortest.AppendixA(), cbortest.Extended()...) {
+		// Marshal doesn't produce half-precision floats yet.
+		if v.CBOR[0] != 0xf9 {
+			vectors = append(vectors, v)
+		}
+	}
+	cbortest.CheckMarshal(t, vectors, func(v interface{}) ([]byte, error) {
+		if !marshalSupports(v) {
+			return nil, errors.ErrUnsupported
+		}
+		return Marshal(v)
+	})
+}
+
+// marshalSupports reports whether v (a cbortest value) has a Go representation that Marshal can encode.
+func marshalSupports(v interface{}) bool {
+	switch v := v.(type) {
+	case cbortest.Tag, cbortest.Simple:
+		return false
+	case []interface{}:
+		for _, elem := range v {
+			if !marshalSupports(elem) {
+				return false
+			}
+		}
+	case map[interface{}]interface{}:
+		for k, elem := range v {
+			if !marshalSupports(k) || !marshalSupports(elem) {
+				return false
+			}
+		}
+	}
+	return v != cbortest.Undefined
+}
+
 type errTestCase struct {
-	input            interface{}
-	expectedErrRegex string
+	input    interface{}
+	expected error
 }
 
 var errTestCases = []errTestCase{
-	{string([]byte{0xff, 0xfe, 0xfd}), `string is not valid UTF-8`},
+	{string([]byte{0xff, 0xfe, 0xfd}), ErrInvalidUTF8},
+	{json.RawMessage(`{"a":`), ErrUnsupportedValue},
+	{map[string]RawMessage{string([]byte{0xff}): {0x01}}, ErrInvalidUTF8},
+	{make(chan int), ErrUnsupportedType},
+	{[]interface{}{func() {}}, ErrUnsupportedType},
+	{&point{-1, 0}, errNegativeX},
+	{iter.Seq2[int, int](nil), ErrUnsupportedType},
+	{map[upperKey]int{"a": 1, "A": 2}, ErrDuplicateKey},
+	{map[badKey]int{1: 1}, ErrMalformed},
+	{map[rawKey]int{"\x18\x01": 1}, ErrUnsupportedValue},             // argument not in its shortest form
+	{map[rawKey]int{"\x7f\x61a\xff": 1}, ErrUnsupportedValue},        // indefinite-length string
+	{map[rawKey]int{"\xfa\x3f\xc0\x00\x00": 1}, ErrUnsupportedValue}, // 1.5 as a float32
+	{struct {
+		N uint `cbor:"n,int8size"`
+	}{256}, ErrUnsupportedValue},
+	{slices.Values([]chan int{nil}), ErrUnsupportedType},
 }
 
 func TestEncodingErrors(t *testing.T) {
@@ -208,13 +430,203 @@ func TestEncodingErrors(t *testing.T) {
 			t.Error("Expected an non-nil error, but err was nil.")
 			continue
 		}
-		r, err2 := regexp.Compile(test.expectedErrRegex)
-		if err2 != nil {
-			t.Error(err2)
-			continue
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected an error wrapping %q but got '%s'", test.expected, err)
 		}
-		if !r.MatchString(err.Error()) {
-			t.Errorf("Expected error to match /%s/ but got '%s'", r, err)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected error
+	}{
+		{"1a0000", ErrTruncated},
+		{"1f", ErrMalformed},
+		{"0000", ErrMalformed},
+		{strings.Repeat("81", maxNestingDepth+1) + "00", ErrMaxDepth},
+	} {
+		err := checkValid(mustDecodeHex(t, test.input))
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%.20s: got error %v; want one wrapping %q", test.input, err, test.expected)
+		}
+	}
+
+	deep := interface{}(0)
+	for i := 0; i < maxNestingDepth+1; i++ {
+		deep = []interface{}{deep}
+	}
+	_, err := Marshal(deep)
+	if !errors.Is(err, ErrMaxDepth) || !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("deeply nested value: got error %v; want one wrapping %q and %q", err, ErrMaxDepth, ErrUnsupportedValue)
+	}
+
+	_, err = JSONCompatible(map[interface{}]interface{}{1: 0, "1": 0})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("JSONCompatible with colliding keys: got error %v; want one wrapping %q", err, ErrDuplicateKey)
+	}
+}
+
+func TestCanMarshal(t *testing.T) {
+	type node struct {
+		Next     *node
+		Children []node
+	}
+	type bad struct {
+		Name  string
+		Items []struct {
+			Callback func()
+		}
+	}
+	for _, test := range []struct {
+		input interface{}
+		err   string // empty if CanMarshal should succeed
+	}{
+		{0, ""},
+		{[]byte(nil), ""},
+		{map[string][]interface{}{}, ""},
+		{node{}, ""},
+		{RawMessage(nil), ""},
+		{OrderedMap(nil), ""},
+		{pairList(nil), ""},
+		{struct{ Ch chan int }{}, "cbor: unsupported type: chan int (at .Ch)"},
+		{struct {
+			C complex64 `cbor:"c"`
+		}{}, "cbor: unsupported type: complex64 (at .c)"},
+		{struct{ c complex64 }{}, ""},
+		{bad{Items: make([]struct{ Callback func() }, 1)}, "cbor: unsupported type: func() (at .Items[].Callback)"},
+		{map[string]*[2]uintptr{"a": {}}, "cbor: unsupported type: uintptr (at [][])"},
+		{map[[1]chan int]int{{nil}: 1}, "cbor: unsupported type: chan int (at [key][])"},
+		{make(chan int), "cbor: unsupported type: chan int"},
+		{iter.Seq[int](nil), ""},
+		{countdown(0), ""},
+		{struct{ S iter.Seq[chan int] }{slices.Values([]chan int{nil})}, "cbor: unsupported type: chan int (at .S[])"},
+		{iter.Seq2[int, int](nil), "cbor: unsupported type: iter.Seq2[int,int]"},
+	} {
+		err := CanMarshal(reflect.TypeOf(test.input))
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("CanMarshal(%T): got error %q; want %q", test.input, got, test.err)
+		}
+		// CanMarshal should agree with Marshal.
+		if _, err := Marshal(test.input); (err == nil) != (test.err == "") {
+			t.Errorf("Marshal(%T): got error %v", test.input, err)
 		}
 	}
 }
+
+func TestEncodedSize(t *testing.T) {
+	for _, suite := range [][]testCase{rfc7049TestCases, additionalTestCases} {
+		for _, test := range suite {
+			n, err := EncodedSize(test.input)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if expected := len(test.expected) / 2; n != expected {
+				t.Errorf("EncodedSize(%#v): expected %d; got %d", test.input, expected, n)
+			}
+		}
+	}
+	for _, test := range errTestCases {
+		if _, err := EncodedSize(test.input); err == nil {
+			t.Errorf("EncodedSize(%#v): expected an error", test.input)
+		}
+	}
+}
+
+func TestMarshalToHash(t *testing.T) {
+	large := make(map[string]interface{})
+	for i := 0; i < 1000; i++ {
+		large[fmt.Sprint(i)] = []interface{}{i, strings.Repeat("x", i), OrderedMap{{"b", make([]byte, 5000)}}}
+	}
+	for _, v := range []interface{}{nil, 1, large} {
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := sha256.Sum256(b)
+		h := sha256.New()
+		if err := MarshalToHash(h, v); err != nil {
+			t.Fatal(err)
+		}
+		if actual := h.Sum(nil); !bytes.Equal(actual, expected[:]) {
+			t.Errorf("MarshalToHash of a %d-byte value: got hash %x; want %x", len(b), actual, expected)
+		}
+	}
+	if err := MarshalToHash(sha256.New(), make(chan int)); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
+
+func TestEncodingDepth(t *testing.T) {
+	var deep interface{} = 1
+	for i := 0; i < maxNestingDepth; i++ {
+		deep = []interface{}{deep}
+	}
+	if _, err := Marshal(deep); err != nil {
+		t.Fatalf("at max depth: %s", err)
+	}
+	deep = OrderedMap{{"a", deep}}
+	if _, err := Marshal(deep); err == nil {
+		t.Fatal("expected an error beyond the max depth")
+	}
+
+	cycle := []interface{}{nil}
+	cycle[0] = cycle
+	_, err := Marshal(cycle)
+	if _, ok := err.(*UnsupportedValueError); !ok {
+		t.Fatalf("encoding a cyclic value: got error %v; want an *UnsupportedValueError", err)
+	}
+
+	var self interface{}
+	self = &self
+	if _, err := Marshal(self); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("encoding a self-referencing interface{}: got error %v; want ErrMaxDepth", err)
+	}
+}
+
+func TestPreregister(t *testing.T) {
+	type inner struct{ A, B int }
+	type message struct {
+		In []inner
+	}
+	if err := Preregister((*message)(nil), nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	fieldCache.RLock()
+	fields := fieldCache.m[reflect.TypeOf(inner{})]
+	fieldCache.RUnlock()
+	if len(fields) != 2 {
+		t.Errorf("got %d cached fields for inner; want 2", len(fields))
+	}
+
+	err := Preregister(struct{ F func() }{}, message{}, make(chan int))
+	var unsupported *UnsupportedTypeError
+	if !errors.As(err, &unsupported) || unsupported.Path != ".F" {
+		t.Fatalf("got error %v; want an *UnsupportedTypeError for .F", err)
+	}
+	if got, want := err.Error(), "cbor: unsupported type: func() (at .F)\ncbor: unsupported type: chan int"; got != want {
+		t.Errorf("got error %q; want %q", got, want)
+	}
+}
+
+func TestFieldAliases(t *testing.T) {
+	type T struct {
+		A int `cbor:"new,omitempty,alias=old|legacy"`
+		B int `cbor:",alias=b"`
+		C int `cbor:"c,alias="`
+	}
+	fields := fieldsForType(reflect.TypeOf(T{}))
+	for i, expected := range [][]string{{"old", "legacy"}, {"b"}, nil} {
+		if !reflect.DeepEqual(fields[i].aliases, expected) {
+			t.Errorf("field %s: expected aliases %q; got %q", fields[i].name, expected, fields[i].aliases)
+		}
+	}
+	if !fields[0].omitEmpty {
+		t.Error("omitempty was lost alongside alias")
+	}
+}
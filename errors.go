@@ -0,0 +1,72 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors for the kinds of failure that callers commonly need to tell apart. The errors returned by
+// this package wrap the matching sentinel, so they can be checked with errors.Is; the concrete error types
+// (SyntaxError, UnexpectedEOFError, and so on) carry the details.
+var (
+	ErrMalformed        = errors.New("cbor: malformed input")
+	ErrTruncated        = errors.New("cbor: unexpected end of input")
+	ErrMaxDepth         = errors.New("cbor: exceeded max nesting depth")
+	ErrInvalidUTF8      = errors.New("cbor: invalid UTF-8")
+	ErrDuplicateKey     = errors.New("cbor: duplicate map key")
+	ErrUnsupportedType  = errors.New("cbor: unsupported type")
+	ErrUnsupportedValue = errors.New("cbor: unsupported value")
+)
+
+// ErrorContext returns a short hexdump of the bytes of data around the offset at which err was found, with
+// that byte marked, to make an error about malformed input actionable in messages and logs. The error must
+// be a *SyntaxError or *UnexpectedEOFError (or wrap one) that was returned for data; otherwise ErrorContext
+// returns "".
+func ErrorContext(data []byte, err error) string {
+	var off int64
+	var syntaxErr *SyntaxError
+	var eofErr *UnexpectedEOFError
+	switch {
+	case errors.As(err, &syntaxErr):
+		off = syntaxErr.Offset
+	case errors.As(err, &eofErr):
+		off = eofErr.Offset
+	default:
+		return ""
+	}
+	if off < 0 || off > int64(len(data)) {
+		return ""
+	}
+	const context = 32 // bytes shown on either side of off, before rounding out to whole lines
+	var b strings.Builder
+	end := min(int64(len(data)), off+context)
+	for line := max(0, off-context) &^ 15; line < end || line <= off; line += 16 {
+		fmt.Fprintf(&b, "%08x ", line)
+		var ascii strings.Builder
+		for i := int64(0); i < 16; i++ {
+			if i == 8 {
+				b.WriteByte(' ')
+			}
+			if line+i >= int64(len(data)) {
+				b.WriteString("   ")
+				continue
+			}
+			c := data[line+i]
+			fmt.Fprintf(&b, " %02x", c)
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			ascii.WriteByte(c)
+		}
+		fmt.Fprintf(&b, "  |%s|\n", ascii.String())
+		if line <= off && off < line+16 {
+			col := 10 + 3*int(off-line)
+			if off-line >= 8 {
+				col++
+			}
+			b.WriteString(strings.Repeat(" ", col) + "^^\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package cbor
+
+import "math"
+
+// Callbacks holds the functions called by Scan for each part of an encoded item. Nil callbacks are skipped.
+// If a callback returns an error, Scan stops and returns it.
+type Callbacks struct {
+	// OnInt is called for an integer: n itself if negative is false, or -1-n if it's true. This covers the
+	// full range of both major types, which doesn't fit in an int64.
+	OnInt func(n uint64, negative bool) error
+	// OnBytes and OnString are called for byte and text strings. The contents share the scanned data's
+	// memory. An indefinite-length string is reported one chunk at a time, with more set for every chunk but
+	// the last; an indefinite-length string with no chunks is reported as a single empty chunk.
+	OnBytes  func(b []byte, more bool) error
+	OnString func(s []byte, more bool) error
+	// OnArrayStart and OnMapStart are called before the elements of an array or the entries of a map, with
+	// the number of elements or entries, or -1 for an indefinite length. OnArrayEnd and OnMapEnd are called
+	// after the last one.
+	OnArrayStart func(n int) error
+	OnArrayEnd   func() error
+	OnMapStart   func(n int) error
+	OnMapEnd     func() error
+	// OnTag is called with a tag number before its content is scanned.
+	OnTag func(n uint64) error
+	// OnFloat is called for floating-point numbers of any precision.
+	OnFloat     func(f float64) error
+	OnBool      func(b bool) error
+	OnNull      func() error
+	OnUndefined func() error
+	// OnSimple is called for simple values other than false, true, null, and undefined.
+	OnSimple func(v byte) error
+}
+
+// Scan walks over the single item encoded in data, calling the functions in cb for each integer, string,
+// container, tag, and so on, in the order they appear, without building any values. It is meant for jobs
+// such as indexing and filtering that need to look at large amounts of data using little memory.
+//
+// The callbacks are called as the data is scanned, so if data turns out to be malformed, Scan returns an
+// error after some of them have already run.
+func Scan(data []byte, cb *Callbacks) error {
+	s := &scanner{data: data}
+	if err := cb.scan(s); err != nil {
+		return err
+	}
+	if s.off != len(data) {
+		return s.errorf("trailing data after top-level item")
+	}
+	return nil
+}
+
+func (cb *Callbacks) scan(s *scanner) error {
+	start := s.off
+	major, info, arg, indefinite, err := s.header()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case typePosInt, typeNegInt:
+		if cb.OnInt != nil {
+			return cb.OnInt(arg, major == typeNegInt)
+		}
+		return nil
+	case typeByteString, typeTextString:
+		fn := cb.OnBytes
+		if major == typeTextString {
+			fn = cb.OnString
+		}
+		if fn == nil {
+			fn = func([]byte, bool) error { return nil }
+		}
+		if !indefinite {
+			b, err := s.readBytes(arg)
+			if err != nil {
+				return err
+			}
+			return fn(b, false)
+		}
+		if s.isBreak() {
+			s.off++
+			return fn(s.data[s.off:s.off], false)
+		}
+		for {
+			chunkStart := s.off
+			m, _, n, indef, err := s.header()
+			if err != nil {
+				return err
+			}
+			if m != major || indef {
+				s.off = chunkStart
+				return s.errorf("invalid chunk in indefinite-length string")
+			}
+			b, err := s.readBytes(n)
+			if err != nil {
+				return err
+			}
+			// At the end of the input, this reports more (correctly: the break is missing), and the
+			// error comes when the next chunk is read.
+			more := !s.isBreak()
+			if err := fn(b, more); err != nil {
+				return err
+			}
+			if !more {
+				s.off++ // break
+				return nil
+			}
+		}
+	case typeList, typeMap, typeTag:
+		s.depth++
+		if s.depth > maxNestingDepth {
+			s.off = start
+			return s.depthError()
+		}
+		defer func() { s.depth-- }()
+	}
+	switch major {
+	case typeList, typeMap:
+		startFn, endFn := cb.OnArrayStart, cb.OnArrayEnd
+		perEntry := 1
+		if major == typeMap {
+			startFn, endFn = cb.OnMapStart, cb.OnMapEnd
+			perEntry = 2
+		}
+		n := -1
+		if !indefinite {
+			if remaining := uint64(len(s.data) - s.off); arg > remaining {
+				// Every item takes at least one byte.
+				return s.eof(arg - remaining)
+			}
+			n = int(arg)
+		}
+		if startFn != nil {
+			if err := startFn(n); err != nil {
+				return err
+			}
+		}
+		for i := 0; indefinite || i < n; i++ {
+			if indefinite && s.isBreak() {
+				s.off++
+				break
+			}
+			for j := 0; j < perEntry; j++ {
+				if err := cb.scan(s); err != nil {
+					return err
+				}
+			}
+		}
+		if endFn != nil {
+			return endFn()
+		}
+		return nil
+	case typeTag:
+		if cb.OnTag != nil {
+			if err := cb.OnTag(arg); err != nil {
+				return err
+			}
+		}
+		return cb.scan(s)
+	}
+	// Major type 7
+	switch {
+	case indefinite:
+		s.off = start
+		return s.errorf("unexpected break")
+	case info == 24 && arg < 32:
+		s.off = start
+		return s.errorf("invalid simple value %d in two-byte form", arg)
+	}
+	switch info {
+	case typeFalse, typeTrue:
+		if cb.OnBool != nil {
+			return cb.OnBool(info == typeTrue)
+		}
+	case typeNull:
+		if cb.OnNull != nil {
+			return cb.OnNull()
+		}
+	case typeUndefined:
+		if cb.OnUndefined != nil {
+			return cb.OnUndefined()
+		}
+	case typeFloat16, typeFloat32, typeFloat64:
+		if cb.OnFloat != nil {
+			return cb.OnFloat(floatValue(info, arg))
+		}
+	default:
+		if cb.OnSimple != nil {
+			return cb.OnSimple(byte(arg))
+		}
+	}
+	return nil
+}
+
+// floatValue returns the value of a float whose header has the given additional information and argument.
+func floatValue(info byte, arg uint64) float64 {
+	switch info {
+	case typeFloat16:
+		return float16ToFloat64(uint16(arg))
+	case typeFloat32:
+		return float64(math.Float32frombits(uint32(arg)))
+	}
+	return math.Float64frombits(arg)
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1F
+	mant := float64(h & 0x3FF)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1F:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
@@ -0,0 +1,108 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// eventLog returns Callbacks that record every event in a list.
+func eventLog(events *[]string) *Callbacks {
+	record := func(format string, args ...interface{}) error {
+		*events = append(*events, fmt.Sprintf(format, args...))
+		return nil
+	}
+	return &Callbacks{
+		OnInt: func(n uint64, negative bool) error {
+			if negative {
+				return record("int -1-%d", n)
+			}
+			return record("int %d", n)
+		},
+		OnBytes:      func(b []byte, more bool) error { return record("bytes %x %t", b, more) },
+		OnString:     func(s []byte, more bool) error { return record("string %q %t", s, more) },
+		OnArrayStart: func(n int) error { return record("array %d", n) },
+		OnArrayEnd:   func() error { return record("end array") },
+		OnMapStart:   func(n int) error { return record("map %d", n) },
+		OnMapEnd:     func() error { return record("end map") },
+		OnTag:        func(n uint64) error { return record("tag %d", n) },
+		OnFloat:      func(f float64) error { return record("float %g", f) },
+		OnBool:       func(b bool) error { return record("bool %t", b) },
+		OnNull:       func() error { return record("null") },
+		OnUndefined:  func() error { return record("undefined") },
+		OnSimple:     func(v byte) error { return record("simple %d", v) },
+	}
+}
+
+func TestScan(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{"00", "int 0"},
+		{"3bffffffffffffffff", "int -1-18446744073709551615"},
+		{"4401020304", "bytes 01020304 false"},
+		{"6161", `string "a" false`},
+		{"7f616161626163ff", `string "a" true; string "b" true; string "c" false`},
+		{"5fff", "bytes  false"},
+		{"8301820203820405", "array 3; int 1; array 2; int 2; int 3; end array; array 2; int 4; int 5; end array; end array"},
+		{"9f018202039f0405ffff", "array -1; int 1; array 2; int 2; int 3; end array; array -1; int 4; int 5; end array; end array"},
+		{"bf61610161629f0203ffff", `map -1; string "a" false; int 1; string "b" false; array -1; int 2; int 3; end array; end map`},
+		{"a201020304", "map 2; int 1; int 2; int 3; int 4; end map"},
+		{"c11a514b67b0", "tag 1; int 1363896240"},
+		{"f93c00", "float 1"},
+		{"fa47c35000", "float 100000"},
+		{"fb3ff199999999999a", "float 1.1"},
+		{"f97c00", "float +Inf"},
+		{"f4", "bool false"},
+		{"f5", "bool true"},
+		{"f6", "null"},
+		{"f7", "undefined"},
+		{"f0", "simple 16"},
+		{"f8ff", "simple 255"},
+	} {
+		var events []string
+		err := Scan(mustDecodeHex(t, test.input), eventLog(&events))
+		if err != nil {
+			t.Errorf("%s: %s", test.input, err)
+			continue
+		}
+		if actual := strings.Join(events, "; "); actual != test.expected {
+			t.Errorf("%s: expected events\n  %s\ngot\n  %s", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"f4f5",       // trailing data
+		"7f6161",     // missing break
+		"7f4161ff",   // byte string chunk in text string
+		"ff",         // stray break
+		"f801",       // invalid simple value
+		"9a7fffffff", // absurd length
+		"8201",       // truncated
+	} {
+		if err := Scan(mustDecodeHex(t, input), &Callbacks{}); err == nil {
+			t.Errorf("%q: expected an error, but err was nil", input)
+		}
+	}
+
+	stop := errors.New("stop")
+	var n int
+	cb := &Callbacks{OnInt: func(uint64, bool) error {
+		n++
+		if n == 2 {
+			return stop
+		}
+		return nil
+	}}
+	if err := Scan(mustDecodeHex(t, "83010203"), cb); err != stop {
+		t.Errorf("got error %v from a failing callback; want %v", err, stop)
+	}
+	if n != 2 {
+		t.Errorf("callback called %d times after returning an error; want 2", n)
+	}
+}
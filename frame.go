@@ -0,0 +1,215 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+)
+
+// Frames are for transports such as raw TCP that don't delimit messages themselves. Each frame holds one
+// item: a 4-byte big-endian length, the item, and, if checksums are on, the item's CRC-32C (Castagnoli)
+// checksum as another 4 big-endian bytes. A FrameWriter and the FrameReader at the other end must agree on
+// whether checksums are used.
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// DefaultMaxFrameSize is the largest item a FrameReader accepts unless SetMaxSize is called.
+const DefaultMaxFrameSize = 16 << 20
+
+// A FrameWriter writes items to a stream as frames.
+type FrameWriter struct {
+	w        io.Writer
+	checksum bool
+	buf      []byte
+}
+
+// NewFrameWriter returns a FrameWriter that writes to w, adding a checksum to each frame if checksum is
+// set.
+func NewFrameWriter(w io.Writer, checksum bool) *FrameWriter {
+	return &FrameWriter{w: w, checksum: checksum}
+}
+
+// WriteFrame writes item, which must be a single well-formed item, as one frame with a single call to the
+// underlying writer's Write.
+func (fw *FrameWriter) WriteFrame(item RawMessage) error {
+	if err := checkValid(item); err != nil {
+		return err
+	}
+	if uint64(len(item)) > math.MaxUint32 {
+		return fmt.Errorf("cbor: item of %d bytes is too large for a frame", len(item))
+	}
+	fw.buf = binary.BigEndian.AppendUint32(fw.buf[:0], uint32(len(item)))
+	fw.buf = append(fw.buf, item...)
+	if fw.checksum {
+		fw.buf = binary.BigEndian.AppendUint32(fw.buf, crc32.Checksum(item, crc32c))
+	}
+	_, err := fw.w.Write(fw.buf)
+	return err
+}
+
+// Encode writes the encoding of v as one frame.
+func (fw *FrameWriter) Encode(v interface{}) error {
+	b, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	return fw.WriteFrame(b)
+}
+
+// A FrameError describes a corrupt frame: a bad checksum, a length over the limit, or contents that aren't
+// a single well-formed item. After returning one, a FrameReader resynchronizes by searching forward a byte
+// at a time for the next good frame. A candidate frame whose length runs past the data read so far is
+// skipped if a good frame has already arrived after it; otherwise the reader waits for the rest of it (up to
+// the maximum size), since it may be genuine.
+type FrameError struct {
+	Offset int64 // The offset of the bad frame in the stream.
+	msg    string
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("cbor: corrupt frame at offset %d: %s", e.Offset, e.msg)
+}
+
+// A FrameReader reads items from a stream of frames.
+type FrameReader struct {
+	r        io.Reader
+	checksum bool
+	maxSize  int
+	buf      []byte // data read but not yet returned
+	off      int64  // the offset of buf[0] in the stream
+	err      error  // the error that ended reading from r
+	// If resyncing is set, a corrupt frame was reported and the reader is looking for the next good one.
+	resyncing bool
+}
+
+// NewFrameReader returns a FrameReader that reads from r, checking each frame's checksum if checksum is set.
+func NewFrameReader(r io.Reader, checksum bool) *FrameReader {
+	return &FrameReader{r: r, checksum: checksum, maxSize: DefaultMaxFrameSize}
+}
+
+// SetMaxSize sets the largest item that the FrameReader accepts; a frame claiming to be longer is treated
+// as corrupt. The default is DefaultMaxFrameSize.
+func (fr *FrameReader) SetMaxSize(n int) {
+	fr.maxSize = n
+}
+
+// ReadFrame returns the item in the next frame. At the end of the stream it returns io.EOF, or
+// io.ErrUnexpectedEOF if the stream ends partway through a frame. A corrupt frame is reported with a
+// *FrameError, and the next call skips ahead to the following good frame.
+func (fr *FrameReader) ReadFrame() (RawMessage, error) {
+	trailer := 0
+	if fr.checksum {
+		trailer = 4
+	}
+	for {
+		if err := fr.fill(4); err != nil {
+			if err == io.EOF && len(fr.buf) == 0 {
+				return nil, io.EOF
+			}
+			return nil, fr.truncated(err)
+		}
+		n := binary.BigEndian.Uint32(fr.buf)
+		if uint64(n) > uint64(fr.maxSize) {
+			if err := fr.corrupt(fmt.Sprintf("length %d exceeds the limit of %d", n, fr.maxSize)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		total := 4 + int(n) + trailer
+		if fr.resyncing && len(fr.buf) < total {
+			// Rather than wait for the rest of a frame whose length may be garbage, move on to a good
+			// frame that has already arrived, if there is one.
+			if off := fr.nextGoodFrame(trailer); off > 0 {
+				fr.skip(off)
+				continue
+			}
+		}
+		if err := fr.fill(total); err != nil {
+			if fr.resyncing && err == io.EOF {
+				// The length was probably garbage.
+				fr.skip(1)
+				continue
+			}
+			return nil, fr.truncated(err)
+		}
+		item := fr.buf[4 : 4+n]
+		if fr.checksum && binary.BigEndian.Uint32(fr.buf[4+n:]) != crc32.Checksum(item, crc32c) {
+			if err := fr.corrupt("checksum mismatch"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := checkValid(item); err != nil {
+			if err := fr.corrupt(err.Error()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fr.resyncing = false
+		item = bytes.Clone(item)
+		fr.skip(total)
+		return item, nil
+	}
+}
+
+// nextGoodFrame returns the offset of the first complete, valid frame in buf after its start, or 0 if there
+// isn't one.
+func (fr *FrameReader) nextGoodFrame(trailer int) int {
+	for off := 1; off+4+trailer <= len(fr.buf); off++ {
+		n := binary.BigEndian.Uint32(fr.buf[off:])
+		if uint64(n) > uint64(fr.maxSize) || off+4+int(n)+trailer > len(fr.buf) {
+			continue
+		}
+		item := fr.buf[off+4 : off+4+int(n)]
+		if fr.checksum && binary.BigEndian.Uint32(fr.buf[off+4+int(n):]) != crc32.Checksum(item, crc32c) {
+			continue
+		}
+		if checkValid(item) == nil {
+			return off
+		}
+	}
+	return 0
+}
+
+// fill reads until at least n bytes are buffered.
+func (fr *FrameReader) fill(n int) error {
+	for len(fr.buf) < n {
+		if fr.err != nil {
+			return fr.err
+		}
+		fr.buf = slices.Grow(fr.buf, max(n-len(fr.buf), 4096))
+		m, err := fr.r.Read(fr.buf[len(fr.buf):cap(fr.buf)])
+		fr.buf = fr.buf[:len(fr.buf)+m]
+		fr.err = err
+	}
+	return nil
+}
+
+func (fr *FrameReader) skip(n int) {
+	fr.buf = fr.buf[n:]
+	fr.off += int64(n)
+}
+
+// corrupt moves past the first byte of the bad frame at the start of buf. It returns a *FrameError unless
+// one was already returned for the corruption that the reader is resynchronizing after.
+func (fr *FrameReader) corrupt(msg string) error {
+	off := fr.off
+	fr.skip(1)
+	if fr.resyncing {
+		return nil
+	}
+	fr.resyncing = true
+	return &FrameError{off, msg}
+}
+
+func (fr *FrameReader) truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
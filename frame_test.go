@@ -0,0 +1,138 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestFrames(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		var buf bytes.Buffer
+		fw := NewFrameWriter(&buf, checksum)
+		values := []interface{}{1, "abc", []int{1, 2}, map[string]int{"a": 1}}
+		for _, v := range values {
+			if err := fw.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := fw.WriteFrame(RawMessage{0x18}); err == nil {
+			t.Error("expected an error writing a truncated item")
+		}
+
+		fr := NewFrameReader(iotest.OneByteReader(&buf), checksum)
+		for _, v := range values {
+			raw, err := fr.ReadFrame()
+			if err != nil {
+				t.Fatalf("checksum=%t: %s", checksum, err)
+			}
+			if expected := mustMarshal(t, v); !bytes.Equal(raw, expected) {
+				t.Errorf("checksum=%t: expected %x; got %x", checksum, expected, raw)
+			}
+		}
+		if _, err := fr.ReadFrame(); err != io.EOF {
+			t.Errorf("checksum=%t: expected io.EOF at the end; got %v", checksum, err)
+		}
+	}
+}
+
+func TestFrameResync(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, true)
+	for _, s := range []string{"first", "second", "third"} {
+		if err := fw.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+	data[4+2] ^= 0xFF // corrupt the second byte of the first item
+
+	fr := NewFrameReader(bytes.NewReader(data), true)
+	_, err := fr.ReadFrame()
+	var frameErr *FrameError
+	if !errors.As(err, &frameErr) || frameErr.Offset != 0 {
+		t.Fatalf("expected a *FrameError at offset 0; got %v", err)
+	}
+	var actual []string
+	for {
+		raw, err := fr.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, hex.EncodeToString(raw))
+	}
+	expected := []string{"667365636f6e64", "657468697264"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("after resynchronizing: expected %q; got %q", expected, actual)
+	}
+}
+
+func TestFrameResyncLive(t *testing.T) {
+	// After a corrupt frame, bytes inside it look like the lengths of frames longer than what has arrived.
+	// The reader must move on to the good frame that follows rather than wait for more input.
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf, true)
+	for _, s := range []string{"first", "second"} {
+		if err := fw.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+	data[4+6] ^= 0xFF // corrupt the checksum of the first frame
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write(data)
+
+	done := make(chan error, 1)
+	go func() {
+		fr := NewFrameReader(pr, true)
+		var frameErr *FrameError
+		if _, err := fr.ReadFrame(); !errors.As(err, &frameErr) {
+			done <- fmt.Errorf("expected a *FrameError; got %v", err)
+			return
+		}
+		raw, err := fr.ReadFrame()
+		if err == nil && hex.EncodeToString(raw) != "667365636f6e64" {
+			err = fmt.Errorf("got frame %x", raw)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ReadFrame blocked instead of resynchronizing on buffered data")
+	}
+}
+
+func TestFrameReaderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		checksum bool
+		err      error
+	}{
+		{"000000", false, io.ErrUnexpectedEOF},
+		{"0000000201", false, io.ErrUnexpectedEOF},
+		{"0000000101", true, io.ErrUnexpectedEOF},
+		{"0000000118", false, &FrameError{}},        // malformed item
+		{"ffffffff00", false, &FrameError{}},        // over the size limit
+		{"000000010100000000", true, &FrameError{}}, // bad checksum
+	} {
+		fr := NewFrameReader(bytes.NewReader(mustDecodeHex(t, tt.input)), tt.checksum)
+		_, err := fr.ReadFrame()
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.err) || tt.err == io.ErrUnexpectedEOF && err != tt.err {
+			t.Errorf("%s (checksum=%t): expected error like %v; got %v", tt.input, tt.checksum, tt.err, err)
+		}
+	}
+}
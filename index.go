@@ -0,0 +1,86 @@
+package cbor
+
+import "fmt"
+
+// An Index records where the value of each entry of an encoded map is, so that a document that is looked
+// up many times only has to be scanned once: after NewIndex, each lookup is a single hash map access that
+// returns a slice of the document, without decoding or copying anything.
+//
+// An Index is safe for concurrent use. It refers to the document's memory, which must not be modified while
+// the Index is in use.
+type Index struct {
+	data    []byte
+	entries map[string]span // keyed by the encoded key
+}
+
+// A span is the byte range data[start:end] of an item.
+type span struct {
+	start, end int
+}
+
+// NewIndex scans data, which must hold a single map, and returns an index of its entries. If a key appears
+// more than once, the first entry is used, as with Node.Get.
+func NewIndex(data []byte) (*Index, error) {
+	x := &Index{data: data, entries: make(map[string]span)}
+	// The entries follow the map's header one after another.
+	_, _, _, off, err := ReadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	var key RawMessage
+	err = scanContainer(data, typeMap, func(raw RawMessage) bool {
+		start := off
+		off += len(raw)
+		if key == nil {
+			key = raw
+			return true
+		}
+		if _, ok := x.entries[string(key)]; !ok {
+			x.entries[string(key)] = span{start, off}
+		}
+		key = nil
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Len returns the number of distinct keys in x.
+func (x *Index) Len() int {
+	return len(x.entries)
+}
+
+// Get returns the value of the entry with the text string key name.
+func (x *Index) Get(name string) (RawMessage, bool) {
+	var buf [64]byte
+	key := append(AppendHeader(buf[:0], MajorText, uint64(len(name))), name...)
+	return x.GetRaw(key)
+}
+
+// GetRaw returns the value of the entry whose key is encoded as key (byte for byte).
+func (x *Index) GetRaw(key RawMessage) (RawMessage, bool) {
+	start, end, ok := x.SpanRaw(key)
+	if !ok {
+		return nil, false
+	}
+	return RawMessage(x.data[start:end:end]), true
+}
+
+// SpanRaw returns the byte range, data[start:end], of the value of the entry whose key is encoded as key.
+func (x *Index) SpanRaw(key RawMessage) (start, end int, ok bool) {
+	s, ok := x.entries[string(key)]
+	return s.start, s.end, ok
+}
+
+// Sub returns an index of the map that is the value of the entry with the text string key name, for
+// documents whose lookups go more than one level deep. The result isn't cached, so callers that use it
+// repeatedly should keep it.
+func (x *Index) Sub(name string) (*Index, error) {
+	value, ok := x.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("cbor: no entry with key %q", name)
+	}
+	return NewIndex(value)
+}
@@ -0,0 +1,55 @@
+package cbor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	data := mustMarshal(t, OrderedMap{
+		{"id", 7},
+		{1, "one"},
+		{"user", OrderedMap{{"name", "gopher"}}},
+		{"id", 8},
+	})
+	x, err := NewIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Len() != 3 {
+		t.Errorf("got Len() = %d; want 3", x.Len())
+	}
+	for _, test := range []struct {
+		key      RawMessage
+		expected interface{}
+	}{
+		{mustMarshal(t, "id"), 7},
+		{mustMarshal(t, 1), "one"},
+	} {
+		value, ok := x.GetRaw(test.key)
+		if expected := mustMarshal(t, test.expected); !ok || !bytes.Equal(value, expected) {
+			t.Errorf("GetRaw(%x): got %x, %t; want %x", test.key, value, ok, expected)
+		}
+	}
+	start, end, ok := x.SpanRaw(mustMarshal(t, 1))
+	if !ok || !bytes.Equal(data[start:end], mustMarshal(t, "one")) {
+		t.Errorf("SpanRaw(1): got %d, %d, %t", start, end, ok)
+	}
+	if _, ok := x.Get("nope"); ok {
+		t.Error("Get(nope): got an entry")
+	}
+
+	user, err := x.Sub("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := user.Get("name"); !ok || !bytes.Equal(value, mustMarshal(t, "gopher")) {
+		t.Errorf("Get(name): got %x, %t", value, ok)
+	}
+	if _, err := x.Sub("id"); err == nil {
+		t.Error("Sub(id): expected an error for a non-map value")
+	}
+	if _, err := NewIndex(mustDecodeHex(t, "a2616101")); err == nil {
+		t.Error("expected an error for a truncated map")
+	}
+}
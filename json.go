@@ -0,0 +1,170 @@
+package cbor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONCompatible converts v, a tree of generic CBOR values (like those handled by Clone), into a form that
+// encoding/json can marshal, following the advice of RFC 8949, section 6.1:
+//
+//   - Maps (including OrderedMaps) become map[string]interface{}. Text string keys are kept, integer, float,
+//     and bool keys become their decimal or literal text, and byte string keys are converted as below. It is
+//     an error if two keys of a map have the same text, or for a key to have any other type.
+//   - Byte strings ([]byte) become base64url strings without padding.
+//   - NaN and infinite floats become nil (null).
+//
+// Other values, including *big.Int, are left as-is; v itself is not modified.
+func JSONCompatible(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		if v == nil {
+			return v, nil
+		}
+		c := make([]interface{}, len(v))
+		for i, elem := range v {
+			var err error
+			if c[i], err = JSONCompatible(elem); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case map[interface{}]interface{}:
+		if v == nil {
+			return map[string]interface{}(nil), nil
+		}
+		c := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			if err := setJSONEntry(c, key, elem); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case map[string]interface{}:
+		if v == nil {
+			return v, nil
+		}
+		c := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			if err := setJSONEntry(c, key, elem); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case OrderedMap:
+		if v == nil {
+			return map[string]interface{}(nil), nil
+		}
+		c := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			if err := setJSONEntry(c, item.Key, item.Value); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case []byte:
+		return base64.RawURLEncoding.EncodeToString(v), nil
+	case float32:
+		return jsonFloat(float64(v)), nil
+	case float64:
+		return jsonFloat(v), nil
+	}
+	return v, nil
+}
+
+func jsonFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+func setJSONEntry(m map[string]interface{}, key, value interface{}) error {
+	s, err := jsonKey(key)
+	if err != nil {
+		return err
+	}
+	if _, ok := m[s]; ok {
+		return fmt.Errorf("%w: more than one key converts to %q", ErrDuplicateKey, s)
+	}
+	m[s], err = JSONCompatible(value)
+	return err
+}
+
+// jsonKey returns the JSON object key for a map key.
+func jsonKey(key interface{}) (string, error) {
+	switch key := key.(type) {
+	case string:
+		return key, nil
+	case []byte:
+		return base64.RawURLEncoding.EncodeToString(key), nil
+	case bool:
+		return strconv.FormatBool(key), nil
+	case *big.Int:
+		if key != nil {
+			return key.String(), nil
+		}
+	}
+	switch v := reflect.ValueOf(key); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("cbor: can't convert map key of type %T to JSON", key)
+}
+
+// A JSONNumberFormat says how json.Number values are encoded.
+type JSONNumberFormat int
+
+const (
+	// JSONNumberAuto encodes numbers written without a fraction or exponent as integers (bignums, if they
+	// don't fit in 64 bits) and other numbers as floats. This is the default.
+	JSONNumberAuto JSONNumberFormat = iota
+	// JSONNumberFloat encodes all numbers as floats.
+	JSONNumberFloat
+	// JSONNumberString encodes numbers as text strings holding their JSON text, passing them through
+	// unchanged.
+	JSONNumberString
+)
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+func (e *encodeState) writeJSONNumber(v reflect.Value) {
+	s := v.String()
+	if !isJSONNumber(s) {
+		e.error(&UnsupportedValueError{Value: v, Str: fmt.Sprintf("invalid json.Number %q", s)})
+	}
+	if e.jsonNumberFormat == JSONNumberString {
+		e.writeTextString(s)
+		return
+	}
+	if e.jsonNumberFormat == JSONNumberAuto && !strings.ContainsAny(s, ".eE") {
+		n, _ := new(big.Int).SetString(s, 10)
+		e.writeBigInt(n)
+		return
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		e.error(&UnsupportedValueError{Value: v, Str: fmt.Sprintf("json.Number %q out of float64 range", s)})
+	}
+	e.writeFloat64(f)
+}
+
+// isJSONNumber reports whether s is a number in JSON syntax. The checks of the first and last bytes rule out
+// other values and the surrounding whitespace that json.Valid allows.
+func isJSONNumber(s string) bool {
+	return s != "" && (s[0] == '-' || isDigit(s[0])) && isDigit(s[len(s)-1]) && json.Valid([]byte(s))
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
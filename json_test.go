@@ -0,0 +1,88 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestJSONCompatible(t *testing.T) {
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{"a", `"a"`},
+		{[]byte{0xfb, 0xff}, `"-_8"`},
+		{math.NaN(), "null"},
+		{[]interface{}{math.Inf(1), float32(1.5), uint64(7)}, "[null,1.5,7]"},
+		{
+			map[interface{}]interface{}{
+				"s": 1, int64(-2): 2, uint8(3): 3, true: 4, 1.5: 5, uint16(107): 6, big.NewInt(10): 7,
+			},
+			`{"-2":2,"1.5":5,"10":7,"107":6,"3":3,"s":1,"true":4}`,
+		},
+		{OrderedMap{{"b", []byte{1}}, {"a", OrderedMap{{1, nil}}}}, `{"a":{"1":null},"b":"AQ"}`},
+		{map[string]interface{}{"x": []interface{}{map[interface{}]interface{}{2: "y"}}}, `{"x":[{"2":"y"}]}`},
+		{big.NewInt(5), "5"},
+	} {
+		v, err := JSONCompatible(test.input)
+		if err != nil {
+			t.Errorf("%#v: %s", test.input, err)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("%#v: converted value can't be marshaled: %s", test.input, err)
+			continue
+		}
+		if string(b) != test.expected {
+			t.Errorf("%#v: expected %s; got %s", test.input, test.expected, b)
+		}
+	}
+	for _, input := range []interface{}{
+		map[interface{}]interface{}{1: "a", "1": "b"},
+		OrderedMap{{"a", 1}, {"a", 2}},
+		OrderedMap{{[1]int{1}, 1}},
+		[]interface{}{OrderedMap{{nil, 1}}},
+	} {
+		if _, err := JSONCompatible(input); err == nil {
+			t.Errorf("%#v: expected an error", input)
+		}
+	}
+}
+
+func TestJSONNumber(t *testing.T) {
+	big2to64, _ := new(big.Int).SetString("18446744073709551616", 10)
+	for _, test := range []struct {
+		format   JSONNumberFormat
+		input    json.Number
+		expected interface{}
+	}{
+		{JSONNumberAuto, "12", 12},
+		{JSONNumberAuto, "-1", -1},
+		{JSONNumberAuto, "18446744073709551616", big2to64},
+		{JSONNumberAuto, "1.5", 1.5},
+		{JSONNumberAuto, "1e3", 1000.0},
+		{JSONNumberFloat, "12", 12.0},
+		{JSONNumberString, "1.50", "1.50"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetJSONNumberFormat(test.format)
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if expected := mustMarshal(t, test.expected); !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("format %d, %s: expected %x; got %x", test.format, test.input, expected, buf.Bytes())
+		}
+	}
+	for _, n := range []json.Number{"", "+1", "0x10", "1e400", "NaN", "1 ", "1\n", " 1", "\t-2.5"} {
+		if _, err := Marshal(n); !errors.Is(err, ErrUnsupportedValue) {
+			t.Errorf("Marshal(json.Number(%q)): expected ErrUnsupportedValue; got %v", n, err)
+		}
+	}
+}
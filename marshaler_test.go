@@ -0,0 +1,62 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// okMarshaler encodes itself as the text string "ok".
+type okMarshaler struct{}
+
+func (okMarshaler) MarshalCBOR() ([]byte, error) { return []byte{0x62, 'o', 'k'}, nil }
+
+var errMarshalFailed = errors.New("marshal failed")
+
+// failingMarshaler always fails to encode itself.
+type failingMarshaler struct{}
+
+func (*failingMarshaler) MarshalCBOR() ([]byte, error) { return nil, errMarshalFailed }
+
+// badMarshaler returns its own bytes, which needn't be a single well-formed item.
+type badMarshaler []byte
+
+func (m badMarshaler) MarshalCBOR() ([]byte, error) { return m, nil }
+
+func TestMarshaler(t *testing.T) {
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		{okMarshaler{}, "626f6b"},
+		{&okMarshaler{}, "626f6b"},
+		{[]okMarshaler{{}, {}}, "82626f6b626f6b"},
+		{(*okMarshaler)(nil), "f6"},
+		{(*failingMarshaler)(nil), "f6"},
+	} {
+		b, err := Marshal(test.input)
+		if err != nil {
+			t.Errorf("%#v: %s", test.input, err)
+			continue
+		}
+		if actual := hex.EncodeToString(b); actual != test.expected {
+			t.Errorf("%#v: expected 0x%s; got 0x%s", test.input, test.expected, actual)
+		}
+	}
+
+	for _, input := range []interface{}{&failingMarshaler{}, []*failingMarshaler{{}}} {
+		b, err := Marshal(input)
+		var marshalerErr *MarshalerError
+		if !errors.As(err, &marshalerErr) || marshalerErr.Err != errMarshalFailed {
+			t.Errorf("%#v: expected a MarshalerError for the failure; got %x, %v", input, b, err)
+		}
+	}
+
+	for _, input := range []badMarshaler{{}, {0x18}, {0x01, 0x02}, {0xff}} {
+		b, err := Marshal(input)
+		var marshalerErr *MarshalerError
+		if !errors.As(err, &marshalerErr) {
+			t.Errorf("%x: expected a MarshalerError for the invalid output; got %x, %v", []byte(input), b, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+)
+
+// MergePatch applies patch to target, both of which hold a single encoded item, and returns the result. It
+// follows the rules of JSON Merge Patch (RFC 7396): if patch is a map, each of its entries with a null value
+// removes the entry with the same key from target, and each other entry is merged into (or added to) target
+// recursively; if patch is anything else, it replaces target entirely. To patch a Go value, Marshal it first.
+//
+// Map keys are compared by their encoded bytes, so they must be encoded the same way in target and patch (as
+// they will be if both use the shortest encodings). Entries of target keep their order, and new entries are
+// added at the end; maps in the result always have definite lengths.
+func MergePatch(target, patch []byte) (RawMessage, error) {
+	if err := checkValid(target); err != nil {
+		return nil, err
+	}
+	if err := checkValid(patch); err != nil {
+		return nil, err
+	}
+	return mergePatch(target, patch)
+}
+
+func isMap(data []byte) bool {
+	return len(data) > 0 && data[0]>>5 == typeMap
+}
+
+func isNull(data []byte) bool {
+	return len(data) == 1 && data[0] == makeIDByte(typeMajor7, typeNull)
+}
+
+// mergePatch implements MergePatch. A nil target means the entry being patched doesn't exist.
+func mergePatch(target, patch []byte) (RawMessage, error) {
+	if !isMap(patch) {
+		return patch, nil
+	}
+	var entries []RawMapEntry
+	index := make(map[string]int) // key -> index in entries
+	if isMap(target) {
+		for entry, err := range MapEntries(target) {
+			if err != nil {
+				return nil, err
+			}
+			index[string(entry.Key)] = len(entries)
+			entries = append(entries, entry)
+		}
+	}
+	n := len(entries)
+	for entry, err := range MapEntries(patch) {
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[string(entry.Key)]
+		if isNull(entry.Value) {
+			if ok && entries[i].Value != nil {
+				entries[i].Value = nil // removed
+				n--
+			}
+			continue
+		}
+		var old RawMessage
+		if ok {
+			old = entries[i].Value
+		}
+		value, err := mergePatch(old, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !ok:
+			index[string(entry.Key)] = len(entries)
+			entries = append(entries, RawMapEntry{entry.Key, value})
+			n++
+		case old == nil:
+			entries[i].Value = value
+			n++
+		default:
+			entries[i].Value = value
+		}
+	}
+	e := &encodeState{}
+	e.writeMajorWithNumber(typeMap, uint64(n))
+	for _, entry := range entries {
+		if entry.Value != nil {
+			e.Write(entry.Key)
+			e.Write(entry.Value)
+		}
+	}
+	return e.Bytes(), nil
+}
+
+// ErrMergePatchNull is returned by CreateMergePatch when the target document can't be reached with a merge
+// patch, because it sets a map entry to null (which a merge patch can only express as removing the entry).
+var ErrMergePatchNull = errors.New("cbor: merge patch can't set a map entry to null")
+
+// CreateMergePatch returns a merge patch that turns from into to when applied by MergePatch: a map with
+// entries only for the keys that were added, removed (with a null value), or changed (recursively, for maps).
+// It's the companion to MergePatch, useful for recording changes between versions of a document.
+//
+// As with MergePatch, keys and values are compared by their encoded bytes, and the order of map entries is
+// not significant. If to has a map entry with a null value that isn't already in from, CreateMergePatch
+// returns ErrMergePatchNull.
+func CreateMergePatch(from, to []byte) (RawMessage, error) {
+	if err := checkValid(from); err != nil {
+		return nil, err
+	}
+	if err := checkValid(to); err != nil {
+		return nil, err
+	}
+	return createMergePatch(from, to)
+}
+
+func createMergePatch(from, to []byte) (RawMessage, error) {
+	if !isMap(to) {
+		return to, nil
+	}
+	if !isMap(from) {
+		// The patch is merged into an empty map, dropping any null entries.
+		if hasNullEntry(to) {
+			return nil, ErrMergePatchNull
+		}
+		return to, nil
+	}
+	var fromKeys []RawMessage
+	fromValues := make(map[string]RawMessage)
+	for entry, err := range MapEntries(from) {
+		if err != nil {
+			return nil, err
+		}
+		fromKeys = append(fromKeys, entry.Key)
+		fromValues[string(entry.Key)] = entry.Value
+	}
+	toValues := make(map[string]RawMessage)
+	for entry, err := range MapEntries(to) {
+		if err != nil {
+			return nil, err
+		}
+		toValues[string(entry.Key)] = entry.Value
+	}
+
+	body := &encodeState{}
+	n := 0
+	for _, key := range fromKeys {
+		if _, ok := toValues[string(key)]; !ok {
+			body.Write(key)
+			body.writeSimple(typeNull)
+			n++
+			toValues[string(key)] = nil // so that a duplicate key isn't removed twice
+		}
+	}
+	for entry, err := range MapEntries(to) {
+		if err != nil {
+			return nil, err
+		}
+		old, ok := fromValues[string(entry.Key)]
+		if ok && bytes.Equal(old, entry.Value) {
+			continue
+		}
+		if isNull(entry.Value) {
+			return nil, ErrMergePatchNull
+		}
+		patch, err := createMergePatch(old, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		if isMap(old) && isMap(patch) && patch[0] == makeIDByte(typeMap, 0) {
+			continue // the maps have the same entries
+		}
+		body.Write(entry.Key)
+		body.Write(patch)
+		n++
+	}
+	e := &encodeState{}
+	e.writeMajorWithNumber(typeMap, uint64(n))
+	e.Write(body.Bytes())
+	return e.Bytes(), nil
+}
+
+// hasNullEntry reports whether the map in data, or a map nested in it as an entry's value, has an entry whose
+// value is null.
+func hasNullEntry(data []byte) bool {
+	for entry, err := range MapEntries(data) {
+		if err != nil {
+			return false
+		}
+		if isNull(entry.Value) || isMap(entry.Value) && hasNullEntry(entry.Value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	for _, test := range []struct {
+		target   interface{}
+		patch    interface{}
+		expected interface{}
+	}{
+		// Examples from RFC 7396, Appendix A.
+		{OrderedMap{{"a", "b"}}, OrderedMap{{"a", "c"}}, OrderedMap{{"a", "c"}}},
+		{OrderedMap{{"a", "b"}}, OrderedMap{{"b", "c"}}, OrderedMap{{"a", "b"}, {"b", "c"}}},
+		{OrderedMap{{"a", "b"}}, OrderedMap{{"a", nil}}, OrderedMap{}},
+		{OrderedMap{{"a", "b"}, {"b", "c"}}, OrderedMap{{"a", nil}}, OrderedMap{{"b", "c"}}},
+		{OrderedMap{{"a", []string{"b"}}}, OrderedMap{{"a", "c"}}, OrderedMap{{"a", "c"}}},
+		{OrderedMap{{"a", "c"}}, OrderedMap{{"a", []string{"b"}}}, OrderedMap{{"a", []string{"b"}}}},
+		{
+			OrderedMap{{"a", OrderedMap{{"b", "c"}}}},
+			OrderedMap{{"a", OrderedMap{{"b", "d"}, {"c", nil}}}},
+			OrderedMap{{"a", OrderedMap{{"b", "d"}}}},
+		},
+		{OrderedMap{{"a", []int{1}}}, OrderedMap{{"a", []int{2}}}, OrderedMap{{"a", []int{2}}}},
+		{[]string{"a", "b"}, []string{"c", "d"}, []string{"c", "d"}},
+		{OrderedMap{{"a", "b"}}, []string{"c"}, []string{"c"}},
+		{OrderedMap{{"a", "foo"}}, nil, nil},
+		{OrderedMap{{"a", "foo"}}, "bar", "bar"},
+		{OrderedMap{{"e", nil}}, OrderedMap{{"a", 1}}, OrderedMap{{"e", nil}, {"a", 1}}},
+		{[]int{1, 2}, OrderedMap{{"a", "b"}, {"c", nil}}, OrderedMap{{"a", "b"}}},
+		{OrderedMap{}, OrderedMap{{"a", OrderedMap{{"bb", OrderedMap{{"ccc", nil}}}}}}, OrderedMap{{"a", OrderedMap{{"bb", OrderedMap{}}}}}},
+
+		// Non-string keys, and removing then re-adding a key.
+		{OrderedMap{{1, "a"}, {2, "b"}}, OrderedMap{{1, nil}, {1, "c"}}, OrderedMap{{1, "c"}, {2, "b"}}},
+	} {
+		target := mustMarshal(t, test.target)
+		patch := mustMarshal(t, test.patch)
+		result, err := MergePatch(target, patch)
+		if err != nil {
+			t.Errorf("MergePatch(%x, %x): %s", target, patch, err)
+			continue
+		}
+		if expected := mustMarshal(t, test.expected); string(result) != string(expected) {
+			t.Errorf("MergePatch(%x, %x): expected %x; got %x", target, patch, expected, result)
+		}
+	}
+}
+
+func TestMergePatchIndefinite(t *testing.T) {
+	// {_ "a": 1, "b": 2} patched with {"b": null, "c": 3}
+	result, err := MergePatch(mustDecodeHex(t, "bf616101616202ff"), mustDecodeHex(t, "a26162f6616303"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := hex.EncodeToString(result), "a2616101616303"; actual != expected {
+		t.Errorf("expected %s; got %s", expected, actual)
+	}
+}
+
+func TestMergePatchErrors(t *testing.T) {
+	for _, test := range []struct{ target, patch string }{
+		{"a1", "a0"},
+		{"a0", "a16161"},
+		{"a0", "a0a0"},
+	} {
+		if _, err := MergePatch(mustDecodeHex(t, test.target), mustDecodeHex(t, test.patch)); err == nil {
+			t.Errorf("MergePatch(%s, %s): expected an error", test.target, test.patch)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	for _, test := range []struct {
+		from, to interface{}
+		patch    interface{}
+	}{
+		{OrderedMap{{"a", "b"}}, OrderedMap{{"a", "c"}}, OrderedMap{{"a", "c"}}},
+		{OrderedMap{{"a", "b"}}, OrderedMap{{"a", "b"}, {"b", "c"}}, OrderedMap{{"b", "c"}}},
+		{OrderedMap{{"a", "b"}, {"b", "c"}}, OrderedMap{{"b", "c"}}, OrderedMap{{"a", nil}}},
+		{OrderedMap{{"a", "b"}, {"b", "c"}}, OrderedMap{{"b", "c"}, {"a", "b"}}, OrderedMap{}},
+		{OrderedMap{{"a", []int{1}}}, OrderedMap{{"a", []int{1, 2}}}, OrderedMap{{"a", []int{1, 2}}}},
+		{
+			OrderedMap{{"a", OrderedMap{{"b", "c"}, {"d", "e"}}}, {"f", 1}},
+			OrderedMap{{"a", OrderedMap{{"b", "x"}, {"d", "e"}}}, {"f", 1}},
+			OrderedMap{{"a", OrderedMap{{"b", "x"}}}},
+		},
+		{OrderedMap{{"a", "b"}}, OrderedMap{{"a", OrderedMap{{"c", 1}}}}, OrderedMap{{"a", OrderedMap{{"c", 1}}}}},
+		{OrderedMap{{"a", nil}}, OrderedMap{{"a", nil}, {"b", 1}}, OrderedMap{{"b", 1}}},
+		{[]int{1}, OrderedMap{{"a", 1}}, OrderedMap{{"a", 1}}},
+		{OrderedMap{{"a", 1}}, "x", "x"},
+		{"x", "x", "x"},
+	} {
+		from := mustMarshal(t, test.from)
+		to := mustMarshal(t, test.to)
+		patch, err := CreateMergePatch(from, to)
+		if err != nil {
+			t.Errorf("CreateMergePatch(%x, %x): %s", from, to, err)
+			continue
+		}
+		if expected := mustMarshal(t, test.patch); string(patch) != string(expected) {
+			t.Errorf("CreateMergePatch(%x, %x): expected %x; got %x", from, to, expected, patch)
+		}
+		// Applying the patch should give back a document with the same entries as to.
+		result, err := MergePatch(from, patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, err := CreateMergePatch(result, to); err != nil || isMap(to) && string(again) != "\xa0" {
+			t.Errorf("MergePatch(%x, %x) = %x, which differs from %x", from, patch, result, to)
+		}
+	}
+}
+
+func TestCreateMergePatchNull(t *testing.T) {
+	for _, test := range []struct{ from, to interface{} }{
+		{OrderedMap{{"a", 1}}, OrderedMap{{"a", nil}}},
+		{OrderedMap{}, OrderedMap{{"a", nil}}},
+		{OrderedMap{}, OrderedMap{{"a", OrderedMap{{"b", nil}}}}},
+		{1, OrderedMap{{"a", nil}}},
+	} {
+		from := mustMarshal(t, test.from)
+		to := mustMarshal(t, test.to)
+		if _, err := CreateMergePatch(from, to); err != ErrMergePatchNull {
+			t.Errorf("CreateMergePatch(%x, %x): got error %v; want ErrMergePatchNull", from, to, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An NDArray is a multi-dimensional array of Ts: a matrix, tensor, or the like. It is encoded as a
+// multi-dimensional array (RFC 8746, section 3), with the elements as a typed array if T is an integer or
+// float type.
+type NDArray[T any] struct {
+	// Dims holds the size of each dimension, from the outermost.
+	Dims []int
+	// Data holds all the elements, with the last dimension varying fastest (row-major order) or, if
+	// ColumnMajor is set, the first dimension varying fastest. Its length must be the product of Dims.
+	Data        []T
+	ColumnMajor bool
+}
+
+// MarshalCBOR encodes a as tag 40 (or, for column-major arrays, tag 1040).
+func (a NDArray[T]) MarshalCBOR() ([]byte, error) {
+	n := 1
+	for _, d := range a.Dims {
+		if d < 0 {
+			return nil, fmt.Errorf("cbor: NDArray has negative dimension %d", d)
+		}
+		n *= d
+	}
+	if len(a.Dims) == 0 || n != len(a.Data) {
+		return nil, fmt.Errorf("cbor: NDArray with dimensions %v has %d elements", a.Dims, len(a.Data))
+	}
+	tag := uint64(tagNDArray)
+	if a.ColumnMajor {
+		tag = tagNDArrayCol
+	}
+	e := &encodeState{encOpts: encOpts{typedArrays: true}}
+	e.writeMajorWithNumber(typeTag, tag)
+	e.writeMajorWithNumber(typeList, 2)
+	e.writeMajorWithNumber(typeList, uint64(len(a.Dims)))
+	for _, d := range a.Dims {
+		e.writeMajorWithNumber(typePosInt, uint64(d))
+	}
+	data := a.Data
+	if data == nil {
+		data = []T{} // an empty array, not null
+	}
+	// A slice of uint8 would otherwise be a plain byte string, which RFC 8746 doesn't allow here.
+	if v := reflect.ValueOf(data); v.Type().Elem().Kind() == reflect.Uint8 && e.writeTypedArray(v) {
+		return e.Bytes(), nil
+	}
+	if err := e.marshal(data); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
@@ -0,0 +1,41 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNDArray(t *testing.T) {
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		// The example of RFC 8746, section 3.1, with the elements as a typed array.
+		{NDArray[uint16]{Dims: []int{2, 3}, Data: []uint16{2, 4, 8, 4, 16, 256}}, "d82882820203d8414c000200040008000400100100"},
+		{NDArray[uint16]{Dims: []int{2, 3}, Data: []uint16{2, 4, 8, 4, 16, 256}, ColumnMajor: true}, "d9041082820203d8414c000200040008000400100100"},
+		{NDArray[uint8]{Dims: []int{2}, Data: []uint8{1, 2}}, "d828828102d840420102"},
+		{NDArray[byte]{Dims: []int{0}}, "d828828100d84040"},
+		{NDArray[string]{Dims: []int{1, 2}, Data: []string{"a", "b"}}, "d828828201028261616162"},
+		{NDArray[float64]{Dims: []int{0, 5}}, "d82882820005d85240"},
+		{struct{ M NDArray[int8] }{NDArray[int8]{Dims: []int{1}, Data: []int8{-1}}}, "a1614dd828828101d84841ff"},
+	} {
+		b, err := Marshal(test.input)
+		if err != nil {
+			t.Errorf("%#v: %s", test.input, err)
+			continue
+		}
+		if actual := hex.EncodeToString(b); actual != test.expected {
+			t.Errorf("%#v: expected 0x%s; got 0x%s", test.input, test.expected, actual)
+		}
+	}
+	for _, input := range []interface{}{
+		NDArray[int]{},
+		NDArray[int]{Dims: []int{2, 2}, Data: []int{1, 2, 3}},
+		NDArray[int]{Dims: []int{-1, -1}, Data: []int{1}},
+		NDArray[chan int]{Dims: []int{1}, Data: []chan int{nil}},
+	} {
+		if _, err := Marshal(input); err == nil {
+			t.Errorf("%#v: expected an error", input)
+		}
+	}
+}
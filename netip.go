@@ -0,0 +1,70 @@
+package cbor
+
+import (
+	"net/netip"
+	"reflect"
+)
+
+var (
+	netipAddrType   = reflect.TypeOf(netip.Addr{})
+	netipPrefixType = reflect.TypeOf(netip.Prefix{})
+)
+
+// writeAddr writes a as an RFC 9164 address: tag 52 (IPv4) or 54 (IPv6) around the bytes of the address,
+// or, for an IPv6 address with a zone, around [address, null, zone]. With the legacyIPTags option, it's
+// written as tag 260 instead, which has no way to express a zone. The zero Addr is written as null.
+func (e *encodeState) writeAddr(a netip.Addr) {
+	if !a.IsValid() {
+		e.writeSimple(typeNull)
+		return
+	}
+	if e.legacyIPTags {
+		if a.Zone() != "" {
+			e.error(&UnsupportedValueError{Value: reflect.ValueOf(a), Str: "IP address zone with legacy tags"})
+		}
+		e.writeMajorWithNumber(typeTag, tagLegacyIPAddr)
+		e.writeByteString(a.AsSlice())
+		return
+	}
+	e.writeMajorWithNumber(typeTag, ipTag(a))
+	if zone := a.Zone(); zone != "" {
+		e.writeMajorWithNumber(typeList, 3)
+		e.writeByteString(a.AsSlice())
+		e.writeSimple(typeNull)
+		e.writeTextString(zone)
+		return
+	}
+	e.writeByteString(a.AsSlice())
+}
+
+// writePrefix writes p as an RFC 9164 prefix: tag 52 or 54 around [prefix length, address bytes], with the
+// address masked and its trailing zero bytes left out. With the legacyIPTags option, it's written as tag
+// 261 around a map from the masked address to the prefix length. The zero Prefix is written as null.
+func (e *encodeState) writePrefix(p netip.Prefix) {
+	if !p.IsValid() {
+		e.writeSimple(typeNull)
+		return
+	}
+	addr := p.Masked().Addr().AsSlice()
+	if e.legacyIPTags {
+		e.writeMajorWithNumber(typeTag, tagLegacyIPPrefix)
+		e.writeMajorWithNumber(typeMap, 1)
+		e.writeByteString(addr)
+		e.writeMajorWithNumber(typePosInt, uint64(p.Bits()))
+		return
+	}
+	for len(addr) > 0 && addr[len(addr)-1] == 0 {
+		addr = addr[:len(addr)-1]
+	}
+	e.writeMajorWithNumber(typeTag, ipTag(p.Addr()))
+	e.writeMajorWithNumber(typeList, 2)
+	e.writeMajorWithNumber(typePosInt, uint64(p.Bits()))
+	e.writeByteString(addr)
+}
+
+func ipTag(a netip.Addr) uint64 {
+	if a.Is4() {
+		return tagIPv4
+	}
+	return tagIPv6
+}
@@ -0,0 +1,44 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestNetIP(t *testing.T) {
+	for _, test := range []struct {
+		legacy   bool
+		input    interface{}
+		expected string
+	}{
+		{false, netip.MustParseAddr("192.0.2.1"), "d83444c0000201"},
+		{false, netip.MustParseAddr("2001:db8::1"), "d8365020010db8000000000000000000000001"},
+		{false, netip.MustParseAddr("fe80::1%eth0"), "d8368350fe800000000000000000000000000001f66465746830"},
+		{false, netip.MustParsePrefix("192.0.2.0/24"), "d83482181843c00002"},
+		{false, netip.MustParsePrefix("2001:db8::/32"), "d8368218204420010db8"},
+		{false, netip.MustParsePrefix("0.0.0.0/0"), "d834820040"},
+		{false, netip.Addr{}, "f6"},
+		{false, netip.Prefix{}, "f6"},
+		{true, netip.MustParseAddr("192.0.2.1"), "d9010444c0000201"},
+		{true, netip.MustParsePrefix("192.0.2.7/24"), "d90105a144c00002001818"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetLegacyIPTags(test.legacy)
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("legacy %t, %v: expected 0x%s; got 0x%s", test.legacy, test.input, test.expected, actual)
+		}
+	}
+
+	enc := NewEncoder(&bytes.Buffer{})
+	enc.SetLegacyIPTags(true)
+	if err := enc.Encode(netip.MustParseAddr("fe80::1%eth0")); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue for a zone with legacy tags; got %v", err)
+	}
+}
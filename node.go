@@ -0,0 +1,272 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A Node is an item of a CBOR document stored in an io.ReaderAt, such as a large file or a memory-mapped
+// region. Nodes let lookup and indexing tools navigate a document without reading all of it: moving to an
+// array element or map entry skips over the items before it by reading only their headers, never the
+// contents of their strings.
+//
+// Only the parts of the document that are visited are checked for well-formedness.
+type Node struct {
+	r          io.ReaderAt
+	size       int64 // of the whole input
+	off        int64 // of the item's header
+	headerSize int
+	major      MajorType
+	arg        uint64
+	indefinite bool
+}
+
+// ReadNodeAt returns the item whose header starts at offset off of r, which holds size bytes of input.
+func ReadNodeAt(r io.ReaderAt, off, size int64) (Node, error) {
+	if off < 0 || off > size {
+		return Node{}, fmt.Errorf("cbor: offset %d outside input of %d bytes", off, size)
+	}
+	var buf [9]byte // the longest header
+	b := buf[:min(int64(len(buf)), size-off)]
+	// At the end of the input there's nothing to read, and ReadHeader reports the truncation.
+	if len(b) > 0 {
+		if _, err := r.ReadAt(b, off); err != nil && err != io.EOF {
+			return Node{}, err
+		}
+	}
+	major, arg, indefinite, headerSize, err := ReadHeader(b)
+	if err != nil {
+		// Make the error's offset relative to the start of the input.
+		var syntaxErr *SyntaxError
+		var eofErr *UnexpectedEOFError
+		switch {
+		case errors.As(err, &syntaxErr):
+			syntaxErr.Offset += off
+		case errors.As(err, &eofErr):
+			eofErr.Offset += off
+		}
+		return Node{}, err
+	}
+	if major == MajorSimple && headerSize == 2 && arg < 32 {
+		return Node{}, &SyntaxError{fmt.Sprintf("invalid simple value %d in two-byte form", arg), off, ErrMalformed}
+	}
+	return Node{r, size, off, headerSize, major, arg, indefinite}, nil
+}
+
+// Offset returns the offset of n's header in the input.
+func (n Node) Offset() int64 {
+	return n.off
+}
+
+// Header returns the major type and argument of n, as returned by ReadHeader.
+func (n Node) Header() (major MajorType, arg uint64, indefinite bool) {
+	return n.major, n.arg, n.indefinite
+}
+
+// End returns the offset just past the end of n, skipping over its contents to find it.
+func (n Node) End() (int64, error) {
+	return n.end(0)
+}
+
+// Raw reads the whole encoding of n.
+func (n Node) Raw() (RawMessage, error) {
+	end, err := n.End()
+	if err != nil {
+		return nil, err
+	}
+	if end-n.off > math.MaxInt {
+		return nil, fmt.Errorf("cbor: item of %d bytes is too large to read", end-n.off)
+	}
+	b := make([]byte, end-n.off)
+	if _, err := n.r.ReadAt(b, n.off); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Content returns the content of n, which must be a tag.
+func (n Node) Content() (Node, error) {
+	if n.major != MajorTag {
+		return Node{}, fmt.Errorf("cbor: expected a tag but found major type %d", n.major)
+	}
+	return n.next(n.off + int64(n.headerSize))
+}
+
+// Index returns the element of n, which must be an array, at index i. Finding it takes time proportional to
+// the size of the items before it (not counting their strings).
+func (n Node) Index(i int) (Node, error) {
+	if n.major != MajorArray {
+		return Node{}, fmt.Errorf("cbor: expected an array but found major type %d", n.major)
+	}
+	var found Node
+	ok, _, err := n.children(0, func(j int, child Node) (bool, error) {
+		if j == i {
+			found = child
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return Node{}, err
+	}
+	if !ok {
+		return Node{}, fmt.Errorf("cbor: array index %d out of range", i)
+	}
+	return found, nil
+}
+
+// Get returns the value of the first entry of n, which must be a map, whose key is encoded as key (using
+// the same encoding of the key, byte for byte). It reports false if there is no such entry.
+func (n Node) Get(key RawMessage) (Node, bool, error) {
+	if n.major != MajorMap {
+		return Node{}, false, fmt.Errorf("cbor: expected a map but found major type %d", n.major)
+	}
+	var found Node
+	var match bool
+	buf := make([]byte, len(key))
+	ok, _, err := n.children(0, func(j int, child Node) (bool, error) {
+		if j%2 == 1 {
+			if match {
+				found = child
+				return true, nil
+			}
+			return false, nil
+		}
+		end, err := child.End()
+		if err != nil {
+			return false, err
+		}
+		match = false
+		if end-child.off == int64(len(key)) {
+			if _, err := n.r.ReadAt(buf, child.off); err != nil && err != io.EOF {
+				return false, err
+			}
+			match = bytes.Equal(buf, key)
+		}
+		return false, nil
+	})
+	return found, ok, err
+}
+
+// Lookup returns the value of the first entry of n, which must be a map, with the text string key name.
+func (n Node) Lookup(name string) (Node, bool, error) {
+	e := &encodeState{}
+	e.writeTextString(name)
+	return n.Get(e.Bytes())
+}
+
+// next returns the node at off, which is inside n.
+func (n Node) next(off int64) (Node, error) {
+	return ReadNodeAt(n.r, off, n.size)
+}
+
+// isBreak reports whether the byte at off is the break code.
+func (n Node) isBreak(off int64) (bool, error) {
+	var b [1]byte
+	if _, err := n.r.ReadAt(b[:], off); err != nil {
+		if err == io.EOF {
+			return false, &UnexpectedEOFError{n.size, 1}
+		}
+		return false, err
+	}
+	return b[0] == makeIDByte(typeMajor7, typeBreak), nil
+}
+
+// children calls fn with each element of n, an array or map (where keys and values are passed separately),
+// with its index, until fn returns true. It reports whether fn did and, if not, returns the offset after
+// the last element (before the break code of an indefinite-length item). The elements are inside depth
+// arrays, maps, and tags.
+func (n Node) children(depth int, fn func(i int, child Node) (bool, error)) (done bool, end int64, err error) {
+	count := n.arg
+	if n.major == MajorMap {
+		count *= 2
+		if n.arg > math.MaxUint64/2 {
+			count = math.MaxUint64
+		}
+	}
+	off := n.off + int64(n.headerSize)
+	for i := 0; n.indefinite || uint64(i) < count; i++ {
+		if n.indefinite && (n.major == MajorArray || i%2 == 0) {
+			brk, err := n.isBreak(off)
+			if err != nil {
+				return false, 0, err
+			}
+			if brk {
+				return false, off, nil
+			}
+		}
+		child, err := n.next(off)
+		if err != nil {
+			return false, 0, err
+		}
+		if done, err := fn(i, child); done || err != nil {
+			return done, 0, err
+		}
+		if off, err = child.end(depth); err != nil {
+			return false, 0, err
+		}
+	}
+	return false, off, nil
+}
+
+// end implements End; depth is the number of arrays, maps, and tags that n is inside of.
+func (n Node) end(depth int) (int64, error) {
+	off := n.off + int64(n.headerSize)
+	switch n.major {
+	case MajorUnsigned, MajorNegative:
+		return off, nil
+	case MajorBytes, MajorText:
+		if !n.indefinite {
+			if remaining := uint64(n.size - off); n.arg > remaining {
+				return 0, &UnexpectedEOFError{n.size, int(min(n.arg-remaining, math.MaxInt))}
+			}
+			return off + int64(n.arg), nil
+		}
+		for {
+			brk, err := n.isBreak(off)
+			if err != nil {
+				return 0, err
+			}
+			if brk {
+				return off + 1, nil
+			}
+			chunk, err := n.next(off)
+			if err != nil {
+				return 0, err
+			}
+			if chunk.major != n.major || chunk.indefinite {
+				return 0, &SyntaxError{"invalid chunk in indefinite-length string", off, ErrMalformed}
+			}
+			if off, err = chunk.end(depth); err != nil {
+				return 0, err
+			}
+		}
+	case MajorArray, MajorMap, MajorTag:
+		if depth++; depth > maxNestingDepth {
+			return 0, &SyntaxError{"exceeded max depth", n.off, ErrMaxDepth}
+		}
+	case MajorSimple:
+		if n.indefinite {
+			return 0, &SyntaxError{"unexpected break", n.off, ErrMalformed}
+		}
+		return off, nil
+	}
+	if n.major == MajorTag {
+		content, err := n.next(off)
+		if err != nil {
+			return 0, err
+		}
+		return content.end(depth)
+	}
+	_, end, err := n.children(depth, func(int, Node) (bool, error) { return false, nil })
+	if err != nil {
+		return 0, err
+	}
+	if n.indefinite {
+		end++ // break
+	}
+	return end, nil
+}
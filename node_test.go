@@ -0,0 +1,104 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// countingReaderAt counts the bytes read from it.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += n
+	return n, err
+}
+
+func TestNode(t *testing.T) {
+	big := strings.Repeat("x", 1<<20)
+	data := mustMarshal(t, OrderedMap{
+		{"blob", []byte(big)},
+		{"list", []interface{}{big, RawMessage(mustDecodeHex(t, "9f0102ff")), 3}},
+		{"tagged", RawMessage(mustDecodeHex(t, "c1a16178f5"))},
+	})
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	root, err := ReadNodeAt(r, 0, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok, err := root.Lookup("list")
+	if err != nil || !ok {
+		t.Fatalf("Lookup(list): got %t, %v", ok, err)
+	}
+	elem, err := list.Index(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := elem.Raw(); err != nil || !bytes.Equal(raw, []byte{0x03}) {
+		t.Errorf("list[2]: got %x, %v; want 03", raw, err)
+	}
+	elem, err = list.Index(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end, err := elem.End(); err != nil || end-elem.Offset() != 4 {
+		t.Errorf("list[1]: got end %d, %v; want %d", end, err, elem.Offset()+4)
+	}
+	if _, err := list.Index(3); err == nil {
+		t.Error("list[3]: expected an error")
+	}
+	if r.n > 1000 {
+		t.Errorf("read %d bytes; expected the strings to be skipped", r.n)
+	}
+
+	tagged, ok, err := root.Lookup("tagged")
+	if err != nil || !ok {
+		t.Fatalf("Lookup(tagged): got %t, %v", ok, err)
+	}
+	content, err := tagged.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := content.Lookup("x")
+	if err != nil || !ok {
+		t.Fatalf("Lookup(x): got %t, %v", ok, err)
+	}
+	if major, arg, _ := v.Header(); major != MajorSimple || arg != typeTrue {
+		t.Errorf("x: got major type %d, argument %d; want true", major, arg)
+	}
+	if _, ok, err := root.Lookup("nope"); ok || err != nil {
+		t.Errorf("Lookup(nope): got %t, %v; want false, nil", ok, err)
+	}
+}
+
+func TestNodeErrors(t *testing.T) {
+	for _, test := range []struct {
+		input    string // hex
+		expected error
+	}{
+		{"5a00010000", ErrTruncated},
+		{"9f01", ErrTruncated},
+		{"8201", ErrTruncated},
+		{"bb80000000000000000102", ErrTruncated}, // 2^63 entries
+		{"5f01ff", ErrMalformed},
+		{"81ff", ErrMalformed},
+		{"f818", ErrMalformed},
+		{"1c", ErrMalformed},
+		{strings.Repeat("81", maxNestingDepth+1) + "00", ErrMaxDepth},
+	} {
+		data := mustDecodeHex(t, test.input)
+		n, err := ReadNodeAt(bytes.NewReader(data), 0, int64(len(data)))
+		if err == nil {
+			_, err = n.End()
+		}
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%.20s: expected %v; got %v", test.input, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cbor
+
+// An Optional holds a struct field that has three states: absent, null, or present with a value, as for
+// the fields of a PATCH-style request, where leaving a field out and setting it to null mean different
+// things. The zero Optional is absent.
+//
+// An absent Optional is empty, so a field with the omitempty option is left out of its struct's encoding.
+// (Without omitempty, absent Optionals are encoded as null.) A null Optional is encoded as null, and a
+// present one as its value.
+//
+// TODO: Decode absent keys, null, and other values into the three states once there's a decoder.
+type Optional[T any] struct {
+	value T
+	state optionalState
+}
+
+type optionalState uint8
+
+const (
+	optionalAbsent optionalState = iota
+	optionalNull
+	optionalPresent
+)
+
+// Some returns a present Optional holding v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{value: v, state: optionalPresent}
+}
+
+// Null returns a null Optional.
+func Null[T any]() Optional[T] {
+	return Optional[T]{state: optionalNull}
+}
+
+// IsPresent reports whether o holds a value.
+func (o Optional[T]) IsPresent() bool { return o.state == optionalPresent }
+
+// IsNull reports whether o is null.
+func (o Optional[T]) IsNull() bool { return o.state == optionalNull }
+
+// Get returns o's value and true if o is present, or the zero value of T and false otherwise.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.state == optionalPresent
+}
+
+// MarshalCBORTo implements StreamMarshaler.
+func (o Optional[T]) MarshalCBORTo(enc *Encoder) error {
+	if o.state != optionalPresent {
+		return enc.writeByte(makeIDByte(typeMajor7, typeNull))
+	}
+	return enc.Encode(o.value)
+}
+
+func (o Optional[T]) isAbsent() bool { return o.state == optionalAbsent }
+
+// absenter is implemented by Optional, for isEmptyValue and isUnset.
+type absenter interface {
+	isAbsent() bool
+}
@@ -0,0 +1,38 @@
+package cbor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOptional(t *testing.T) {
+	type patch struct {
+		Name  Optional[string] `cbor:"name,omitempty"`
+		Email Optional[string] `cbor:"email,omitempty"`
+		Age   Optional[int]    `cbor:"age,omitempty"`
+		Tags  Optional[[]string]
+	}
+	for _, test := range []struct {
+		input    patch
+		expected interface{}
+	}{
+		{patch{}, OrderedMap{{"Tags", nil}}},
+		{
+			patch{Name: Some("gopher"), Email: Null[string](), Age: Some(0), Tags: Some([]string{"a"})},
+			OrderedMap{{"name", "gopher"}, {"email", nil}, {"age", 0}, {"Tags", []string{"a"}}},
+		},
+	} {
+		actual := mustMarshal(t, test.input)
+		if expected := mustMarshal(t, test.expected); !bytes.Equal(actual, expected) {
+			t.Errorf("%+v: expected %x; got %x", test.input, expected, actual)
+		}
+	}
+
+	o := Some(3)
+	if v, ok := o.Get(); !ok || v != 3 || !o.IsPresent() || o.IsNull() {
+		t.Errorf("Some(3): got Get() = %d, %t; IsPresent() = %t; IsNull() = %t", v, ok, o.IsPresent(), o.IsNull())
+	}
+	if o := Null[int](); o.IsPresent() || !o.IsNull() {
+		t.Errorf("Null: got IsPresent() = %t; IsNull() = %t", o.IsPresent(), o.IsNull())
+	}
+}
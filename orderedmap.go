@@ -0,0 +1,76 @@
+package cbor
+
+import (
+	"iter"
+	"reflect"
+)
+
+// A MapItem is a single key/value pair of an OrderedMap.
+type MapItem struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// An OrderedMap is a CBOR map that keeps its entries in order. Go maps are encoded with their keys sorted, but
+// an OrderedMap is encoded with its entries in slice order, for protocols where the order of a map's entries
+// is meaningful. A nil OrderedMap is encoded as null.
+//
+// Keys are compared using reflect.DeepEqual.
+type OrderedMap []MapItem
+
+// Get returns the value of the first entry in m with the given key and whether any such entry exists.
+func (m OrderedMap) Get(key interface{}) (interface{}, bool) {
+	if i := m.index(key); i >= 0 {
+		return m[i].Value, true
+	}
+	return nil, false
+}
+
+// Set sets the value of the first entry in m with the given key, appending a new entry if there is none.
+func (m *OrderedMap) Set(key, value interface{}) {
+	if i := m.index(key); i >= 0 {
+		(*m)[i].Value = value
+		return
+	}
+	*m = append(*m, MapItem{key, value})
+}
+
+// Delete removes every entry in m with the given key.
+func (m *OrderedMap) Delete(key interface{}) {
+	items := (*m)[:0]
+	for _, item := range *m {
+		if !reflect.DeepEqual(item.Key, key) {
+			items = append(items, item)
+		}
+	}
+	*m = items
+}
+
+// Keys returns the keys of m in order.
+func (m OrderedMap) Keys() []interface{} {
+	keys := make([]interface{}, len(m))
+	for i, item := range m {
+		keys[i] = item.Key
+	}
+	return keys
+}
+
+func (m OrderedMap) index(key interface{}) int {
+	for i, item := range m {
+		if reflect.DeepEqual(item.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CBORMapItems implements MapIterator, yielding the entries of m in order.
+func (m OrderedMap) CBORMapItems() iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		for _, item := range m {
+			if !yield(item.Key, item.Value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package cbor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	var m OrderedMap
+	m.Set("b", 1)
+	m.Set([]byte{1}, 2)
+	m.Set("a", 3)
+	m.Set("b", 4)
+	if v, ok := m.Get("b"); !ok || v != 4 {
+		t.Errorf(`Get("b"): expected 4, true; got %v, %t`, v, ok)
+	}
+	if v, ok := m.Get([]byte{1}); !ok || v != 2 {
+		t.Errorf(`Get([]byte{1}): expected 2, true; got %v, %t`, v, ok)
+	}
+	if _, ok := m.Get("c"); ok {
+		t.Error(`Get("c"): expected no entry`)
+	}
+	m.Delete([]byte{1})
+	if expected := []interface{}{"b", "a"}; !reflect.DeepEqual(m.Keys(), expected) {
+		t.Errorf("expected keys %v; got %v", expected, m.Keys())
+	}
+}
@@ -0,0 +1,89 @@
+package cbor
+
+import (
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// EncodeParallel encodes the values yielded by seq to enc using up to workers goroutines at once (or
+// GOMAXPROCS goroutines, if workers isn't positive), for bulk jobs that write many independent records. The
+// values are encoded concurrently into pooled buffers but written in the order seq yields them, so the
+// output is the same as calling Encode with each value in turn. (The Encoder's stats hook isn't called,
+// though.)
+//
+// The values must not be modified once they've been yielded, since they may still be being encoded while
+// seq runs. If encoding or writing a value fails, EncodeParallel stops taking values from seq and returns
+// the error, after writing the values before the one that failed.
+func EncodeParallel[T any](enc *Encoder, seq iter.Seq[T], workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	type result struct {
+		e   *encodeState
+		err error
+	}
+	type job struct {
+		v      T
+		result chan<- result
+	}
+	jobs := make(chan job)
+	// The results, in the order of seq. Its capacity bounds how far encoding can run ahead of writing.
+	pending := make(chan chan result, 2*workers)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for v := range seq {
+			r := make(chan result, 1)
+			select {
+			case pending <- r:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- job{v, r}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for range workers {
+		go func() {
+			for j := range jobs {
+				e := newPooledEncodeState(enc.encOpts)
+				err := e.marshal(j.v)
+				j.result <- result{e, err}
+			}
+		}()
+	}
+
+	for r := range pending {
+		res := <-r
+		err := res.err
+		if err == nil {
+			err = enc.write(res.e.Bytes())
+		}
+		encodeStatePool.Put(res.e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var encodeStatePool sync.Pool
+
+// newPooledEncodeState returns an empty encodeState from encodeStatePool, or a new one.
+func newPooledEncodeState(opts encOpts) *encodeState {
+	e, ok := encodeStatePool.Get().(*encodeState)
+	if !ok {
+		return &encodeState{encOpts: opts}
+	}
+	buf := e.Buffer
+	buf.Reset()
+	*e = encodeState{Buffer: buf, encOpts: opts}
+	return e
+}
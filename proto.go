@@ -1,5 +1,7 @@
 package cbor
 
+import "encoding/binary"
+
 const (
 	// The first 6 major types have constants equal to their byte value
 	typePosInt     byte = 0
@@ -32,3 +34,75 @@ var additionalLength = [...]byte{
 	4: 26,
 	8: 27,
 }
+
+// The additional information value for indefinite-length items
+const indefiniteLength = 31
+
+// Tag numbers
+const (
+	tagDateTime       = 0    // RFC 3339 date/time string
+	tagEpochTime      = 1    // seconds since the Unix epoch
+	tagPosBignum      = 2    // unsigned bignum: the magnitude in a byte string
+	tagNegBignum      = 3    // negative bignum: -1 minus the value in a byte string
+	tagRational       = 30   // rational number: [numerator, denominator]
+	tagNDArray        = 40   // multi-dimensional array in row-major order: [dimensions, elements]
+	tagIPv4           = 52   // RFC 9164 IPv4 address or prefix
+	tagIPv6           = 54   // RFC 9164 IPv6 address or prefix
+	tagSequence       = 63   // RFC 8742 CBOR sequence in a byte string
+	tagDays           = 100  // RFC 8943 date: days since 1970-01-01
+	tagLegacyIPAddr   = 260  // network address (superseded by tags 52 and 54)
+	tagLegacyIPPrefix = 261  // network address and prefix length: {address: length} (superseded likewise)
+	tagEmbeddedJSON   = 262  // JSON text in a byte string
+	tagExtendedTime   = 1001 // RFC 9581 extended time: a map of time components
+	tagDuration       = 1002 // RFC 9581 duration: a map like an extended time's
+	tagFullDate       = 1004 // RFC 8943 date: an RFC 3339 full-date string
+	tagNDArrayCol     = 1040 // multi-dimensional array in column-major order
+)
+
+// A MajorType is the type of a CBOR data item, given by the top three bits of its initial byte.
+type MajorType byte
+
+// The major types (RFC 8949, section 3.1).
+const (
+	MajorUnsigned MajorType = iota // unsigned integer
+	MajorNegative                  // negative integer
+	MajorBytes                     // byte string
+	MajorText                      // text string
+	MajorArray                     // array
+	MajorMap                       // map
+	MajorTag                       // tagged item
+	MajorSimple                    // floats, simple values, and the break code
+)
+
+// AppendHeader appends the header of an item of the given major type with the argument n to dst, using the
+// shortest encoding of n, and returns the extended slice. The argument is the integer itself (for
+// MajorUnsigned), -1 minus the integer (for MajorNegative), the length in bytes or elements (for strings and
+// containers; maps count entries), the tag number, or the simple value (which must not be 24 through 31).
+// The contents of strings and containers and the content of tags are not included.
+func AppendHeader(dst []byte, major MajorType, n uint64) []byte {
+	m := byte(major)
+	switch {
+	case n < 24:
+		return append(dst, makeIDByte(m, byte(n)))
+	case n < 1<<8:
+		return append(dst, makeIDByte(m, additionalLength[1]), byte(n))
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16(append(dst, makeIDByte(m, additionalLength[2])), uint16(n))
+	case n < 1<<32:
+		return binary.BigEndian.AppendUint32(append(dst, makeIDByte(m, additionalLength[4])), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(dst, makeIDByte(m, additionalLength[8])), n)
+}
+
+// ReadHeader decodes the header at the start of data, returning its major type and argument and the number
+// of bytes it takes. For indefinite-length strings and containers and for the break code, indefinite is true
+// and n is 0. For MajorSimple, n is the simple value or the bits of a float, whose width is told by size
+// (3, 5, or 9 bytes).
+func ReadHeader(data []byte) (major MajorType, n uint64, indefinite bool, size int, err error) {
+	s := &scanner{data: data}
+	m, _, n, indefinite, err := s.header()
+	if err != nil {
+		return 0, 0, false, 0, err
+	}
+	return MajorType(m), n, indefinite, s.off, nil
+}
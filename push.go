@@ -0,0 +1,102 @@
+package cbor
+
+import (
+	"context"
+	"io"
+)
+
+// A Parser splits a CBOR sequence (RFC 8742) that arrives in arbitrary pieces into its top-level items, for
+// callers such as event loops and protocol handlers that are handed bytes as they come in rather than an
+// io.Reader. Input is fed to the Parser with Write, and each item is passed to the emit function as soon as
+// its last byte has been written.
+//
+// The items are checked for well-formedness but not decoded. The Parser keeps its place in a partial item
+// between writes, the way a Validator does, so feeding an item in small pieces costs no more than feeding it
+// all at once.
+type Parser struct {
+	emit     func(RawMessage) error
+	v        Validator // finds where the items end
+	ends     []int64   // offsets in the stream where items in buf end
+	buf      []byte    // input not yet emitted
+	consumed int64     // bytes emitted so far
+	err      error
+}
+
+// NewParser returns a Parser that calls emit with each complete item. The RawMessage passed to emit is not
+// modified by later writes and may be retained. If emit returns an error, parsing stops and that error is
+// returned by Write.
+func NewParser(emit func(RawMessage) error) *Parser {
+	p := &Parser{emit: emit, v: Validator{maxDepth: maxNestingDepth}}
+	p.v.itemEnd = func(off int64) { p.ends = append(p.ends, off) }
+	return p
+}
+
+// Write appends b to the input and emits every item it completes. It always consumes all of b, unless the
+// input is malformed or emit fails; that error is returned by this and every later call to Write.
+func (p *Parser) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	p.buf = append(p.buf, b...)
+	_, err := p.v.Write(b)
+	// The items that ended before any syntax error are emitted first.
+	for _, end := range p.ends {
+		n := int(end - p.consumed)
+		// Capping the capacity keeps later appends to buf from writing into the emitted item.
+		item := RawMessage(p.buf[:n:n])
+		p.buf = p.buf[n:]
+		p.consumed = end
+		if emitErr := p.emit(item); emitErr != nil {
+			p.ends = p.ends[:0]
+			p.err = emitErr
+			return len(b), emitErr
+		}
+	}
+	p.ends = p.ends[:0]
+	if err != nil {
+		p.err = err
+		return len(b), err
+	}
+	return len(b), nil
+}
+
+// Buffered returns the number of bytes written that are part of an item not yet complete.
+func (p *Parser) Buffered() int {
+	return len(p.buf)
+}
+
+// Close reports an error if the input ended partway through an item, or if an earlier Write failed.
+func (p *Parser) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.v.Close()
+}
+
+// ReadItems reads the CBOR sequence in r in a new goroutine and sends each of its items on the returned
+// channel, for consumers that feed a long-lived stream to a pool of workers. The channel has the given
+// capacity; once it's full, reading stops until the consumer catches up.
+//
+// When the input ends, or reading fails, or ctx is canceled, the item channel is closed and then the
+// result (nil at the end of well-formed input) is sent on the error channel.
+func ReadItems(ctx context.Context, r io.Reader, capacity int) (<-chan RawMessage, <-chan error) {
+	items := make(chan RawMessage, capacity)
+	errc := make(chan error, 1)
+	go func() {
+		p := NewParser(func(item RawMessage) error {
+			select {
+			case items <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		_, err := io.CopyBuffer(p, r, make([]byte, 32<<10))
+		if err == nil {
+			err = p.Close()
+		}
+		close(items)
+		errc <- err
+	}()
+	return items, errc
+}
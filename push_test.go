@@ -0,0 +1,159 @@
+package cbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	expected := []string{"01", "63616263", "82018102", "bf0102ff", "1a000f4240"}
+	var input []byte
+	for _, s := range expected {
+		input = append(input, mustDecodeHex(t, s)...)
+	}
+	for _, size := range []int{1, 2, 3, 7, len(input)} {
+		var actual []string
+		p := NewParser(func(raw RawMessage) error {
+			actual = append(actual, hex.EncodeToString(raw))
+			return nil
+		})
+		for b := input; len(b) > 0; {
+			n := min(size, len(b))
+			if _, err := p.Write(b[:n]); err != nil {
+				t.Fatalf("writing in pieces of %d bytes: %s", size, err)
+			}
+			b = b[n:]
+		}
+		if err := p.Close(); err != nil {
+			t.Fatalf("writing in pieces of %d bytes: Close: %s", size, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("writing in pieces of %d bytes: expected items %q; got %q", size, expected, actual)
+		}
+	}
+}
+
+func TestParserSmallWrites(t *testing.T) {
+	// An array of many small items, written a byte at a time, takes time proportional to its length: the
+	// Parser doesn't rescan the array from its start after each write.
+	input := append(mustDecodeHex(t, "9a00100000"), make([]byte, 1<<20)...)
+	var items int
+	p := NewParser(func(raw RawMessage) error {
+		items++
+		if len(raw) != len(input) {
+			t.Errorf("got an item of %d bytes; want %d", len(raw), len(input))
+		}
+		return nil
+	})
+	for i := range input {
+		if _, err := p.Write(input[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+		if i < len(input)-1 && p.Buffered() != i+1 {
+			t.Fatalf("after %d bytes: Buffered returned %d", i+1, p.Buffered())
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if items != 1 {
+		t.Errorf("got %d items; want 1", items)
+	}
+}
+
+func TestParserRetainedItems(t *testing.T) {
+	var items []RawMessage
+	p := NewParser(func(raw RawMessage) error {
+		items = append(items, raw)
+		return nil
+	})
+	for _, s := range []string{"0102", "03", "0405", "06"} {
+		if _, err := p.Write(mustDecodeHex(t, s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, raw := range items {
+		if len(raw) != 1 || raw[0] != byte(i+1) {
+			t.Errorf("item %d: got %x", i, raw)
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	p := NewParser(func(RawMessage) error { return nil })
+	if _, err := p.Write(mustDecodeHex(t, "0119")); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Buffered(); n != 1 {
+		t.Errorf("Buffered: got %d; want 1", n)
+	}
+	err := p.Close()
+	var eofErr *UnexpectedEOFError
+	if !errors.As(err, &eofErr) || eofErr.Offset != 2 || eofErr.Needed != 2 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Close with a partial item: got %v", err)
+	}
+
+	// A huge declared length mustn't overflow the count of bytes needed.
+	p = NewParser(func(RawMessage) error { return nil })
+	if _, err := p.Write(mustDecodeHex(t, "5bffffffffffffffff")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); !errors.As(err, &eofErr) || eofErr.Needed <= 0 {
+		t.Errorf("Close with a huge partial item: got %v", err)
+	}
+
+	p = NewParser(func(RawMessage) error { return nil })
+	_, err = p.Write(mustDecodeHex(t, "0102ff"))
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Offset != 2 {
+		t.Errorf("Write with a stray break: got %v", err)
+	}
+	if _, err2 := p.Write([]byte{0}); err2 != err {
+		t.Errorf("Write after an error: got %v; want %v", err2, err)
+	}
+
+	stop := errors.New("stop")
+	p = NewParser(func(RawMessage) error { return stop })
+	if _, err := p.Write(mustDecodeHex(t, "0102")); err != stop {
+		t.Errorf("Write with a failing emit: got %v; want %v", err, stop)
+	}
+	if err := p.Close(); err != stop {
+		t.Errorf("Close after a failing emit: got %v; want %v", err, stop)
+	}
+}
+
+func TestReadItems(t *testing.T) {
+	items, errc := ReadItems(context.Background(), bytes.NewReader(mustDecodeHex(t, "016361626382018102")), 1)
+	var actual []string
+	for item := range items {
+		actual = append(actual, hex.EncodeToString(item))
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"01", "63616263", "82018102"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got items %q; want %q", actual, expected)
+	}
+
+	items, errc = ReadItems(context.Background(), bytes.NewReader(mustDecodeHex(t, "018301")), 0)
+	for range items {
+	}
+	if err := <-errc; !errors.Is(err, ErrTruncated) {
+		t.Errorf("got error %v; want ErrTruncated", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	items, errc = ReadItems(ctx, bytes.NewReader(mustDecodeHex(t, "0102")), 0)
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v; want context.Canceled", err)
+	}
+	if _, ok := <-items; ok {
+		t.Error("got an item after cancellation")
+	}
+}
@@ -0,0 +1,146 @@
+package cbor
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+)
+
+// RawMessage is a raw encoded CBOR item. It implements Marshaler and can be used to delay CBOR decoding or
+// to precompute a CBOR encoding. A map[string]RawMessage, such as the outer map of an envelope whose values
+// are passed along untouched, is encoded on a fast path that copies the values verbatim.
+type RawMessage []byte
+
+// MarshalCBOR returns m as the CBOR encoding of m.
+func (m RawMessage) MarshalCBOR() ([]byte, error) {
+	if m == nil {
+		return []byte{makeIDByte(typeMajor7, typeNull)}, nil
+	}
+	return m, nil
+}
+
+var rawMessageMapType = reflect.TypeOf(map[string]RawMessage(nil))
+
+// writeRawMessageMap writes m, a map[string]RawMessage such as the outer map of an envelope, without
+// reflecting on its values: each one is copied verbatim, as its MarshalCBOR would return it.
+func (e *encodeState) writeRawMessageMap(m map[string]RawMessage) {
+	// Encode all the keys into one buffer, then sort the entries by them.
+	var keys []byte
+	ends := make([]int, 0, len(m))
+	entries := make([]RawMapEntry, 0, len(m))
+	for k, v := range m {
+		if !utf8.ValidString(k) {
+			e.error(&InvalidUTF8Error{k})
+		}
+		keys = append(AppendHeader(keys, MajorText, uint64(len(k))), k...)
+		ends = append(ends, len(keys))
+		entries = append(entries, RawMapEntry{Value: v})
+	}
+	start := 0
+	for i, end := range ends {
+		entries[i].Key = RawMessage(keys[start:end])
+		start = end
+	}
+	less := e.mapKeyOrder
+	if less == nil {
+		less = LengthFirstKeyOrder
+	}
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i].Key, entries[j].Key) })
+	e.writeMajorWithNumber(typeMap, uint64(len(m)))
+	for _, entry := range entries {
+		e.Write(entry.Key)
+		if entry.Value == nil {
+			e.writeSimple(typeNull)
+		} else {
+			e.Write(entry.Value)
+		}
+	}
+}
+
+// ArrayElements returns an iterator over the elements of the array encoded in data. Each element is yielded
+// as a RawMessage sharing data's memory, without being decoded, so arbitrarily large arrays may be processed
+// one element at a time. Elements are checked for well-formedness as they are reached; if data is malformed
+// or isn't an array, the iterator yields a non-nil error and stops.
+func ArrayElements(data []byte) iter.Seq2[RawMessage, error] {
+	return func(yield func(RawMessage, error) bool) {
+		err := scanContainer(data, typeList, func(raw RawMessage) bool {
+			return yield(raw, nil)
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// A RawMapEntry is a single key/value pair of an encoded map.
+type RawMapEntry struct {
+	Key   RawMessage
+	Value RawMessage
+}
+
+// MapEntries returns an iterator over the key/value pairs of the map encoded in data, in the order they
+// appear. As with ArrayElements, the keys and values are not decoded and share data's memory, and if data is
+// malformed or isn't a map, the iterator yields a non-nil error and stops.
+func MapEntries(data []byte) iter.Seq2[RawMapEntry, error] {
+	return func(yield func(RawMapEntry, error) bool) {
+		var key RawMessage
+		err := scanContainer(data, typeMap, func(raw RawMessage) bool {
+			if key == nil {
+				key = raw
+				return true
+			}
+			entry := RawMapEntry{key, raw}
+			key = nil
+			return yield(entry, nil)
+		})
+		if err != nil {
+			yield(RawMapEntry{}, err)
+		}
+	}
+}
+
+var containerNames = map[byte]string{
+	typeList: "an array",
+	typeMap:  "a map",
+}
+
+// scanContainer checks that data holds a single array or map (according to major) and calls fn with each of
+// its elements in turn; for maps, each key and each value is passed separately. Scanning stops without error
+// if fn returns false.
+func scanContainer(data []byte, major byte, fn func(RawMessage) bool) error {
+	s := &scanner{data: data}
+	m, _, n, indefinite, err := s.header()
+	if err != nil {
+		return err
+	}
+	if m != major {
+		return fmt.Errorf("cbor: expected %s but found major type %d", containerNames[major], m)
+	}
+	if remaining := uint64(len(data) - s.off); n > remaining {
+		// Every item takes at least one byte.
+		return s.eof(n - remaining)
+	}
+	if major == typeMap {
+		n *= 2
+	}
+	for i := uint64(0); indefinite || i < n; i++ {
+		// A break may not come between a key and its value.
+		if indefinite && s.isBreak() && (major == typeList || i%2 == 0) {
+			s.off++
+			break
+		}
+		start := s.off
+		if err := s.skip(); err != nil {
+			return err
+		}
+		if !fn(RawMessage(data[start:s.off:s.off])) {
+			return nil
+		}
+	}
+	if s.off != len(data) {
+		return s.errorf("trailing data after top-level item")
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestArrayElements(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected []string
+	}{
+		{"80", nil},
+		{"83010203", []string{"01", "02", "03"}},
+		{"8301820203820405", []string{"01", "820203", "820405"}},
+		{"9f018202039f0405ffff", []string{"01", "820203", "9f0405ff"}},
+		{"826161a161626163", []string{"6161", "a161626163"}},
+		{"825f42010243030405ff1903e8", []string{"5f42010243030405ff", "1903e8"}},
+	} {
+		var actual []string
+		for raw, err := range ArrayElements(mustDecodeHex(t, test.input)) {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.input, err)
+				break
+			}
+			actual = append(actual, hex.EncodeToString(raw))
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected elements %q; got %q", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestArrayElementsErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"a0",           // not an array
+		"8301",         // truncated
+		"820102ff",     // trailing data
+		"9f0102",       // missing break
+		"8201f8",       // truncated simple value
+		"81f818",       // simple value < 32 in two-byte form
+		"811c",         // reserved additional information
+		"815f41016102", // mixed chunk types
+	} {
+		var err error
+		for _, err = range ArrayElements(mustDecodeHex(t, input)) {
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("%q: expected an error, but err was nil", input)
+		}
+	}
+}
+
+func TestArrayElementsStopEarly(t *testing.T) {
+	n := 0
+	// The second element is malformed, but iteration stops before reaching it.
+	for _, err := range ArrayElements(mustDecodeHex(t, "82011c")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("expected 1 element; got %d", n)
+	}
+}
+
+func TestMapEntries(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected [][2]string
+	}{
+		{"a0", nil},
+		{"a201020304", [][2]string{{"01", "02"}, {"03", "04"}}},
+		{"a26161016162820203", [][2]string{{"6161", "01"}, {"6162", "820203"}}},
+		{"bf6346756ef563416d7421ff", [][2]string{{"6346756e", "f5"}, {"63416d74", "21"}}},
+	} {
+		var actual [][2]string
+		for entry, err := range MapEntries(mustDecodeHex(t, test.input)) {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.input, err)
+				break
+			}
+			actual = append(actual, [2]string{hex.EncodeToString(entry.Key), hex.EncodeToString(entry.Value)})
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected entries %q; got %q", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestMapEntriesErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"80",                 // not a map
+		"a20102",             // truncated
+		"a10102ff",           // trailing data
+		"bf0102",             // missing break
+		"bf01ff",             // break between key and value
+		"bb7fffffffffffffff", // absurd length
+	} {
+		var err error
+		for _, err = range MapEntries(mustDecodeHex(t, input)) {
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("%q: expected an error, but err was nil", input)
+		}
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		needed int
+	}{
+		{"", 1},
+		{"19", 2},
+		{"1a0001", 2},
+		{"43aa", 2},
+		{"83", 1},
+		{"8301", 1},
+		{"a1", 1},
+		{"9f01", 1},
+	} {
+		err := checkValid(mustDecodeHex(t, tt.input))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%q: got error %v; want io.ErrUnexpectedEOF", tt.input, err)
+			continue
+		}
+		var eofErr *UnexpectedEOFError
+		if !errors.As(err, &eofErr) {
+			t.Errorf("%q: got error of type %T; want *UnexpectedEOFError", tt.input, err)
+			continue
+		}
+		if eofErr.Offset != int64(len(tt.input)/2) || eofErr.Needed != tt.needed {
+			t.Errorf("%q: got offset %d, needed %d; want offset %d, needed %d",
+				tt.input, eofErr.Offset, eofErr.Needed, len(tt.input)/2, tt.needed)
+		}
+	}
+
+	var eofErr *UnexpectedEOFError
+	for _, err := range ArrayElements(mustDecodeHex(t, "83")) {
+		if !errors.As(err, &eofErr) || eofErr.Needed != 3 {
+			t.Errorf("ArrayElements: got error %v; want *UnexpectedEOFError needing 3 bytes", err)
+		}
+	}
+}
+
+func TestErrorContext(t *testing.T) {
+	data := mustDecodeHex(t, "a2616101616282021c")
+	expected := "" +
+		"00000000  a2 61 61 01 61 62 82 02  1c                       |.aa.ab...|\n" +
+		"                                   ^^\n"
+	if actual := ErrorContext(data, checkValid(data)); actual != expected {
+		t.Errorf("expected\n%sgot\n%s", expected, actual)
+	}
+
+	data = make([]byte, 100)
+	data = append(data, 0x83, 0x01)
+	err := fmt.Errorf("reading record: %w", checkValid(data[100:]))
+	expected = "" +
+		"00000000  83 01                                             |..|\n" +
+		"                ^^\n"
+	if actual := ErrorContext(data[100:], err); actual != expected {
+		t.Errorf("expected\n%sgot\n%s", expected, actual)
+	}
+
+	// Only the lines near the offset are shown.
+	data[60] = 0x1c
+	s := &scanner{data: data, off: 60}
+	expected = "" +
+		"00000010  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n" +
+		"00000020  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n" +
+		"00000030  00 00 00 00 00 00 00 00  00 00 00 00 1c 00 00 00  |................|\n" +
+		"                                               ^^\n" +
+		"00000040  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n" +
+		"00000050  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n"
+	if actual := ErrorContext(data, s.skip()); actual != expected {
+		t.Errorf("expected\n%sgot\n%s", expected, actual)
+	}
+
+	if actual := ErrorContext(data, errors.New("x")); actual != "" {
+		t.Errorf("expected no context for an error without an offset; got\n%s", actual)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	for _, test := range []struct {
+		major      MajorType
+		n          uint64
+		indefinite bool
+		encoded    string
+	}{
+		{MajorUnsigned, 0, false, "00"},
+		{MajorUnsigned, 23, false, "17"},
+		{MajorNegative, 24, false, "3818"},
+		{MajorBytes, 255, false, "58ff"},
+		{MajorText, 256, false, "790100"},
+		{MajorArray, 65536, false, "9a00010000"},
+		{MajorMap, 1 << 32, false, "bb0000000100000000"},
+		{MajorTag, 55799, false, "d9d9f7"},
+		{MajorSimple, 22, false, "f6"},
+		{MajorArray, 0, true, "9f"},
+		{MajorSimple, 0, true, "ff"},
+	} {
+		if !test.indefinite {
+			if actual := hex.EncodeToString(AppendHeader([]byte{}, test.major, test.n)); actual != test.encoded {
+				t.Errorf("AppendHeader(%d, %d): expected %s; got %s", test.major, test.n, test.encoded, actual)
+			}
+		}
+		data := append(mustDecodeHex(t, test.encoded), 0xaa)
+		major, n, indefinite, size, err := ReadHeader(data)
+		if err != nil {
+			t.Errorf("ReadHeader(%x): %s", data, err)
+			continue
+		}
+		if major != test.major || n != test.n || indefinite != test.indefinite || size != len(test.encoded)/2 {
+			t.Errorf("ReadHeader(%x) = %d, %d, %t, %d; want %d, %d, %t, %d", data, major, n, indefinite, size,
+				test.major, test.n, test.indefinite, len(test.encoded)/2)
+		}
+	}
+
+	if got := AppendHeader([]byte{0x82}, MajorUnsigned, 1); !reflect.DeepEqual(got, []byte{0x82, 0x01}) {
+		t.Errorf("AppendHeader didn't append: got %x", got)
+	}
+	for _, input := range []string{"", "19ff", "1c", "3f"} {
+		if _, _, _, _, err := ReadHeader(mustDecodeHex(t, input)); err == nil {
+			t.Errorf("ReadHeader(%s): expected an error, but err was nil", input)
+		}
+	}
+}
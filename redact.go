@@ -0,0 +1,33 @@
+package cbor
+
+import "crypto/sha256"
+
+// Redact returns a copy of the single item encoded in data with the items at paths matching any of patterns
+// (written as for Transformer.HandlePath) replaced, for example before logging a payload. If placeholder is
+// non-nil, each redacted item is replaced by it; otherwise, it's replaced by a byte string holding the
+// SHA-256 hash of its encoding, so that equal values can still be correlated. Everything else is kept byte
+// for byte, except that the arrays and maps holding redacted items are written with definite lengths.
+func Redact(data []byte, placeholder RawMessage, patterns ...string) (RawMessage, error) {
+	if placeholder != nil {
+		if err := checkValid(placeholder); err != nil {
+			return nil, err
+		}
+	}
+	redact := func(path Path, item RawMessage) (RawMessage, error) {
+		if placeholder != nil {
+			return placeholder, nil
+		}
+		sum := sha256.Sum256(item)
+		e := &encodeState{}
+		e.writeMajorWithNumber(typeByteString, uint64(len(sum)))
+		e.Write(sum[:])
+		return e.Bytes(), nil
+	}
+	var t Transformer
+	for _, pattern := range patterns {
+		if err := t.HandlePath(pattern, redact); err != nil {
+			return nil, err
+		}
+	}
+	return t.Transform(data)
+}
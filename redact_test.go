@@ -0,0 +1,60 @@
+package cbor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	input := mustMarshal(t, OrderedMap{
+		{"user", "gopher"},
+		{"token", "s3cr3t"},
+		{"cards", []interface{}{
+			OrderedMap{{"number", "4111"}, {"exp", "01/30"}},
+		}},
+	})
+
+	actual, err := Redact(input, mustMarshal(t, "***"), `["token"]`, `["cards"][*]["number"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := mustMarshal(t, OrderedMap{
+		{"user", "gopher"},
+		{"token", "***"},
+		{"cards", []interface{}{
+			OrderedMap{{"number", "***"}, {"exp", "01/30"}},
+		}},
+	})
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("with a placeholder: expected\n  %x\ngot\n  %x", expected, actual)
+	}
+
+	actual, err = Redact(input, nil, `["token"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(mustMarshal(t, "s3cr3t"))
+	expected = mustMarshal(t, OrderedMap{
+		{"user", "gopher"},
+		{"token", sum[:]},
+		{"cards", []interface{}{
+			OrderedMap{{"number", "4111"}, {"exp", "01/30"}},
+		}},
+	})
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("with hashing: expected\n  %x\ngot\n  %x", expected, actual)
+	}
+}
+
+func TestRedactErrors(t *testing.T) {
+	if _, err := Redact(mustDecodeHex(t, "a0"), RawMessage{0x82}, `["a"]`); err == nil {
+		t.Error("malformed placeholder: expected an error, but err was nil")
+	}
+	if _, err := Redact(mustDecodeHex(t, "a0"), nil, `["a"`); err == nil {
+		t.Error("bad pattern: expected an error, but err was nil")
+	}
+	if _, err := Redact(mustDecodeHex(t, "a1"), nil, `["a"]`); err == nil {
+		t.Error("malformed data: expected an error, but err was nil")
+	}
+}
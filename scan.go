@@ -0,0 +1,238 @@
+package cbor
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxNestingDepth is the deepest nesting of arrays, maps, and tags that the scanner will descend into before
+// giving up. (encoding/json uses the same limit.)
+const maxNestingDepth = 10000
+
+// A SyntaxError describes malformed CBOR input. It wraps ErrMalformed, or ErrMaxDepth for input nested too
+// deeply. ErrorContext shows the input around Offset.
+type SyntaxError struct {
+	msg    string
+	Offset int64 // The error was found after reading Offset bytes.
+	err    error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("cbor: %s (offset %d)", e.msg, e.Offset)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.err
+}
+
+// scanner walks over encoded CBOR items without decoding them, checking that they are well-formed.
+type scanner struct {
+	data  []byte
+	off   int // into data
+	depth int
+}
+
+// An UnexpectedEOFError is returned for input that ends in the middle of an item. It wraps both ErrTruncated
+// and io.ErrUnexpectedEOF.
+type UnexpectedEOFError struct {
+	Offset int64 // The length of the input.
+	// Needed is the minimum number of additional bytes needed to finish the header or string being read
+	// when the input ended. Completing the whole item may take more.
+	Needed int
+}
+
+func (e *UnexpectedEOFError) Error() string {
+	return fmt.Sprintf("cbor: unexpected end of input (offset %d; need at least %d more bytes)", e.Offset, e.Needed)
+}
+
+func (e *UnexpectedEOFError) Unwrap() []error {
+	return []error{ErrTruncated, io.ErrUnexpectedEOF}
+}
+
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{fmt.Sprintf(format, args...), int64(s.off), ErrMalformed}
+}
+
+func (s *scanner) depthError() error {
+	return &SyntaxError{"exceeded max depth", int64(s.off), ErrMaxDepth}
+}
+
+// eof returns an error for input that ended when at least needed more bytes were expected.
+func (s *scanner) eof(needed uint64) error {
+	return &UnexpectedEOFError{int64(len(s.data)), int(min(needed, math.MaxInt))}
+}
+
+// header reads the initial byte of an item and its argument. For indefinite-length items (and the break
+// code), indefinite is true and arg is 0. For major type 7, arg holds the simple value or the bits of the
+// float.
+func (s *scanner) header() (major, info byte, arg uint64, indefinite bool, err error) {
+	if s.off >= len(s.data) {
+		return 0, 0, 0, false, s.eof(1)
+	}
+	b := s.data[s.off]
+	major, info = b>>5, b&0x1F
+	switch {
+	case info < 24:
+		s.off++
+		return major, info, uint64(info), false, nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(s.data)-s.off-1 < n {
+			return 0, 0, 0, false, s.eof(uint64(s.off + 1 + n - len(s.data)))
+		}
+		for _, c := range s.data[s.off+1 : s.off+1+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		s.off += 1 + n
+		return major, info, arg, false, nil
+	case info == indefiniteLength:
+		switch major {
+		case typePosInt, typeNegInt, typeTag:
+			return 0, 0, 0, false, s.errorf("indefinite length not allowed for major type %d", major)
+		}
+		s.off++
+		return major, info, 0, true, nil
+	}
+	return 0, 0, 0, false, s.errorf("reserved additional information value %d", info)
+}
+
+// isBreak reports whether the next byte is the break code.
+func (s *scanner) isBreak() bool {
+	return s.off < len(s.data) && s.data[s.off] == makeIDByte(typeMajor7, typeBreak)
+}
+
+// skip advances past one complete item, returning an error if it is malformed.
+func (s *scanner) skip() error {
+	start := s.off
+	major, info, arg, indefinite, err := s.header()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case typePosInt, typeNegInt:
+		return nil
+	case typeByteString, typeTextString:
+		if !indefinite {
+			return s.skipBytes(arg)
+		}
+		for !s.isBreak() {
+			chunkStart := s.off
+			m, _, n, indef, err := s.header()
+			if err != nil {
+				return err
+			}
+			if m != major || indef {
+				s.off = chunkStart
+				return s.errorf("invalid chunk in indefinite-length string")
+			}
+			if err := s.skipBytes(n); err != nil {
+				return err
+			}
+		}
+		s.off++ // break
+		return nil
+	case typeList, typeMap, typeTag:
+		s.depth++
+		if s.depth > maxNestingDepth {
+			s.off = start
+			return s.depthError()
+		}
+		defer func() { s.depth-- }()
+	}
+	switch major {
+	case typeList, typeMap:
+		perEntry := 1
+		if major == typeMap {
+			perEntry = 2
+		}
+		if indefinite {
+			for !s.isBreak() {
+				for i := 0; i < perEntry; i++ {
+					if err := s.skip(); err != nil {
+						return err
+					}
+				}
+			}
+			s.off++ // break
+			return nil
+		}
+		for i := uint64(0); i < arg; i++ {
+			for j := 0; j < perEntry; j++ {
+				if err := s.skip(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case typeTag:
+		return s.skip()
+	}
+	// Major type 7
+	switch {
+	case indefinite:
+		s.off = start
+		return s.errorf("unexpected break")
+	case info == 24 && arg < 32:
+		s.off = start
+		return s.errorf("invalid simple value %d in two-byte form", arg)
+	}
+	return nil
+}
+
+func (s *scanner) skipBytes(n uint64) error {
+	if remaining := uint64(len(s.data) - s.off); remaining < n {
+		return s.eof(n - remaining)
+	}
+	s.off += int(n)
+	return nil
+}
+
+// readBytes is like skipBytes but returns the bytes skipped.
+func (s *scanner) readBytes(n uint64) ([]byte, error) {
+	start := s.off
+	if err := s.skipBytes(n); err != nil {
+		return nil, err
+	}
+	return s.data[start:s.off], nil
+}
+
+// checkValid verifies that data holds exactly one well-formed item.
+func checkValid(data []byte) error {
+	s := &scanner{data: data}
+	if err := s.skip(); err != nil {
+		return err
+	}
+	if s.off != len(data) {
+		return s.errorf("trailing data after top-level item")
+	}
+	return nil
+}
+
+// checkDeterministic verifies that data, a well-formed item, is in the deterministic encoding of RFC 8949,
+// section 4.2.1: it has no indefinite-length items, and every argument and float is in its shortest form.
+// (The order of map keys isn't checked.)
+func checkDeterministic(data []byte) error {
+	s := &scanner{data: data}
+	for s.off < len(data) {
+		start := s.off
+		major, info, arg, indefinite, err := s.header()
+		if err != nil {
+			return err
+		}
+		switch {
+		case indefinite:
+			return fmt.Errorf("indefinite length at offset %d", start)
+		case major == typeMajor7 && info > 24:
+			if info != shortestFloatInfo(floatValue(info, arg)) {
+				return fmt.Errorf("float not in its shortest form at offset %d", start)
+			}
+		case info != shortestInfo(arg):
+			return fmt.Errorf("argument not in its shortest form at offset %d", start)
+		}
+		if major == typeByteString || major == typeTextString {
+			s.off += int(arg)
+		}
+	}
+	return nil
+}
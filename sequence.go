@@ -0,0 +1,59 @@
+package cbor
+
+import "fmt"
+
+// A Sequence is a CBOR sequence (RFC 8742) carried inside an item, for envelope formats that nest sequences
+// in messages. It's encoded as tag 63 around a byte string that holds the items one after another. A nil
+// Sequence is encoded as null.
+type Sequence []RawMessage
+
+// MarshalCBOR implements Marshaler. It returns an error if any of the items isn't a single well-formed
+// item.
+func (s Sequence) MarshalCBOR() ([]byte, error) {
+	if s == nil {
+		return []byte{makeIDByte(typeMajor7, typeNull)}, nil
+	}
+	n := 0
+	for i, item := range s {
+		if err := checkValid(item); err != nil {
+			return nil, fmt.Errorf("cbor: sequence item %d: %w", i, err)
+		}
+		n += len(item)
+	}
+	b := AppendHeader(nil, MajorTag, tagSequence)
+	b = AppendHeader(b, MajorBytes, uint64(n))
+	for _, item := range s {
+		b = append(b, item...)
+	}
+	return b, nil
+}
+
+// SequenceItems returns the items of the sequence encoded in data, which must hold a single tag 63 item
+// (as written for a Sequence). The items share data's memory.
+func SequenceItems(data []byte) (Sequence, error) {
+	major, tag, _, n, err := ReadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if major != MajorTag || tag != tagSequence {
+		return nil, fmt.Errorf("cbor: expected tag %d", tagSequence)
+	}
+	if err := checkValid(data[n:]); err != nil {
+		return nil, err
+	}
+	major, length, indefinite, m, _ := ReadHeader(data[n:])
+	if major != MajorBytes || indefinite {
+		return nil, fmt.Errorf("cbor: tag %d content isn't a definite-length byte string", tagSequence)
+	}
+	content := data[n+m : n+m+int(length)]
+	items := Sequence{}
+	s := &scanner{data: content}
+	for s.off < len(content) {
+		start := s.off
+		if err := s.skip(); err != nil {
+			return nil, fmt.Errorf("cbor: sequence item %d: %w", len(items), err)
+		}
+		items = append(items, RawMessage(content[start:s.off:s.off]))
+	}
+	return items, nil
+}
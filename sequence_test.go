@@ -0,0 +1,38 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestSequence(t *testing.T) {
+	seq := Sequence{mustMarshal(t, 1), mustMarshal(t, "a"), mustMarshal(t, []int{2})}
+	b := mustMarshal(t, struct{ S Sequence }{seq})
+	if actual, expected := hex.EncodeToString(b), "a16153d83f450161618102"; actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+	items, err := SequenceItems(b[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, seq) {
+		t.Errorf("got items %x; want %x", items, seq)
+	}
+
+	if b := mustMarshal(t, Sequence(nil)); !bytes.Equal(b, []byte{0xf6}) {
+		t.Errorf("nil Sequence: got %x; want f6", b)
+	}
+	if items, err := SequenceItems(mustMarshal(t, Sequence{})); err != nil || len(items) != 0 {
+		t.Errorf("empty Sequence: got %x, %v", items, err)
+	}
+	if _, err := Marshal(Sequence{{0x83, 0x01}}); err == nil {
+		t.Error("expected an error for a truncated item")
+	}
+	for _, input := range []string{"d83f4283", "d83e4101", "d83f5f4101ff", "d83f01"} {
+		if _, err := SequenceItems(mustDecodeHex(t, input)); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// A ShapeKind says what kind of value a field of a Shape holds, and so which Go type its value must have.
+type ShapeKind int
+
+const (
+	ShapeAny    ShapeKind = iota // any value that Marshal accepts (encoded using reflection)
+	ShapeInt                     // int64
+	ShapeUint                    // uint64
+	ShapeFloat                   // float64
+	ShapeString                  // string
+	ShapeBytes                   // []byte
+	ShapeBool                    // bool
+	ShapeNested                  // []interface{}, laid out by the field's Shape
+)
+
+// A Shape describes the layout of a message that is encoded as a map, for programs that only learn their
+// schemas at run time (plugin systems, for instance). Compiling a Shape with CompileShape produces an encoder
+// for such messages that, like generated code, doesn't need reflection.
+type Shape struct {
+	Fields []ShapeField
+}
+
+// A ShapeField describes one entry of a Shape's map.
+type ShapeField struct {
+	Name      string
+	KeyAsInt  bool // Use Name, which must be an integer, as an integer key (as with the keyasint tag option).
+	OmitEmpty bool // Leave the entry out when the value is nil or the zero value of its kind.
+	Kind      ShapeKind
+	Shape     *Shape // The layout of a ShapeNested value.
+}
+
+// A CompiledShape encodes messages laid out as described by a Shape. It's safe for concurrent use.
+type CompiledShape struct {
+	fields []compiledField
+}
+
+type compiledField struct {
+	name      string
+	key       []byte // encoded
+	omitEmpty bool
+	encode    func(e *encodeState, v interface{}) error // v is never nil
+	isEmpty   func(v interface{}) bool
+}
+
+// CompileShape checks s and compiles it into a CompiledShape. Nested shapes may refer back to s (or to each
+// other), to describe recursive messages.
+func CompileShape(s *Shape) (*CompiledShape, error) {
+	return compileShape(s, make(map[*Shape]*CompiledShape))
+}
+
+func compileShape(s *Shape, compiled map[*Shape]*CompiledShape) (*CompiledShape, error) {
+	if c, ok := compiled[s]; ok {
+		return c, nil
+	}
+	c := &CompiledShape{fields: make([]compiledField, len(s.Fields))}
+	compiled[s] = c
+	seen := make(map[string]bool)
+	for i, sf := range s.Fields {
+		f := compiledField{name: sf.Name, omitEmpty: sf.OmitEmpty}
+		e := &encodeState{}
+		if sf.KeyAsInt {
+			n, err := strconv.ParseInt(sf.Name, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cbor: shape field %q has KeyAsInt set but isn't an integer", sf.Name)
+			}
+			e.writeInt(n)
+		} else {
+			e.writeTextString(sf.Name)
+		}
+		f.key = e.Bytes()
+		if seen[string(f.key)] {
+			return nil, fmt.Errorf("cbor: shape has more than one field with key %q", sf.Name)
+		}
+		seen[string(f.key)] = true
+
+		switch sf.Kind {
+		case ShapeAny:
+			f.encode = func(e *encodeState, v interface{}) error { return e.marshal(v) }
+			f.isEmpty = func(v interface{}) bool { return false }
+		case ShapeInt:
+			f.encode = shapeEncoder(sf.Name, func(e *encodeState, n int64) error {
+				e.writeInt(n)
+				return nil
+			})
+			f.isEmpty = func(v interface{}) bool { return v == int64(0) }
+		case ShapeUint:
+			f.encode = shapeEncoder(sf.Name, func(e *encodeState, n uint64) error {
+				e.writeMajorWithNumber(typePosInt, n)
+				return nil
+			})
+			f.isEmpty = func(v interface{}) bool { return v == uint64(0) }
+		case ShapeFloat:
+			f.encode = shapeEncoder(sf.Name, func(e *encodeState, x float64) error {
+				e.writeFloat64(x)
+				return nil
+			})
+			f.isEmpty = func(v interface{}) bool { return v == float64(0) }
+		case ShapeString:
+			f.encode = shapeEncoder(sf.Name, func(e *encodeState, s string) error {
+				if !utf8.ValidString(s) {
+					return &InvalidUTF8Error{s}
+				}
+				e.writeTextString(s)
+				return nil
+			})
+			f.isEmpty = func(v interface{}) bool { return v == "" }
+		case ShapeBytes:
+			f.encode = shapeEncoder(sf.Name, func(e *encodeState, b []byte) error {
+				e.writeByteString(b)
+				return nil
+			})
+			f.isEmpty = func(v interface{}) bool { b, _ := v.([]byte); return len(b) == 0 }
+		case ShapeBool:
+			f.encode = shapeEncoder(sf.Name, func(e *encodeState, b bool) error {
+				if b {
+					e.writeSimple(typeTrue)
+				} else {
+					e.writeSimple(typeFalse)
+				}
+				return nil
+			})
+			f.isEmpty = func(v interface{}) bool { return v == false }
+		case ShapeNested:
+			if sf.Shape == nil {
+				return nil, fmt.Errorf("cbor: shape field %q is ShapeNested but has no Shape", sf.Name)
+			}
+			nested, err := compileShape(sf.Shape, compiled)
+			if err != nil {
+				return nil, err
+			}
+			f.encode = shapeEncoder(sf.Name, nested.encode)
+			f.isEmpty = func(v interface{}) bool { values, _ := v.([]interface{}); return len(values) == 0 }
+		default:
+			return nil, fmt.Errorf("cbor: shape field %q has unknown kind %d", sf.Name, sf.Kind)
+		}
+		c.fields[i] = f
+	}
+	return c, nil
+}
+
+// shapeEncoder returns an encoding function for the named field that checks that the value has type T
+// before calling encode.
+func shapeEncoder[T any](name string, encode func(*encodeState, T) error) func(*encodeState, interface{}) error {
+	return func(e *encodeState, v interface{}) error {
+		x, ok := v.(T)
+		if !ok {
+			return fmt.Errorf("cbor: shape field %q needs a %T, not %T", name, *new(T), v)
+		}
+		return encode(e, x)
+	}
+}
+
+// Marshal returns the encoding of a message with the given field values, which must be in the order of the
+// Shape's fields and have the types given by their kinds. A nil value is encoded as null (or omitted, for
+// OmitEmpty fields). ShapeAny values are encoded with the default settings, as by the package-level
+// Marshal; there's no way to apply an Encoder's settings to them. Messages nested more deeply than Marshal
+// allows (as a cyclic value would be) fail with an error wrapping ErrMaxDepth.
+func (c *CompiledShape) Marshal(values []interface{}) ([]byte, error) {
+	e := &encodeState{}
+	if err := c.encode(e, values); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+func (c *CompiledShape) encode(e *encodeState, values []interface{}) error {
+	if len(values) != len(c.fields) {
+		return fmt.Errorf("cbor: shape has %d fields, but got %d values", len(c.fields), len(values))
+	}
+	if err := e.tryEnter(reflect.ValueOf(values)); err != nil {
+		return err
+	}
+	defer e.leave()
+	n := 0
+	for i, f := range c.fields {
+		if !f.omitted(values[i]) {
+			n++
+		}
+	}
+	e.writeMajorWithNumber(typeMap, uint64(n))
+	for i, f := range c.fields {
+		v := values[i]
+		if f.omitted(v) {
+			continue
+		}
+		e.Write(f.key)
+		if v == nil {
+			e.writeSimple(typeNull)
+			continue
+		}
+		if err := f.encode(e, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *compiledField) omitted(v interface{}) bool {
+	return f.omitEmpty && (v == nil || f.isEmpty(v))
+}
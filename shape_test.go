@@ -0,0 +1,109 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCompiledShape(t *testing.T) {
+	point := &Shape{Fields: []ShapeField{
+		{Name: "1", KeyAsInt: true, Kind: ShapeFloat},
+		{Name: "2", KeyAsInt: true, Kind: ShapeFloat},
+	}}
+	shape := &Shape{Fields: []ShapeField{
+		{Name: "id", Kind: ShapeUint},
+		{Name: "name", Kind: ShapeString},
+		{Name: "delta", Kind: ShapeInt},
+		{Name: "blob", Kind: ShapeBytes, OmitEmpty: true},
+		{Name: "ok", Kind: ShapeBool},
+		{Name: "at", Kind: ShapeNested, Shape: point},
+		{Name: "extra", Kind: ShapeAny},
+		{Name: "note", Kind: ShapeString, OmitEmpty: true},
+	}}
+	c, err := CompileShape(shape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := c.Marshal([]interface{}{
+		uint64(7), "gopher", int64(-3), []byte{}, true, []interface{}{1.5, 0.1}, []int{1, 2}, nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := mustMarshal(t, OrderedMap{
+		{"id", 7},
+		{"name", "gopher"},
+		{"delta", -3},
+		{"ok", true},
+		{"at", OrderedMap{{1, 1.5}, {2, 0.1}}},
+		{"extra", []int{1, 2}},
+	})
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("expected\n  %x\ngot\n  %x", expected, actual)
+	}
+}
+
+func TestCompiledShapeRecursive(t *testing.T) {
+	node := &Shape{}
+	node.Fields = []ShapeField{
+		{Name: "v", Kind: ShapeInt},
+		{Name: "next", Kind: ShapeNested, Shape: node, OmitEmpty: true},
+	}
+	c, err := CompileShape(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := c.Marshal([]interface{}{int64(1), []interface{}{int64(2), nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := mustMarshal(t, OrderedMap{{"v", 1}, {"next", OrderedMap{{"v", 2}}}})
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("expected %x; got %x", expected, actual)
+	}
+
+	self := &Shape{}
+	self.Fields = []ShapeField{{Name: "self", Kind: ShapeNested, Shape: self}}
+	c, err = CompileShape(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cycle := []interface{}{nil}
+	cycle[0] = cycle
+	if _, err := c.Marshal(cycle); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("encoding a cyclic message: got error %v; want ErrMaxDepth", err)
+	}
+}
+
+func TestCompileShapeErrors(t *testing.T) {
+	for _, s := range []*Shape{
+		{Fields: []ShapeField{{Name: "a", KeyAsInt: true}}},
+		{Fields: []ShapeField{{Name: "a"}, {Name: "a"}}},
+		{Fields: []ShapeField{{Name: "a", Kind: ShapeNested}}},
+		{Fields: []ShapeField{{Name: "a", Kind: ShapeKind(100)}}},
+	} {
+		if _, err := CompileShape(s); err == nil {
+			t.Errorf("CompileShape(%+v): expected an error, but err was nil", s)
+		}
+	}
+}
+
+func TestCompiledShapeMarshalErrors(t *testing.T) {
+	c, err := CompileShape(&Shape{Fields: []ShapeField{
+		{Name: "n", Kind: ShapeInt},
+		{Name: "s", Kind: ShapeString},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range [][]interface{}{
+		{int64(1)},
+		{1, "a"},
+		{int64(1), "\xff"},
+	} {
+		if _, err := c.Marshal(values); err == nil {
+			t.Errorf("Marshal(%#v): expected an error, but err was nil", values)
+		}
+	}
+}
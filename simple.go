@@ -0,0 +1,96 @@
+package cbor
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Simple is a simple value (major type 7), such as one assigned a meaning by an application protocol. The
+// values 20 through 23 are false, true, null, and undefined; 24 through 31 are reserved and can't be
+// encoded.
+type Simple uint8
+
+// MarshalCBOR implements Marshaler.
+func (s Simple) MarshalCBOR() ([]byte, error) {
+	if s >= 24 && s < 32 {
+		return nil, fmt.Errorf("cbor: reserved simple value %d", s)
+	}
+	return AppendHeader(nil, MajorSimple, uint64(s)), nil
+}
+
+// SimpleValues is a registry of the meanings that an application gives to simple values: each registered
+// simple value stands for a Go value, such as a constant of some type. An Encoder set to use it with
+// SetSimpleValues writes each registered Go value as its simple value.
+//
+// A SimpleValues must not be modified while Encoders are using it.
+type SimpleValues struct {
+	toSimple   map[interface{}]Simple
+	fromSimple map[Simple]interface{}
+}
+
+// NewSimpleValues returns an empty registry.
+func NewSimpleValues() *SimpleValues {
+	return &SimpleValues{
+		toSimple:   make(map[interface{}]Simple),
+		fromSimple: make(map[Simple]interface{}),
+	}
+}
+
+// Register records that s stands for v. The simple value must be one that has no meaning in CBOR itself
+// (0 through 19 or 32 through 255) and v must be comparable, and neither may be registered already. The
+// match is on both v's type and its value, so registering a constant of a named type doesn't affect plain
+// integers that happen to be equal to it.
+func (sv *SimpleValues) Register(s Simple, v interface{}) error {
+	if s >= 20 && s < 32 {
+		return fmt.Errorf("cbor: simple value %d is not available for applications", s)
+	}
+	if v == nil || !reflect.ValueOf(v).Comparable() {
+		return fmt.Errorf("cbor: cannot register %#v as a simple value: not comparable", v)
+	}
+	if old, ok := sv.fromSimple[s]; ok {
+		return fmt.Errorf("cbor: simple value %d is already registered for %#v", s, old)
+	}
+	if old, ok := sv.toSimple[v]; ok {
+		return fmt.Errorf("cbor: %#v is already registered as simple value %d", v, old)
+	}
+	sv.toSimple[v] = s
+	sv.fromSimple[s] = v
+	return nil
+}
+
+// Simple returns the simple value registered for v.
+func (sv *SimpleValues) Simple(v interface{}) (Simple, bool) {
+	if v == nil || !reflect.ValueOf(v).Comparable() {
+		return 0, false
+	}
+	s, ok := sv.toSimple[v]
+	return s, ok
+}
+
+// Value returns the Go value registered for s.
+func (sv *SimpleValues) Value(s Simple) (interface{}, bool) {
+	v, ok := sv.fromSimple[s]
+	return v, ok
+}
+
+// hasType reports whether any Go value of type t is registered. It's false for a nil registry.
+func (sv *SimpleValues) hasType(t reflect.Type) bool {
+	if sv == nil {
+		return false
+	}
+	for v := range sv.toSimple {
+		if reflect.TypeOf(v) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleValue returns the simple value registered for v, if the Encoder has a registry.
+func (e *encodeState) simpleValue(v reflect.Value) (Simple, bool) {
+	if e.simpleValues == nil || len(e.simpleValues.toSimple) == 0 || !v.CanInterface() || !v.Comparable() {
+		return 0, false
+	}
+	s, ok := e.simpleValues.toSimple[v.Interface()]
+	return s, ok
+}
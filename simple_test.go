@@ -0,0 +1,86 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+type signal int
+
+const (
+	signalUnknown signal = iota
+	signalRedacted
+)
+
+type marker struct{}
+
+func TestSimpleValues(t *testing.T) {
+	sv := NewSimpleValues()
+	for _, r := range []struct {
+		s Simple
+		v interface{}
+	}{
+		{32, signalUnknown},
+		{33, signalRedacted},
+		{255, marker{}},
+		{5, "five"},
+	} {
+		if err := sv.Register(r.s, r.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range []struct {
+		s Simple
+		v interface{}
+	}{
+		{22, 1},              // null
+		{28, 1},              // reserved
+		{32, signalRedacted}, // simple value taken
+		{40, signalUnknown},  // Go value taken
+		{41, []int{1}},       // not comparable
+		{42, nil},            // not comparable
+	} {
+		if err := sv.Register(r.s, r.v); err == nil {
+			t.Errorf("Register(%d, %#v): expected an error", r.s, r.v)
+		}
+	}
+	if v, ok := sv.Value(33); !ok || v != signalRedacted {
+		t.Errorf("Value(33): got %#v, %t", v, ok)
+	}
+	if s, ok := sv.Simple(marker{}); !ok || s != 255 {
+		t.Errorf("Simple(marker{}): got %d, %t", s, ok)
+	}
+
+	v := map[string]interface{}{
+		"a": []interface{}{signalUnknown, signalRedacted, marker{}, "five"},
+		"b": 0, // equal to signalUnknown but a different type
+		"c": []int{1},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetSimpleValues(sv)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	expected := "a3616184f820f821f8ffe561620061638101"
+	if actual := hex.EncodeToString(buf.Bytes()); actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+
+	// Without the registry, the values are encoded as usual.
+	if actual := hex.EncodeToString(mustMarshal(t, []interface{}{signalRedacted, marker{}})); actual != "8201a0" {
+		t.Errorf("without a registry: got 0x%s", actual)
+	}
+}
+
+func TestSimpleMarshal(t *testing.T) {
+	for s, expected := range map[Simple]string{0: "e0", 19: "f3", 20: "f4", 23: "f7", 32: "f820", 255: "f8ff"} {
+		if actual := hex.EncodeToString(mustMarshal(t, s)); actual != expected {
+			t.Errorf("Simple(%d): expected 0x%s; got 0x%s", s, expected, actual)
+		}
+	}
+	if _, err := Marshal(Simple(24)); err == nil {
+		t.Error("expected an error for reserved simple value 24")
+	}
+}
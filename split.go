@@ -0,0 +1,66 @@
+package cbor
+
+import (
+	"io"
+	"iter"
+)
+
+// Items returns an iterator over the top-level items of the CBOR sequence (RFC 8742) in data: items written
+// back to back, as by successive calls to Encoder.Encode. Each item is yielded as a RawMessage sharing data's
+// memory, without being decoded, so that messages can be routed or stored individually. Items are checked
+// for well-formedness as they are reached; if data is malformed, or ends partway through an item, the
+// iterator yields a non-nil error and stops.
+func Items(data []byte) iter.Seq2[RawMessage, error] {
+	return func(yield func(RawMessage, error) bool) {
+		s := &scanner{data: data}
+		for s.off < len(data) {
+			start := s.off
+			if err := s.skip(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(RawMessage(data[start:s.off:s.off]), nil) {
+				return
+			}
+		}
+	}
+}
+
+// ReaderItems is like Items but reads the sequence from r as the iteration goes. The yielded items may be
+// retained: they aren't modified by later reads. If reading from r fails, the iterator yields the error and
+// stops.
+func ReaderItems(r io.Reader) iter.Seq2[RawMessage, error] {
+	return func(yield func(RawMessage, error) bool) {
+		var items []RawMessage
+		p := NewParser(func(item RawMessage) error {
+			items = append(items, item)
+			return nil
+		})
+		// The Parser copies what it's given, so the items grow with the input that actually arrives, not
+		// with the length that a header claims.
+		chunk := make([]byte, 32<<10)
+		for {
+			n, readErr := r.Read(chunk)
+			_, err := p.Write(chunk[:n])
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			items = items[:0]
+			switch {
+			case err != nil:
+				yield(nil, err)
+				return
+			case readErr == io.EOF:
+				if err := p.Close(); err != nil {
+					yield(nil, err)
+				}
+				return
+			case readErr != nil:
+				yield(nil, readErr)
+				return
+			}
+		}
+	}
+}
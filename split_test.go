@@ -0,0 +1,116 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestItems(t *testing.T) {
+	// The byte string is longer than ReaderItems reads at a time.
+	long := "5a00010000" + hex.EncodeToString(make([]byte, 1<<16))
+	expected := []string{"01", "63616263", "82018102", "bf0102ff", long, "f6"}
+	var input []byte
+	for _, s := range expected {
+		input = append(input, mustDecodeHex(t, s)...)
+	}
+
+	collect := func(seq func(func(RawMessage, error) bool)) ([]string, error) {
+		var items []string
+		for raw, err := range seq {
+			if err != nil {
+				return items, err
+			}
+			items = append(items, hex.EncodeToString(raw))
+		}
+		return items, nil
+	}
+	for name, seq := range map[string]func(func(RawMessage, error) bool){
+		"Items":                   Items(input),
+		"ReaderItems":             ReaderItems(bytes.NewReader(input)),
+		"ReaderItems(OneByte)":    ReaderItems(iotest.OneByteReader(bytes.NewReader(input))),
+		"ReaderItems(DataErrEOF)": ReaderItems(iotest.DataErrReader(bytes.NewReader(input))),
+	} {
+		actual, err := collect(seq)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: got %d items, differing from the expected %d", name, len(actual), len(expected))
+		}
+	}
+
+	// Items from a reader stay intact as reading continues.
+	var retained []RawMessage
+	for raw, err := range ReaderItems(iotest.HalfReader(bytes.NewReader(input))) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		retained = append(retained, raw)
+	}
+	for i, raw := range retained {
+		if actual := hex.EncodeToString(raw); actual != expected[i] {
+			t.Errorf("retained item %d changed", i)
+		}
+	}
+}
+
+func TestItemsErrors(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		err   error
+	}{
+		{"0118", &UnexpectedEOFError{}},
+		{"018201", &UnexpectedEOFError{}},
+		{"01ff", &SyntaxError{}},
+		{"015bffffffffffffffff", &UnexpectedEOFError{}},
+	} {
+		data := mustDecodeHex(t, test.input)
+		for name, seq := range map[string]func(func(RawMessage, error) bool){
+			"Items":       Items(data),
+			"ReaderItems": ReaderItems(bytes.NewReader(data)),
+		} {
+			var n int
+			var err error
+			for _, err = range seq {
+				if err != nil {
+					break
+				}
+				n++
+			}
+			if n != 1 || reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+				t.Errorf("%s(%s): expected 1 item, then an error like %T; got %d items, then %v", name, test.input, test.err, n, err)
+			}
+		}
+	}
+
+	readErr := errors.New("read failed")
+	for _, err := range ReaderItems(io.MultiReader(bytes.NewReader([]byte{0x82}), iotest.ErrReader(readErr))) {
+		if err != readErr {
+			t.Errorf("expected the read error; got %v", err)
+		}
+	}
+}
+
+func TestReaderItemsSmallReads(t *testing.T) {
+	// Reading a large array a byte at a time doesn't rescan it after each read.
+	input := append(mustDecodeHex(t, "9a00100000"), make([]byte, 1<<20)...)
+	var n int
+	for raw, err := range ReaderItems(iotest.OneByteReader(bytes.NewReader(input))) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(raw) != len(input) {
+			t.Errorf("got an item of %d bytes; want %d", len(raw), len(input))
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("got %d items; want 1", n)
+	}
+}
@@ -0,0 +1,16 @@
+package cbor
+
+import "database/sql/driver"
+
+// SQL wraps a value of type T so that it's stored in a database as CBOR, in a BLOB or bytea column. It
+// implements driver.Valuer.
+//
+// TODO: Implement sql.Scanner once there's a decoder.
+type SQL[T any] struct {
+	V T
+}
+
+// Value returns the CBOR encoding of s.V.
+func (s SQL[T]) Value() (driver.Value, error) {
+	return Marshal(s.V)
+}
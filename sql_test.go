@@ -0,0 +1,26 @@
+package cbor
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSQLValue(t *testing.T) {
+	type point struct{ X, Y int }
+	var valuer driver.Valuer = SQL[point]{point{1, 2}}
+	v, err := valuer.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		t.Fatalf("got value of type %T; want []byte", v)
+	}
+	if got, want := hex.EncodeToString(b), "a2615801615902"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if !driver.IsValue(v) {
+		t.Errorf("%#v isn't a valid driver.Value", v)
+	}
+}
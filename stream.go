@@ -0,0 +1,352 @@
+package cbor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"time"
+)
+
+// An Encoder writes CBOR values to an output stream.
+type Encoder struct {
+	encOpts
+	w           io.Writer
+	buf         *bufio.Writer // nil unless buffering is enabled
+	written     int64
+	lastWritten int
+	sizeHint    int
+	statsHook   func(EncodeStats)
+	// If compressor is set, it's the same as w, and it's flushed after each item (or, when buffering is
+	// enabled, by Flush).
+	compressor Compressor
+
+	// If inner is set, this Encoder was passed to a StreamMarshaler and writes into the encoding of the
+	// enclosing value rather than to w.
+	inner *encodeState
+}
+
+// NewEncoder returns a new encoder that writes to w. By default each call to Encode writes directly to w;
+// see SetBufferSize.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// A Compressor is a compressing writer, such as a *flate.Writer, *gzip.Writer, or *zlib.Writer, or a
+// zstd encoder; it's an interface so that this package doesn't depend on any of them.
+type Compressor interface {
+	io.Writer
+	// Flush writes out everything written so far, so that the reader can decompress it without waiting for
+	// more.
+	Flush() error
+}
+
+// NewCompressedEncoder returns an Encoder that writes through c, flushing it after each call to Encode (or
+// the other methods), so that every message can be decompressed as soon as it arrives. If buffering is
+// enabled with SetBufferSize, c is flushed by Flush instead, which lets a batch of messages be compressed
+// together. The caller is responsible for closing c when the stream is done.
+func NewCompressedEncoder(c Compressor) *Encoder {
+	return &Encoder{w: c, compressor: c}
+}
+
+// Encode writes the CBOR encoding of v to the stream.
+//
+// See the documentation for Marshal for details about the conversion of Go values to CBOR.
+func (enc *Encoder) Encode(v interface{}) error {
+	if enc.statsHook == nil || enc.inner != nil {
+		return enc.encode(func(e *encodeState) error { return e.marshal(v) })
+	}
+	start := time.Now()
+	var maxDepth int
+	err := enc.encode(func(e *encodeState) error {
+		err := e.marshal(v)
+		maxDepth = e.maxDepth
+		return err
+	})
+	enc.statsHook(EncodeStats{
+		Bytes:    enc.lastWritten,
+		MaxDepth: maxDepth,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	return err
+}
+
+// EncodeArrayHeader writes the start of an array of n elements, which must be followed by n calls to Encode
+// (or other items) to write the elements. This is mainly useful in the MarshalCBORTo method of a
+// StreamMarshaler.
+func (enc *Encoder) EncodeArrayHeader(n int) error {
+	return enc.encode(func(e *encodeState) error {
+		e.writeMajorWithNumber(typeList, uint64(n))
+		return nil
+	})
+}
+
+// EncodeMapHeader writes the start of a map of n entries, which must be followed by the n keys and values.
+func (enc *Encoder) EncodeMapHeader(n int) error {
+	return enc.encode(func(e *encodeState) error {
+		e.writeMajorWithNumber(typeMap, uint64(n))
+		return nil
+	})
+}
+
+// EncodeChannel writes the values received from ch to enc as an indefinite-length array, finishing it when
+// ch is closed, so that a producer goroutine can stream its results directly onto the wire. Each value is
+// written as it's received (subject to the Encoder's buffering; see SetBufferSize). If writing a value
+// fails, EncodeChannel returns the error without receiving any more values, leaving the array unfinished.
+func EncodeChannel[T any](enc *Encoder, ch <-chan T) error {
+	if err := enc.writeByte(makeIDByte(typeList, indefiniteLength)); err != nil {
+		return err
+	}
+	for v := range ch {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return enc.writeByte(makeIDByte(typeMajor7, typeBreak))
+}
+
+// ByteStringWriter starts an indefinite-length byte string and returns a writer for its contents, so that
+// code built around io.Writer (such as a gzip.Writer or tar.Writer) can stream straight into an item, such as
+// the value of a map entry. Each Write becomes a chunk of the string (or several, if the chunk size is set
+// with SetStringChunkSize), and Close ends the string; it doesn't close the Encoder's writer. Nothing else may
+// be written to the Encoder until the writer is closed.
+func (enc *Encoder) ByteStringWriter() io.WriteCloser {
+	return &byteStringWriter{enc: enc}
+}
+
+type byteStringWriter struct {
+	enc     *Encoder
+	started bool // whether the start of the string has been written
+	err     error
+}
+
+var errByteStringWriterClosed = errors.New("cbor: write to closed ByteStringWriter")
+
+func (w *byteStringWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.err = w.enc.encode(func(e *encodeState) error {
+		w.start(e)
+		size := w.enc.stringChunkSize
+		if size <= 0 {
+			size = len(p)
+		}
+		for b := p; len(b) > 0; {
+			n := min(size, len(b))
+			e.writeMajorWithNumber(typeByteString, uint64(n))
+			e.Write(b[:n])
+			b = b[n:]
+		}
+		return nil
+	})
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+// Close writes the end of the string.
+func (w *byteStringWriter) Close() error {
+	if w.err != nil {
+		if w.err == errByteStringWriterClosed {
+			return nil
+		}
+		return w.err
+	}
+	w.err = w.enc.encode(func(e *encodeState) error {
+		w.start(e)
+		e.writeSimple(typeBreak)
+		return nil
+	})
+	if w.err != nil {
+		return w.err
+	}
+	w.err = errByteStringWriterClosed
+	return nil
+}
+
+func (w *byteStringWriter) start(e *encodeState) {
+	if !w.started {
+		e.WriteByte(makeIDByte(typeByteString, indefiniteLength))
+		w.started = true
+	}
+}
+
+func (enc *Encoder) writeByte(b byte) error {
+	return enc.encode(func(e *encodeState) error {
+		return e.WriteByte(b)
+	})
+}
+
+// encode calls fn to encode an item and writes the result to the stream.
+func (enc *Encoder) encode(fn func(e *encodeState) error) error {
+	if enc.inner != nil {
+		return fn(enc.inner)
+	}
+	e := &encodeState{encOpts: enc.encOpts}
+	if enc.sizeHint > 0 {
+		e.Grow(enc.sizeHint)
+	}
+	enc.lastWritten = 0
+	if err := fn(e); err != nil {
+		return err
+	}
+	return enc.write(e.Bytes())
+}
+
+// write writes the already-encoded b to the stream.
+func (enc *Encoder) write(b []byte) error {
+	if enc.inner != nil {
+		enc.inner.Write(b)
+		return nil
+	}
+	var w io.Writer = enc.w
+	if enc.buf != nil {
+		w = enc.buf
+	}
+	n, err := w.Write(b)
+	enc.lastWritten = n
+	enc.written += int64(n)
+	if err == nil && enc.compressor != nil && enc.buf == nil {
+		err = enc.compressor.Flush()
+	}
+	return err
+}
+
+// SetBufferSize sets the size of the Encoder's internal buffer. If size is positive, encoded values are
+// collected in a buffer of (at least) that size and only written to the underlying writer when the buffer
+// fills up or when Flush is called, which batches small values into fewer writes. A size of 0 (the default)
+// turns off buffering. Any data already buffered is flushed first.
+func (enc *Encoder) SetBufferSize(size int) error {
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	if size <= 0 {
+		enc.buf = nil
+		return nil
+	}
+	enc.buf = bufio.NewWriterSize(enc.w, size)
+	return nil
+}
+
+// SetStringChunkSize makes the Encoder split text and byte strings longer than size bytes into chunks of at
+// most size bytes, encoded as indefinite-length strings. Chunks of text strings end on UTF-8 character
+// boundaries, so they may be somewhat shorter than size. A size of 0 (the default) means that all strings
+// are encoded with a definite length.
+func (enc *Encoder) SetStringChunkSize(size int) {
+	enc.stringChunkSize = size
+}
+
+// SetByteStringFieldNames sets whether the Encoder writes the map keys of struct fields as byte strings
+// (containing the UTF-8 field names) rather than text strings, for peers that expect byte-string keys. Fields
+// tagged with keyasint still get integer keys.
+func (enc *Encoder) SetByteStringFieldNames(on bool) {
+	enc.byteStringFieldNames = on
+}
+
+// SetSizeHint tells the Encoder that encoded values are usually about size bytes long, so that Encode can
+// allocate a large enough buffer for each value up front rather than growing it repeatedly. A size of 0 (the
+// default) means there is no hint.
+func (enc *Encoder) SetSizeHint(size int) {
+	enc.sizeHint = size
+}
+
+// SetMapKeyOrder sets the order in which the Encoder writes the entries of Go maps. The less function
+// reports whether the map key encoded as a should come before the key encoded as b; LengthFirstKeyOrder (the
+// default) and BytewiseKeyOrder implement the orders of the CBOR RFCs, and other functions can match the
+// canonical forms of other implementations. A nil less restores the default.
+func (enc *Encoder) SetMapKeyOrder(less func(a, b []byte) bool) {
+	enc.mapKeyOrder = less
+}
+
+// SetTypedArrays sets whether the Encoder writes slices and arrays of integers and floats as typed arrays
+// (RFC 8746, section 2): a tag identifying the element type followed by a byte string holding the elements in
+// big-endian order. This is much smaller and faster than writing each element separately. Elements of type
+// int and uint are written as 64-bit integers; byte slices are still written as plain byte strings. Elements
+// that wouldn't be written as numbers on their own (those with their own encoding methods, Durations,
+// values registered with SetSimpleValues, and enums under SetStringerEnums) are written one by one in an ordinary array.
+func (enc *Encoder) SetTypedArrays(on bool) {
+	enc.typedArrays = on
+}
+
+// SetTimeFormat sets how the Encoder writes time.Time values. The default is TimeRFC3339.
+func (enc *Encoder) SetTimeFormat(format TimeFormat) {
+	enc.timeFormat = format
+}
+
+// SetDateFormat sets how the Encoder writes Date values. The default is DateString.
+func (enc *Encoder) SetDateFormat(format DateFormat) {
+	enc.dateFormat = format
+}
+
+// SetJSONNumberFormat sets how the Encoder writes json.Number values, such as those in a tree decoded by
+// encoding/json with UseNumber. The default is JSONNumberAuto.
+func (enc *Encoder) SetJSONNumberFormat(format JSONNumberFormat) {
+	enc.jsonNumberFormat = format
+}
+
+// SetLegacyIPTags sets whether the Encoder writes netip.Addr and netip.Prefix values with the older tags 260
+// and 261, for peers that predate RFC 9164, rather than with tags 52 and 54 (the default).
+func (enc *Encoder) SetLegacyIPTags(on bool) {
+	enc.legacyIPTags = on
+}
+
+// SetSimpleValues sets a registry of Go values that the Encoder writes as simple values, ahead of any other
+// way of encoding them. A nil registry, the default, turns this off.
+func (enc *Encoder) SetSimpleValues(sv *SimpleValues) {
+	enc.simpleValues = sv
+}
+
+// SetStringerEnums sets whether the Encoder writes values of integer types that implement fmt.Stringer (such
+// as enums generated by the stringer tool) as text strings holding their String form rather than as
+// integers, for consumers that expect readable values. Types that implement Marshaler or StreamMarshaler
+// are still encoded with their own methods, and integer types whose String form is a quantity rather than a
+// name (time.Duration and fs.FileMode) are still written as integers. Slices and arrays of enums are written
+// as arrays of text strings even with SetTypedArrays.
+func (enc *Encoder) SetStringerEnums(on bool) {
+	enc.stringerEnums = on
+}
+
+// EncodeStats describes a call to Encode, for the hook set with SetStatsHook.
+type EncodeStats struct {
+	Bytes    int           // The number of bytes written.
+	MaxDepth int           // The deepest nesting of arrays and maps in the value.
+	Duration time.Duration // The time taken to encode and write the value.
+	Err      error         // The error returned by Encode, if any.
+}
+
+// SetStatsHook sets a function that the Encoder calls after each call to Encode with statistics about it, so
+// that services can export metrics about their CBOR traffic without wrapping every call site. A nil hook (the
+// default) turns this off.
+func (enc *Encoder) SetStatsHook(hook func(EncodeStats)) {
+	enc.statsHook = hook
+}
+
+// Flush writes any buffered data to the underlying writer (and flushes the Compressor of an Encoder made by
+// NewCompressedEncoder). Otherwise, it does nothing.
+func (enc *Encoder) Flush() error {
+	if enc.buf != nil {
+		if err := enc.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	if enc.compressor != nil {
+		return enc.compressor.Flush()
+	}
+	return nil
+}
+
+// Written returns the total number of bytes written to the stream by Encode, including any bytes that are
+// still buffered.
+func (enc *Encoder) Written() int64 {
+	return enc.written
+}
+
+// LastWritten returns the number of bytes written to the stream by the most recent call to Encode.
+func (enc *Encoder) LastWritten() int {
+	return enc.lastWritten
+}
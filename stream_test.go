@@ -0,0 +1,524 @@
+package cbor
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingWriter records the number of calls to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(b)
+}
+
+func TestEncoder(t *testing.T) {
+	var w countingWriter
+	enc := NewEncoder(&w)
+	for _, v := range []interface{}{1, "a", []int{1, 2, 3}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if actual, expected := hex.EncodeToString(w.Bytes()), "01616183010203"; actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+	if w.writes != 3 {
+		t.Errorf("expected 3 writes; got %d", w.writes)
+	}
+	if enc.Written() != 7 || enc.LastWritten() != 4 {
+		t.Errorf("expected Written() = 7, LastWritten() = 4; got %d, %d", enc.Written(), enc.LastWritten())
+	}
+}
+
+func TestEncoderBuffered(t *testing.T) {
+	var w countingWriter
+	enc := NewEncoder(&w)
+	if err := enc.SetBufferSize(64); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := enc.Encode(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.writes != 0 {
+		t.Errorf("expected no writes before Flush; got %d", w.writes)
+	}
+	if enc.Written() != 10 {
+		t.Errorf("expected Written() = 10; got %d", enc.Written())
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 || w.Len() != 10 {
+		t.Errorf("expected 10 bytes in 1 write after Flush; got %d bytes in %d writes", w.Len(), w.writes)
+	}
+}
+
+func TestEncoderStringChunkSize(t *testing.T) {
+	for _, test := range []struct {
+		size     int
+		input    interface{}
+		expected string
+	}{
+		{0, "abcde", "656162636465"},
+		{5, "abcde", "656162636465"},
+		{2, "abcde", "7f6261626263646165ff"},
+		{2, []byte{1, 2, 3}, "5f4201024103ff"},
+		{2, "a\u00fcb", "7f616162c3bc6162ff"},
+		{1, "\u00fc", "7f62c3bcff"},
+		{3, struct{ S string }{"abcd"}, "a161537f636162636164ff"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetStringChunkSize(test.size)
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("chunk size %d, input %#v: expected 0x%s; got 0x%s", test.size, test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestEncoderByteStringFieldNames(t *testing.T) {
+	input := struct {
+		A int
+		B struct{ C int } `cbor:"b"`
+		D int             `cbor:"4,keyasint"`
+		M map[string]int
+	}{1, struct{ C int }{2}, 3, map[string]int{"x": 5}}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetByteStringFieldNames(true)
+	if err := enc.Encode(input); err != nil {
+		t.Fatal(err)
+	}
+	// Map keys other than field names are unaffected.
+	expected := "a44141014162a14143020403414da1617805"
+	if actual := hex.EncodeToString(buf.Bytes()); actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+}
+
+func TestEncoderSizeHint(t *testing.T) {
+	v := []string{strings.Repeat("a", 4000), strings.Repeat("b", 4000)}
+	allocs := func(hint int) float64 {
+		enc := NewEncoder(io.Discard)
+		enc.SetSizeHint(hint)
+		return testing.AllocsPerRun(10, func() {
+			if err := enc.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if without, with := allocs(0), allocs(8100); with >= without {
+		t.Errorf("got %v allocations per Encode with a size hint and %v without one", with, without)
+	}
+}
+
+func TestEncoderMapKeyOrder(t *testing.T) {
+	input := map[interface{}]int{10: 1, 100: 2, -1: 3, "z": 4, "aa": 5, false: 6}
+	for _, test := range []struct {
+		less     func(a, b []byte) bool
+		expected string
+	}{
+		{nil, "a60a012003f406186402617a0462616105"},
+		{LengthFirstKeyOrder, "a60a012003f406186402617a0462616105"},
+		{BytewiseKeyOrder, "a60a011864022003617a0462616105f406"},
+		{func(a, b []byte) bool { return BytewiseKeyOrder(b, a) }, "a6f40662616105617a0420031864020a01"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetMapKeyOrder(test.less)
+		if err := enc.Encode(input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("expected 0x%s; got 0x%s", test.expected, actual)
+		}
+	}
+}
+
+func TestEncoderTypedArrays(t *testing.T) {
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		{[]uint16{1, 0xabcd}, "d841440001abcd"},
+		{[]int32{-1, 2}, "d84a48ffffffff00000002"},
+		{[]float32{1.5}, "d851443fc00000"},
+		{[]float64{-2}, "d85248c000000000000000"},
+		{[]int{1}, "d84b480000000000000001"},
+		{[2]int8{-1, 1}, "d84842ff01"},
+		{[2]uint8{1, 2}, "d840420102"},
+		{[]uint8{1, 2}, "420102"},
+		{[]int64{}, "d84b40"},
+		{[]float64(nil), "f6"},
+		{[]string{"a"}, "816161"},
+		{[]rawInt{1}, "8163313233"},
+		{struct{ V []uint32 }{[]uint32{7}}, "a16156d8424400000007"},
+		{[2]Duration{1, 2}, "82d903eaa201002801d903eaa201002802"},
+		{[]streamedInt{5}, "816135"},
+		{[]signal{signalRedacted, 7}, "82f82107"},
+	} {
+		sv := NewSimpleValues()
+		if err := sv.Register(33, signalRedacted); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetTypedArrays(true)
+		enc.SetSimpleValues(sv)
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("%#v: expected 0x%s; got 0x%s", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestEncoderStatsHook(t *testing.T) {
+	var stats []EncodeStats
+	enc := NewEncoder(io.Discard)
+	enc.SetStatsHook(func(s EncodeStats) { stats = append(stats, s) })
+	for _, v := range []interface{}{
+		1,
+		[]interface{}{1, map[string][]int{"a": {1}}},
+		OrderedMap{{"a", []interface{}{[]int{}}}},
+		make(chan int),
+	} {
+		enc.Encode(v)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("hook called %d times; want 4", len(stats))
+	}
+	for i, expected := range []EncodeStats{
+		{Bytes: 1, MaxDepth: 0},
+		{Bytes: 7, MaxDepth: 3},
+		{Bytes: 5, MaxDepth: 3},
+		{Bytes: 0, MaxDepth: 0},
+	} {
+		s := stats[i]
+		if s.Bytes != expected.Bytes || s.MaxDepth != expected.MaxDepth {
+			t.Errorf("call %d: got Bytes %d, MaxDepth %d; want %d, %d",
+				i, s.Bytes, s.MaxDepth, expected.Bytes, expected.MaxDepth)
+		}
+		if (s.Err != nil) != (i == 3) {
+			t.Errorf("call %d: got Err %v", i, s.Err)
+		}
+	}
+}
+
+// rawInt is a number with its own encoding.
+type rawInt uint16
+
+func (rawInt) MarshalCBOR() ([]byte, error) { return []byte{0x63, '1', '2', '3'}, nil }
+
+// streamedInt is a number encoded by a StreamMarshaler.
+type streamedInt int32
+
+func (n streamedInt) MarshalCBORTo(enc *Encoder) error { return enc.Encode(fmt.Sprint(int32(n))) }
+
+var errNegativeX = errors.New("negative X")
+
+// point is a StreamMarshaler encoded as an array.
+type point struct{ X, Y int }
+
+func (p *point) MarshalCBORTo(enc *Encoder) error {
+	if p.X < 0 {
+		return errNegativeX
+	}
+	if err := enc.EncodeArrayHeader(2); err != nil {
+		return err
+	}
+	if err := enc.Encode(p.X); err != nil {
+		return err
+	}
+	return enc.Encode(p.Y)
+}
+
+func TestStreamMarshaler(t *testing.T) {
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		{&point{1, 2}, "820102"},
+		{[]point{{1, 2}, {3, 4}}, "82820102820304"},
+		{(*point)(nil), "f6"},
+		{map[string]*point{"a": {5, 6}}, "a16161820506"},
+		{&struct{ P point }{point{1, 2}}, "a16150820102"},
+	} {
+		b, err := Marshal(test.input)
+		if err != nil {
+			t.Errorf("%#v: %s", test.input, err)
+			continue
+		}
+		if actual := hex.EncodeToString(b); actual != test.expected {
+			t.Errorf("%#v: expected 0x%s; got 0x%s", test.input, test.expected, actual)
+		}
+		if n, err := EncodedSize(test.input); err != nil || n != len(b) {
+			t.Errorf("EncodedSize(%#v) = %d, %v; want %d", test.input, n, err, len(b))
+		}
+	}
+
+	// Settings of the outer Encoder apply to values written by MarshalCBORTo.
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetByteStringFieldNames(true)
+	if err := enc.Encode([]interface{}{&point{1, 2}, &struct{ A *point }{&point{3, 4}}}); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := hex.EncodeToString(buf.Bytes()), "82820102a14141820304"; actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+	if enc.Written() != int64(buf.Len()) {
+		t.Errorf("Written() = %d; want %d", enc.Written(), buf.Len())
+	}
+
+	_, err := Marshal(&point{-1, 0})
+	if _, ok := err.(*MarshalerError); !ok {
+		t.Errorf("got error %v; want a *MarshalerError", err)
+	}
+}
+
+func TestEncodeChannel(t *testing.T) {
+	ch := make(chan interface{})
+	go func() {
+		for _, v := range []interface{}{1, "a", []int{2}} {
+			ch <- v
+		}
+		close(ch)
+	}()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := EncodeChannel(enc, ch); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := hex.EncodeToString(buf.Bytes()), "9f0161618102ff"; actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+	if enc.Written() != 7 {
+		t.Errorf("Written() = %d; want 7", enc.Written())
+	}
+
+	bad := make(chan func(), 2)
+	bad <- func() {}
+	bad <- func() {}
+	if err := EncodeChannel(NewEncoder(io.Discard), bad); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("got error %v; want one wrapping %q", err, ErrUnsupportedType)
+	}
+	if len(bad) != 1 {
+		t.Errorf("%d values left in the channel after an error; want 1", len(bad))
+	}
+}
+
+func TestByteStringWriter(t *testing.T) {
+	for _, test := range []struct {
+		chunkSize int
+		writes    []string
+		expected  string
+	}{
+		{0, nil, "5fff"},
+		{0, []string{"abc", "", "de"}, "5f43616263426465ff"},
+		{2, []string{"abc", "de"}, "5f4261624163426465ff"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if err := enc.EncodeMapHeader(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode("data"); err != nil {
+			t.Fatal(err)
+		}
+		enc.SetStringChunkSize(test.chunkSize)
+		w := enc.ByteStringWriter()
+		for _, s := range test.writes {
+			if _, err := io.WriteString(w, s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		expected := "a16464617461" + test.expected
+		if actual := hex.EncodeToString(buf.Bytes()); actual != expected {
+			t.Errorf("chunk size %d, writes %q: expected 0x%s; got 0x%s", test.chunkSize, test.writes, expected, actual)
+		}
+		if _, err := w.Write([]byte("x")); err == nil {
+			t.Error("expected an error writing after Close")
+		}
+	}
+}
+
+func TestEncodeParallel(t *testing.T) {
+	var values []interface{}
+	var expected bytes.Buffer
+	var first500 int
+	for i := range 1000 {
+		if i == 500 {
+			first500 = expected.Len()
+		}
+		v := []interface{}{i, strings.Repeat("x", i%50), point{i, -i}}
+		values = append(values, v)
+		expected.Write(mustMarshal(t, v))
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := EncodeParallel(enc, slices.Values(values), 4); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+		t.Error("output differs from encoding the values one at a time")
+	}
+	if enc.Written() != int64(expected.Len()) {
+		t.Errorf("Written() = %d; want %d", enc.Written(), expected.Len())
+	}
+
+	buf.Reset()
+	values[500] = &point{-1, 0}
+	if err := EncodeParallel(NewEncoder(&buf), slices.Values(values), 0); !errors.Is(err, errNegativeX) {
+		t.Errorf("got error %v; want one wrapping %q", err, errNegativeX)
+	}
+	if !bytes.Equal(buf.Bytes(), expected.Bytes()[:first500]) {
+		t.Errorf("wrote %d bytes before the error; want the %d bytes of the first 500 values", buf.Len(), first500)
+	}
+}
+
+// flushCounter is a Compressor that counts calls to Flush.
+type flushCounter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (f *flushCounter) Flush() error {
+	f.flushes++
+	return nil
+}
+
+func TestCompressedEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := NewCompressedEncoder(fw)
+	if err := enc.Encode([]int{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	// The message is readable without closing the stream.
+	msg := make([]byte, 4)
+	if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(buf.Bytes())), msg); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := hex.EncodeToString(msg), "83010203"; actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+
+	var fc flushCounter
+	enc = NewCompressedEncoder(&fc)
+	enc.Encode(1)
+	enc.Encode(2)
+	if fc.flushes != 2 {
+		t.Errorf("got %d flushes after 2 messages; want 2", fc.flushes)
+	}
+	enc.SetBufferSize(100)
+	fc.flushes = 0
+	enc.Encode(3)
+	enc.Encode(4)
+	if fc.flushes != 0 || fc.Len() != 2 {
+		t.Errorf("got %d flushes and %d bytes before Flush; want 0 and 2", fc.flushes, fc.Len())
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if fc.flushes != 1 || fc.Len() != 4 {
+		t.Errorf("got %d flushes and %d bytes after Flush; want 1 and 4", fc.flushes, fc.Len())
+	}
+}
+
+// color is an enum with a String method, as generated by the stringer tool.
+type color int
+
+func (c color) String() string { return [...]string{"red", "green", "blue"}[c] }
+
+func TestEncoderStringerEnums(t *testing.T) {
+	input := struct {
+		C  color
+		Cs []color
+		N  int
+		R  rawInt
+	}{2, []color{0, 1}, 3, 4}
+	for _, test := range []struct {
+		on       bool
+		expected string
+	}{
+		{false, "a4614302624373820001614e03615263313233"},
+		{true, "a4614364626c7565624373826372656465677265656e614e03615263313233"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetStringerEnums(test.on)
+		if err := enc.Encode(input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("SetStringerEnums(%t): expected 0x%s; got 0x%s", test.on, test.expected, actual)
+		}
+	}
+}
+
+func TestEncoderStringerEnumsExclusions(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetStringerEnums(true)
+	enc.SetTypedArrays(true)
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		// Quantities are still numbers.
+		{time.Hour, "1b0000034630b8a000"},
+		{fs.FileMode(0644), "1901a4"},
+		// Enums in arrays are names, not typed array elements.
+		{[2]color{0, 2}, "826372656464626c7565"},
+		{[]time.Duration{1}, "d84b480000000000000001"},
+	} {
+		buf.Reset()
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("%#v: expected 0x%s; got 0x%s", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestEncoderMapKeySettings(t *testing.T) {
+	// The Encoder's settings apply to map keys, except that keys are never split into chunks.
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetStringerEnums(true)
+	enc.SetStringChunkSize(2)
+	if err := enc.Encode(map[color]string{2: "xyz"}); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := hex.EncodeToString(buf.Bytes()), "a164626c75657f627879617aff"; actual != expected {
+		t.Errorf("expected 0x%s; got 0x%s", expected, actual)
+	}
+}
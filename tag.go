@@ -34,3 +34,13 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// Value returns the value of an option of the form name=value, such as alias=old_name.
+func (o tagOptions) Value(optionName string) (string, bool) {
+	for _, opt := range strings.Split(string(o), ",") {
+		if value, ok := strings.CutPrefix(opt, optionName+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
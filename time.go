@@ -0,0 +1,127 @@
+package cbor
+
+import (
+	"reflect"
+	"time"
+)
+
+// A TimeFormat says how time.Time values are encoded.
+type TimeFormat int
+
+const (
+	// TimeRFC3339 encodes times as tag 0 with an RFC 3339 string (with as many fractional digits as
+	// needed, and the time's own zone offset). This is the default.
+	TimeRFC3339 TimeFormat = iota
+	// TimeUnix encodes times as tag 1 with integer seconds since the Unix epoch, dropping fractions of a
+	// second.
+	TimeUnix
+	// TimeUnixFloat encodes times as tag 1 with floating-point seconds since the Unix epoch. Times far from
+	// the epoch lose sub-second precision.
+	TimeUnixFloat
+	// TimeUnixMilli, TimeUnixMicro, and TimeUnixNano encode times as extended times (RFC 9581, tag 1001):
+	// a map holding the integer seconds since the Unix epoch (key 1) and the fraction of a second as an
+	// integer number of milliseconds (key -3), microseconds (key -6), or nanoseconds (key -9).
+	TimeUnixMilli
+	TimeUnixMicro
+	TimeUnixNano
+)
+
+// A Timescale is the time scale of an ExtendedTime.
+type Timescale int
+
+const (
+	TimescaleUTC Timescale = 0 // POSIX time, which ignores leap seconds
+	TimescaleTAI Timescale = 1 // International Atomic Time, counted from 1970-01-01T00:00:00 TAI
+)
+
+// An ExtendedTime is a time encoded as an RFC 9581 extended time (tag 1001) with nanosecond precision: the
+// integer seconds since the epoch (key 1), the nanoseconds (key -9, left out when zero), and, for times
+// that aren't UTC, the time scale (key -1). A TAI time's Time holds the reading of a TAI clock, as if it
+// were UTC.
+type ExtendedTime struct {
+	Time      time.Time
+	Timescale Timescale
+}
+
+// A Duration is a time.Duration that is encoded as an RFC 9581 duration (tag 1002): a map holding the
+// integer seconds (key 1) and the nanoseconds (key -9, left out when zero). Plain time.Duration values are
+// encoded as integers, like any other int64.
+type Duration time.Duration
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	extendedTimeType = reflect.TypeOf(ExtendedTime{})
+	durationType     = reflect.TypeOf(Duration(0))
+)
+
+func (e *encodeState) writeTime(t time.Time) {
+	switch e.timeFormat {
+	case TimeUnix:
+		e.writeMajorWithNumber(typeTag, tagEpochTime)
+		e.writeInt(t.Unix())
+	case TimeUnixFloat:
+		e.writeMajorWithNumber(typeTag, tagEpochTime)
+		e.reflectValue(reflect.ValueOf(float64(t.Unix()) + float64(t.Nanosecond())/1e9))
+	case TimeUnixMilli, TimeUnixMicro, TimeUnixNano:
+		var key int64
+		var frac int
+		switch e.timeFormat {
+		case TimeUnixMilli:
+			key, frac = -3, t.Nanosecond()/1e6
+		case TimeUnixMicro:
+			key, frac = -6, t.Nanosecond()/1e3
+		default:
+			key, frac = -9, t.Nanosecond()
+		}
+		e.writeMajorWithNumber(typeTag, tagExtendedTime)
+		e.writeMajorWithNumber(typeMap, 2)
+		e.writeInt(1)
+		e.writeInt(t.Unix())
+		e.writeInt(key)
+		e.writeInt(int64(frac))
+	default:
+		s := t.Format(time.RFC3339Nano)
+		e.writeMajorWithNumber(typeTag, tagDateTime)
+		e.writeMajorWithNumber(typeTextString, uint64(len(s)))
+		e.WriteString(s)
+	}
+}
+
+func (e *encodeState) writeExtendedTime(t ExtendedTime) {
+	e.writeMajorWithNumber(typeTag, tagExtendedTime)
+	n := 1
+	if t.Time.Nanosecond() != 0 {
+		n++
+	}
+	if t.Timescale != TimescaleUTC {
+		n++
+	}
+	e.writeMajorWithNumber(typeMap, uint64(n))
+	e.writeInt(1)
+	e.writeInt(t.Time.Unix())
+	if t.Timescale != TimescaleUTC {
+		e.writeInt(-1)
+		e.writeInt(int64(t.Timescale))
+	}
+	if ns := t.Time.Nanosecond(); ns != 0 {
+		e.writeInt(-9)
+		e.writeInt(int64(ns))
+	}
+}
+
+func (e *encodeState) writeDuration(d Duration) {
+	// Both parts have the sign of d, as with time.Unix.
+	sec, ns := int64(d)/1e9, int64(d)%1e9
+	e.writeMajorWithNumber(typeTag, tagDuration)
+	if ns == 0 {
+		e.writeMajorWithNumber(typeMap, 1)
+	} else {
+		e.writeMajorWithNumber(typeMap, 2)
+	}
+	e.writeInt(1)
+	e.writeInt(sec)
+	if ns != 0 {
+		e.writeInt(-9)
+		e.writeInt(ns)
+	}
+}
@@ -0,0 +1,85 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	tm := time.Date(2013, 3, 21, 20, 4, 0, 500_123_456, time.UTC)
+	for _, test := range []struct {
+		format   TimeFormat
+		input    interface{}
+		expected string
+	}{
+		{TimeRFC3339, tm.Truncate(time.Second), "c074323031332d30332d32315432303a30343a30305a"},
+		{TimeRFC3339, tm.In(time.FixedZone("", -7*3600)), "c07823323031332d30332d32315431333a30343a30302e3530303132333435362d30373a3030"},
+		{TimeUnix, tm, "c11a514b67b0"},
+		{TimeUnix, time.Unix(-1, 0), "c120"},
+		{TimeUnixFloat, tm.Truncate(time.Millisecond * 500), "c1fb41d452d9ec200000"},
+		{TimeUnixFloat, tm.Truncate(time.Second), "c1fb41d452d9ec000000"},
+		{TimeUnixMilli, tm, "d903e9a2011a514b67b0221901f4"},
+		{TimeUnixMicro, tm, "d903e9a2011a514b67b0251a0007a19b"},
+		{TimeUnixNano, tm, "d903e9a2011a514b67b0281a1dcf4740"},
+		{TimeUnix, struct{ T *time.Time }{&tm}, "a16154c11a514b67b0"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetTimeFormat(test.format)
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("format %d, %v: expected 0x%s; got 0x%s", test.format, test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	for _, test := range []struct {
+		format   DateFormat
+		input    interface{}
+		expected string
+	}{
+		{DateString, Date{2013, time.March, 21}, "d903ec6a323031332d30332d3231"},
+		{DateString, Date{2013, time.March, 32}, "d903ec6a323031332d30342d3031"},
+		{DateDays, Date{2013, time.March, 21}, "d864193da9"},
+		{DateDays, Date{1969, time.December, 31}, "d86420"},
+		{DateDays, DateOf(time.Date(2013, 3, 21, 23, 0, 0, 0, time.FixedZone("", -7*3600))), "d864193da9"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetDateFormat(test.format)
+		if err := enc.Encode(test.input); err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(buf.Bytes()); actual != test.expected {
+			t.Errorf("format %d, %v: expected 0x%s; got 0x%s", test.format, test.input, test.expected, actual)
+		}
+	}
+	if _, err := Marshal(Date{10000, time.January, 1}); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("expected ErrUnsupportedValue for year 10000; got %v", err)
+	}
+}
+
+func TestExtendedTime(t *testing.T) {
+	tm := time.Date(2013, 3, 21, 20, 4, 0, 500_123_456, time.UTC)
+	for _, test := range []struct {
+		input    interface{}
+		expected string
+	}{
+		{ExtendedTime{Time: tm.Truncate(time.Second)}, "d903e9a1011a514b67b0"},
+		{ExtendedTime{Time: tm}, "d903e9a2011a514b67b0281a1dcf4740"},
+		{ExtendedTime{Time: tm, Timescale: TimescaleTAI}, "d903e9a3011a514b67b02001281a1dcf4740"},
+		{Duration(90 * time.Second), "d903eaa101185a"},
+		{Duration(-1500 * time.Millisecond), "d903eaa20120283a1dcd64ff"},
+		{struct{ D time.Duration }{time.Second}, "a161441a3b9aca00"},
+	} {
+		if actual := hex.EncodeToString(mustMarshal(t, test.input)); actual != test.expected {
+			t.Errorf("%v: expected 0x%s; got 0x%s", test.input, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package cbor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A TransformFunc computes the replacement for an item reached by a Transformer. Returning a nil RawMessage
+// removes the item: an array element is dropped, as is the map entry holding a removed value; a removed
+// top-level item produces no output.
+type TransformFunc func(path Path, item RawMessage) (RawMessage, error)
+
+// A Transformer rewrites encoded documents, applying functions to the items found at particular paths or
+// with particular tags: for example, to rename keys, convert units, or strip fields when proxying between
+// versions of a schema. Items are rewritten in their encoded form, without decoding the document into Go
+// values.
+//
+// The zero value is a Transformer that leaves everything unchanged; rules are added with HandlePath,
+// HandleTag, and RenameKey.
+type Transformer struct {
+	rules []transformRule
+}
+
+type transformRule struct {
+	pattern []pathPattern // for path rules
+	tag     uint64        // for tag rules (with pattern nil)
+	isTag   bool
+	fn      TransformFunc
+	key     RawMessage // for renames (with fn nil)
+}
+
+// HandlePath arranges for fn to be called for each item whose path matches pattern. A pattern is written like
+// the result of Path.String: a sequence of steps such as ["name"] (a text string key), [3] (an array index,
+// or an integer key), or [*] (anything). The empty pattern matches the top-level item. Keys are compared by
+// their encoded bytes, so they must use the shortest encoding to match.
+func (t *Transformer) HandlePath(pattern string, fn TransformFunc) error {
+	p, err := parsePathPattern(pattern)
+	if err != nil {
+		return err
+	}
+	t.rules = append(t.rules, transformRule{pattern: p, fn: fn})
+	return nil
+}
+
+// HandleTag arranges for fn to be called for each item with the given tag number. The item passed to fn
+// includes the tag. (Path rules, by contrast, see tagged items only as a whole, not their content.)
+func (t *Transformer) HandleTag(number uint64, fn TransformFunc) {
+	t.rules = append(t.rules, transformRule{tag: number, isTag: true, fn: fn})
+}
+
+// RenameKey arranges for each map entry whose value's path matches pattern (see HandlePath) to have its key
+// replaced by the text string name.
+func (t *Transformer) RenameKey(pattern, name string) error {
+	p, err := parsePathPattern(pattern)
+	if err != nil {
+		return err
+	}
+	e := &encodeState{}
+	e.writeTextString(name)
+	t.rules = append(t.rules, transformRule{pattern: p, key: e.Bytes()})
+	return nil
+}
+
+// Transform returns the result of applying t's rules to the single item encoded in data.
+//
+// Each item's children are transformed before the item itself, so a function sees the results of the
+// rules applied below it. When several rules match an item, they are applied in the order they were added,
+// until one of them removes it. Parts of the document that no rule changes are copied byte for byte;
+// changed arrays and maps are written with definite lengths.
+func (t *Transformer) Transform(data []byte) (RawMessage, error) {
+	if err := checkValid(data); err != nil {
+		return nil, err
+	}
+	return t.transform(data, nil, false)
+}
+
+// TransformStream reads a CBOR sequence (RFC 8742) from r, transforms each of its items as with Transform,
+// and writes the results to w as they are completed. Only one item is held in memory at a time.
+func (t *Transformer) TransformStream(w io.Writer, r io.Reader) error {
+	p := NewParser(func(item RawMessage) error {
+		out, err := t.transform(item, nil, false)
+		if err != nil || out == nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	})
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if _, err := p.Write(buf[:n]); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			return p.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// transform implements Transform for item, found at path. Path rules apply to the outermost item at a path,
+// so they are skipped for tag content.
+func (t *Transformer) transform(item RawMessage, path Path, tagContent bool) (RawMessage, error) {
+	s := &scanner{data: item}
+	major, _, arg, _, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	body := &encodeState{}
+	n := uint64(0)
+	switch major {
+	case typeList:
+		i := 0
+		for elem, err := range ArrayElements(item) {
+			if err != nil {
+				return nil, err
+			}
+			out, err := t.transform(elem, append(path, PathElem{Index: i}), false)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || !bytes.Equal(out, elem)
+			if out != nil {
+				body.Write(out)
+				n++
+			}
+			i++
+		}
+	case typeMap:
+		for entry, err := range MapEntries(item) {
+			if err != nil {
+				return nil, err
+			}
+			entryPath := append(path, PathElem{Key: entry.Key})
+			out, err := t.transform(entry.Value, entryPath, false)
+			if err != nil {
+				return nil, err
+			}
+			key := t.renamedKey(entryPath, entry.Key)
+			changed = changed || !bytes.Equal(out, entry.Value) || !bytes.Equal(key, entry.Key)
+			if out != nil {
+				body.Write(key)
+				body.Write(out)
+				n++
+			}
+		}
+	case typeTag:
+		content := item[s.off:]
+		out, err := t.transform(content, path, true)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			return nil, nil
+		}
+		if !bytes.Equal(out, content) {
+			item = append(item[:s.off:s.off], out...)
+		}
+	}
+	if changed {
+		e := &encodeState{}
+		e.writeMajorWithNumber(major, n)
+		e.Write(body.Bytes())
+		item = e.Bytes()
+	}
+
+	for _, rule := range t.rules {
+		switch {
+		case rule.fn == nil:
+			continue
+		case rule.isTag:
+			if major != typeTag || arg != rule.tag {
+				continue
+			}
+		case tagContent || !matchPath(rule.pattern, path):
+			continue
+		}
+		out, err := rule.fn(path, item)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			return nil, nil
+		}
+		if err := checkValid(out); err != nil {
+			return nil, fmt.Errorf("cbor: transform of %s returned malformed data: %w", path, err)
+		}
+		item = out
+		major, _, arg, _, _ = (&scanner{data: item}).header()
+	}
+	return item, nil
+}
+
+func (t *Transformer) renamedKey(path Path, key RawMessage) RawMessage {
+	for _, rule := range t.rules {
+		if rule.key != nil && matchPath(rule.pattern, path) {
+			key = rule.key
+		}
+	}
+	return key
+}
+
+// A pathPattern is one step of a pattern given to HandlePath.
+type pathPattern struct {
+	any   bool       // [*]
+	key   RawMessage // the encoded key
+	index int        // the array index matched; -1 for none
+}
+
+func (p pathPattern) matches(elem PathElem) bool {
+	switch {
+	case p.any:
+		return true
+	case elem.Key == nil:
+		return p.index >= 0 && p.index == elem.Index
+	}
+	return bytes.Equal(p.key, elem.Key)
+}
+
+func matchPath(pattern []pathPattern, path Path) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, p := range pattern {
+		if !p.matches(path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePathPattern(s string) ([]pathPattern, error) {
+	orig := s
+	pattern := []pathPattern{}
+	for s != "" {
+		if s[0] != '[' {
+			return nil, fmt.Errorf("cbor: bad path pattern %q: expected '['", orig)
+		}
+		s = s[1:]
+		p := pathPattern{index: -1}
+		e := &encodeState{}
+		switch {
+		case strings.HasPrefix(s, "*"):
+			p.any = true
+			s = s[1:]
+		case strings.HasPrefix(s, `"`):
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("cbor: bad path pattern %q: %s", orig, err)
+			}
+			key, _ := strconv.Unquote(quoted)
+			e.writeTextString(key)
+			s = s[len(quoted):]
+		default:
+			i := strings.IndexByte(s, ']')
+			if i < 0 {
+				i = len(s)
+			}
+			n, err := strconv.ParseInt(s[:i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cbor: bad path pattern %q: %s", orig, err)
+			}
+			e.writeInt(n)
+			p.index = int(max(n, -1))
+			s = s[i:]
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, fmt.Errorf("cbor: bad path pattern %q: expected ']'", orig)
+		}
+		s = s[1:]
+		p.key = e.Bytes()
+		pattern = append(pattern, p)
+	}
+	return pattern, nil
+}
@@ -0,0 +1,109 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	input := mustMarshal(t, OrderedMap{
+		{"name", "gopher"},
+		{"password", "hunter2"},
+		{"readings", []interface{}{
+			OrderedMap{{"celsius", 20}, {"at", 0}},
+			OrderedMap{{"celsius", 23}, {"at", 1}},
+		}},
+		{"tagged", RawMessage(mustDecodeHex(t, "d8646178"))}, // 100("x")
+	})
+	expected := mustMarshal(t, OrderedMap{
+		{"name", "gopher"},
+		{"readings", []interface{}{
+			OrderedMap{{"kelvin", 293}, {"at", 0}},
+			OrderedMap{{"kelvin", 296}, {"at", 1}},
+		}},
+		{"tagged", "x"},
+	})
+
+	var tr Transformer
+	for _, err := range []error{
+		tr.HandlePath(`["password"]`, func(Path, RawMessage) (RawMessage, error) { return nil, nil }),
+		tr.HandlePath(`["readings"][*]["celsius"]`, func(path Path, item RawMessage) (RawMessage, error) {
+			return Marshal(int(item[0]) + 273) // integers below 24 only
+		}),
+		tr.RenameKey(`["readings"][*]["celsius"]`, "kelvin"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr.HandleTag(100, func(path Path, item RawMessage) (RawMessage, error) {
+		return item[2:], nil // strip the two-byte tag header
+	})
+	actual, err := tr.Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("expected\n  %x\ngot\n  %x", expected, actual)
+	}
+}
+
+func TestTransformUnchanged(t *testing.T) {
+	// Indefinite-length containers and non-shortest encodings are kept as they are.
+	input := mustDecodeHex(t, "bf61619f1800ff6162d82a01ff")
+	var tr Transformer
+	if err := tr.HandlePath(`["c"]`, func(Path, RawMessage) (RawMessage, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := tr.Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, input) {
+		t.Errorf("expected %x unchanged; got %x", input, actual)
+	}
+}
+
+func TestTransformStream(t *testing.T) {
+	var tr Transformer
+	if err := tr.HandlePath("[1]", func(Path, RawMessage) (RawMessage, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.HandlePath("", func(path Path, item RawMessage) (RawMessage, error) {
+		if item[0] == 0xf6 {
+			return nil, nil // drop nulls from the sequence
+		}
+		return item, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := tr.TransformStream(&out, bytes.NewReader(mustDecodeHex(t, "83010203f6820405"))); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := hex.EncodeToString(out.Bytes()), "820103"+"8104"; actual != expected {
+		t.Errorf("expected %s; got %s", expected, actual)
+	}
+
+	if err := tr.TransformStream(&out, bytes.NewReader(mustDecodeHex(t, "8301"))); err == nil {
+		t.Error("TransformStream of a truncated sequence: expected an error, but err was nil")
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	var tr Transformer
+	for _, pattern := range []string{`x`, `[`, `["a"`, `[1.5]`, `["a]`, `[*`} {
+		if err := tr.HandlePath(pattern, nil); err == nil {
+			t.Errorf("HandlePath(%q): expected an error, but err was nil", pattern)
+		}
+	}
+	if err := tr.HandlePath("[0]", func(Path, RawMessage) (RawMessage, error) {
+		return RawMessage{0x82}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Transform(mustDecodeHex(t, "8101")); err == nil {
+		t.Error("Transform with a malformed replacement: expected an error, but err was nil")
+	}
+}
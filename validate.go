@@ -0,0 +1,230 @@
+package cbor
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Validator checks that a CBOR sequence (RFC 8742) is well-formed as it streams through, without holding
+// on to any of it: its memory use is fixed, apart from a stack that grows with the nesting depth (which is
+// limited). It's an io.Writer, so it can be placed in front of a message broker or any other consumer with
+// io.TeeReader or io.MultiWriter.
+//
+// Once the input is malformed, Write returns an error (a *SyntaxError, with the offset into the whole
+// stream) and keeps returning it. Close reports input that ends in the middle of an item.
+type Validator struct {
+	maxDepth int
+	off      int64 // bytes written so far
+	items    int64 // complete top-level items
+	err      error
+
+	// The header being read, which may be split between writes.
+	header      [9]byte
+	headerLen   int
+	headerNeed  int
+	headerStart int64
+
+	skip  uint64 // bytes of string content left to pass over
+	stack []validatorFrame
+	depth int // arrays, maps, and tags in stack
+
+	// If itemEnd is set, it's called with the offset just past each complete top-level item.
+	itemEnd func(off int64)
+}
+
+// A validatorFrame is an unfinished array, map, tag, or indefinite-length string.
+type validatorFrame struct {
+	major      byte
+	indefinite bool
+	remaining  uint64 // items left, if definite (keys and values are counted separately)
+	count      uint64 // items seen, if indefinite
+}
+
+// NewValidator returns a Validator that accepts nesting up to the depth that the rest of the package does
+// (10000).
+func NewValidator() *Validator {
+	return &Validator{maxDepth: maxNestingDepth}
+}
+
+// SetMaxDepth sets the deepest nesting of arrays, maps, and tags that the Validator accepts.
+func (v *Validator) SetMaxDepth(depth int) {
+	v.maxDepth = depth
+}
+
+// Items returns the number of complete top-level items written so far.
+func (v *Validator) Items() int64 {
+	return v.items
+}
+
+// Write checks the next part of the input. It returns an error, having consumed only the bytes before the
+// problem, if the input is malformed.
+func (v *Validator) Write(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	for i := 0; i < len(p); {
+		if v.skip > 0 {
+			n := int(min(v.skip, uint64(len(p)-i)))
+			i += n
+			v.off += int64(n)
+			if v.skip -= uint64(n); v.skip == 0 {
+				v.finishItem()
+			}
+			continue
+		}
+		b := p[i]
+		if v.headerLen == 0 {
+			v.headerStart = v.off
+			v.headerNeed = 1
+			if info := b & 0x1F; info >= 24 && info <= 27 {
+				v.headerNeed += 1 << (info - 24)
+			}
+		}
+		v.header[v.headerLen] = b
+		v.headerLen++
+		i++
+		v.off++
+		if v.headerLen < v.headerNeed {
+			continue
+		}
+		v.headerLen = 0
+		if err := v.item(); err != nil {
+			v.err = err
+			// The header may have started in an earlier write.
+			return max(0, i-int(v.off-v.headerStart)), err
+		}
+	}
+	return len(p), nil
+}
+
+// Close reports whether the input ended between items. It returns an *UnexpectedEOFError if it didn't.
+func (v *Validator) Close() error {
+	if v.err != nil {
+		return v.err
+	}
+	var needed uint64
+	switch {
+	case v.headerLen > 0:
+		needed = uint64(v.headerNeed - v.headerLen)
+	case v.skip > 0:
+		needed = v.skip
+	case len(v.stack) > 0:
+		needed = 1
+	default:
+		return nil
+	}
+	return &UnexpectedEOFError{v.off, int(min(needed, math.MaxInt))}
+}
+
+func (v *Validator) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{fmt.Sprintf(format, args...), v.headerStart, ErrMalformed}
+}
+
+// item handles the complete header in v.header.
+func (v *Validator) item() error {
+	s := &scanner{data: v.header[:v.headerNeed]}
+	major, info, arg, indefinite, err := s.header()
+	if err != nil {
+		if se, ok := err.(*SyntaxError); ok {
+			se.Offset += v.headerStart
+		}
+		return err
+	}
+	isBreak := major == typeMajor7 && indefinite
+
+	var top *validatorFrame
+	if len(v.stack) > 0 {
+		top = &v.stack[len(v.stack)-1]
+	}
+	if top != nil && (top.major == typeByteString || top.major == typeTextString) {
+		// Inside an indefinite-length string, only chunks and the break may appear.
+		if isBreak {
+			v.pop()
+			v.finishItem()
+			return nil
+		}
+		if major != top.major || indefinite {
+			return v.errorf("invalid chunk in indefinite-length string")
+		}
+		v.skip = arg
+		return nil
+	}
+	if isBreak {
+		// A break may not come between a key and its value.
+		if top == nil || !top.indefinite || top.major == typeMap && top.count%2 == 1 {
+			return v.errorf("unexpected break")
+		}
+		v.pop()
+		v.finishItem()
+		return nil
+	}
+
+	switch major {
+	case typePosInt, typeNegInt:
+		v.finishItem()
+	case typeByteString, typeTextString:
+		if indefinite {
+			v.stack = append(v.stack, validatorFrame{major: major, indefinite: true})
+			return nil
+		}
+		if v.skip = arg; arg == 0 {
+			v.finishItem()
+		}
+	case typeList, typeMap, typeTag:
+		if v.depth >= v.maxDepth {
+			return &SyntaxError{"exceeded max depth", v.headerStart, ErrMaxDepth}
+		}
+		remaining := arg
+		switch {
+		case major == typeTag:
+			remaining = 1
+		case major == typeMap:
+			remaining = arg * 2
+			if arg > math.MaxUint64/2 {
+				remaining = math.MaxUint64
+			}
+		}
+		if !indefinite && remaining == 0 {
+			v.finishItem()
+			return nil
+		}
+		v.stack = append(v.stack, validatorFrame{major: major, indefinite: indefinite, remaining: remaining})
+		v.depth++
+	default:
+		if info == 24 && arg < 32 {
+			return v.errorf("invalid simple value %d in two-byte form", arg)
+		}
+		v.finishItem()
+	}
+	return nil
+}
+
+func (v *Validator) pop() {
+	if top := v.stack[len(v.stack)-1]; top.major != typeByteString && top.major != typeTextString {
+		v.depth--
+	}
+	v.stack = v.stack[:len(v.stack)-1]
+}
+
+// finishItem records that an item (or a chunk of an indefinite-length string) has ended, along with any
+// containers that it completes.
+func (v *Validator) finishItem() {
+	for len(v.stack) > 0 {
+		top := &v.stack[len(v.stack)-1]
+		switch {
+		case top.major == typeByteString || top.major == typeTextString:
+			return
+		case top.indefinite:
+			top.count++
+			return
+		}
+		if top.remaining--; top.remaining > 0 {
+			return
+		}
+		v.pop()
+	}
+	v.items++
+	if v.itemEnd != nil {
+		v.itemEnd(v.off)
+	}
+}
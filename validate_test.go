@@ -0,0 +1,87 @@
+package cbor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cespare/cbor/cbortest"
+)
+
+func TestValidator(t *testing.T) {
+	vectors := append(cbortest.AppendixA(), cbortest.Extended()...)
+	var seq []byte
+	for _, v := range vectors {
+		seq = append(seq, v.CBOR...)
+	}
+	for _, chunkSize := range []int{1, 3, len(seq)} {
+		v := NewValidator()
+		for b := seq; len(b) > 0; b = b[min(chunkSize, len(b)):] {
+			if _, err := v.Write(b[:min(chunkSize, len(b))]); err != nil {
+				t.Fatalf("chunk size %d: %s", chunkSize, err)
+			}
+		}
+		if err := v.Close(); err != nil {
+			t.Errorf("chunk size %d: Close: %s", chunkSize, err)
+		}
+		if v.Items() != int64(len(vectors)) {
+			t.Errorf("chunk size %d: got %d items; want %d", chunkSize, v.Items(), len(vectors))
+		}
+	}
+}
+
+func TestValidatorErrors(t *testing.T) {
+	for _, test := range []struct {
+		input    string // hex
+		expected error
+		offset   int64
+	}{
+		{"001c", ErrMalformed, 1},
+		{"ff", ErrMalformed, 0},
+		{"bf01ff", ErrMalformed, 2},
+		{"5f4101614101ff", ErrMalformed, 3},
+		{"5f5f4101ffff", ErrMalformed, 1},
+		{"9f1f", ErrMalformed, 1},
+		{"c1f818", ErrMalformed, 1},
+		{"8301", ErrTruncated, 2},
+		{"44aabb", ErrTruncated, 3},
+		{"1a0001", ErrTruncated, 3},
+		{"5f41", ErrTruncated, 2},
+		{strings.Repeat("81", maxNestingDepth) + "81", ErrMaxDepth, maxNestingDepth},
+	} {
+		v := NewValidator()
+		data := mustDecodeHex(t, test.input)
+		n, err := v.Write(data)
+		if err == nil {
+			n, err = len(data), v.Close()
+		}
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%.20s: expected %v; got %v", test.input, test.expected, err)
+			continue
+		}
+		var syntaxErr *SyntaxError
+		var eofErr *UnexpectedEOFError
+		var offset int64
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &eofErr):
+			offset = eofErr.Offset
+		}
+		if offset != test.offset || int64(n) != test.offset && test.expected != ErrTruncated {
+			t.Errorf("%.20s: got offset %d, %d bytes written; want %d", test.input, offset, n, test.offset)
+		}
+		if _, err2 := v.Write([]byte{0}); test.expected != ErrTruncated && err2 != err {
+			t.Errorf("%.20s: Write after an error returned %v", test.input, err2)
+		}
+	}
+
+	v := NewValidator()
+	v.SetMaxDepth(2)
+	if _, err := v.Write(mustDecodeHex(t, "818100818181")); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("expected ErrMaxDepth with a limit of 2; got %v", err)
+	}
+	if v.Items() != 1 {
+		t.Errorf("got %d items before the error; want 1", v.Items())
+	}
+}
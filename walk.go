@@ -0,0 +1,109 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// A PathElem is one step of a Path: an index into an array, or the key of a map entry.
+type PathElem struct {
+	Key   RawMessage // The encoded key, for map entries; nil for array elements.
+	Index int        // The index, for array elements.
+}
+
+// A Path locates an item within an encoded document, as the sequence of steps taken from the top-level item
+// to reach it.
+type Path []PathElem
+
+// String returns the path in a form like ["name"][0][1], for messages. Text string and integer keys are
+// shown as their values; other keys are shown as the hex of their encoding.
+func (p Path) String() string {
+	var b strings.Builder
+	for _, elem := range p {
+		b.WriteByte('[')
+		if elem.Key == nil {
+			b.WriteString(strconv.Itoa(elem.Index))
+		} else {
+			b.WriteString(keyString(elem.Key))
+		}
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+func keyString(key RawMessage) string {
+	s := &scanner{data: key}
+	major, _, arg, indefinite, err := s.header()
+	switch {
+	case err != nil || indefinite:
+	case major == typePosInt:
+		return strconv.FormatUint(arg, 10)
+	case major == typeNegInt && arg < 1<<63:
+		return strconv.FormatInt(-1-int64(arg), 10)
+	case major == typeTextString && uint64(len(key)-s.off) == arg:
+		return strconv.Quote(string(key[s.off:]))
+	}
+	return "h'" + hex.EncodeToString(key) + "'"
+}
+
+// A Visitor's Visit method is called by Walk for each item encountered. If the Visitor w it returns is not
+// nil, Walk visits each of the item's children with w, followed by a call of w.Visit(path, nil). A non-nil
+// error stops the walk and is returned by Walk.
+type Visitor interface {
+	Visit(path Path, item RawMessage) (w Visitor, err error)
+}
+
+// Walk traverses the single item encoded in data in depth-first order, keeping track of the path to each
+// item so that tools such as redactors and statistics collectors don't need to. It starts by calling
+// v.Visit(nil, data). The children of an array are its elements, and the children of a map are the values of
+// its entries (the keys are only seen as part of the paths). The child of a tag is its content, which has
+// the same path as the tag itself.
+//
+// The path passed to Visit is reused between calls, so a Visitor that keeps it must copy it. Walk checks that
+// data is well-formed before visiting anything.
+func Walk(data []byte, v Visitor) error {
+	if err := checkValid(data); err != nil {
+		return err
+	}
+	return walk(data, nil, v)
+}
+
+func walk(item RawMessage, path Path, v Visitor) error {
+	w, err := v.Visit(path, item)
+	if err != nil || w == nil {
+		return err
+	}
+	switch item[0] >> 5 {
+	case typeList:
+		i := 0
+		for elem, err := range ArrayElements(item) {
+			if err != nil {
+				return err
+			}
+			if err := walk(elem, append(path, PathElem{Index: i}), w); err != nil {
+				return err
+			}
+			i++
+		}
+	case typeMap:
+		for entry, err := range MapEntries(item) {
+			if err != nil {
+				return err
+			}
+			if err := walk(entry.Value, append(path, PathElem{Key: entry.Key}), w); err != nil {
+				return err
+			}
+		}
+	case typeTag:
+		s := &scanner{data: item}
+		if _, _, _, _, err := s.header(); err != nil {
+			return err
+		}
+		if err := walk(item[s.off:], path, w); err != nil {
+			return err
+		}
+	}
+	_, err = w.Visit(path, nil)
+	return err
+}
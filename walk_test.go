@@ -0,0 +1,73 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recorder is a Visitor that records each call to Visit.
+type recorder struct {
+	calls *[]string
+	skip  string // hex of an item whose children shouldn't be visited
+}
+
+func (r recorder) Visit(path Path, item RawMessage) (Visitor, error) {
+	if item == nil {
+		*r.calls = append(*r.calls, fmt.Sprintf("%s end", path))
+		return nil, nil
+	}
+	*r.calls = append(*r.calls, fmt.Sprintf("%s %x", path, item))
+	if hex.EncodeToString(item) == r.skip {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func TestWalk(t *testing.T) {
+	// {"a": [1, 2], -2: 1(3), h'00': {}}
+	input := mustDecodeHex(t, "a3616182010221c1034100a0")
+	var calls []string
+	if err := Walk(input, recorder{calls: &calls, skip: "a0"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		" a3616182010221c1034100a0",
+		`["a"] 820102`,
+		`["a"][0] 01`,
+		`["a"][0] end`,
+		`["a"][1] 02`,
+		`["a"][1] end`,
+		`["a"] end`,
+		"[-2] c103",
+		"[-2] 03",
+		"[-2] end",
+		"[-2] end",
+		"[h'4100'] a0",
+		" end",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls\n  %q\ngot\n  %q", expected, calls)
+	}
+}
+
+type errVisitor struct{ err error }
+
+func (v errVisitor) Visit(path Path, item RawMessage) (Visitor, error) {
+	if len(path) > 0 {
+		return nil, v.err
+	}
+	return v, nil
+}
+
+func TestWalkErrors(t *testing.T) {
+	if err := Walk(mustDecodeHex(t, "8201"), errVisitor{}); err == nil {
+		t.Error("Walk of malformed data: expected an error, but err was nil")
+	}
+	stop := errors.New("stop")
+	if err := Walk(mustDecodeHex(t, "820102"), errVisitor{stop}); err != stop {
+		t.Errorf("Walk with a failing Visitor: got error %v; want %v", err, stop)
+	}
+}